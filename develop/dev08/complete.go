@@ -0,0 +1,164 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Слово перед курсором, которое нужно дополнить: его начало в line
+// и значение без кавычек и \. command - слово стоит на месте имени команды
+func completionWord(line string) (start int, word string, command bool) {
+	var b strings.Builder
+	inWord := false
+	cmdPos := true // Следующее слово - имя команды
+	redir := false // Следующее слово - имя файла после > или <
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			inWord = false
+			continue
+		case strings.IndexByte("|&;()", c) >= 0:
+			inWord, cmdPos, redir = false, true, false
+			continue
+		case c == '<' || c == '>':
+			inWord, redir = false, true
+			continue
+		}
+		if !inWord {
+			start, inWord = i, true
+			b.Reset()
+			command = cmdPos && !redir
+			if redir {
+				redir = false
+			} else {
+				cmdPos = false
+			}
+		}
+		switch c {
+		case '\\':
+			if i+1 < len(line) {
+				i++
+				b.WriteByte(line[i])
+			}
+		case '\'', '"':
+			// Незакрытая кавычка тянется до курсора
+			end := strings.IndexByte(line[i+1:], c)
+			if end < 0 {
+				end = len(line) - i - 1
+			}
+			b.WriteString(line[i+1 : i+1+end])
+			i += end + 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	if !inWord {
+		return len(line), "", cmdPos && !redir
+	}
+	return start, b.String(), command
+}
+
+// Экранирует \ символы, которые иначе разберет интерпретатор
+func escapeWord(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(" \t\n\\'\"|&;()<>$`*?[]{}!", s[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Встроенные команды и программы из каталогов path, имена которых
+// начинаются с prefix. Результат отсортирован и без повторов
+func completeCommands(prefix string, builtins []string, path string) []string {
+	seen := make(map[string]bool)
+	for _, name := range builtins {
+		if strings.HasPrefix(name, prefix) {
+			seen[name] = true
+		}
+	}
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			dir = "."
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if !strings.HasPrefix(name, prefix) || seen[name] {
+				continue
+			}
+			// Stat, а не Info: ссылка на программу тоже программа
+			info, err := os.Stat(filepath.Join(dir, name))
+			if err == nil && info.Mode().IsRegular() && info.Mode()&0o111 != 0 {
+				seen[name] = true
+			}
+		}
+	}
+	res := make([]string, 0, len(seen))
+	for name := range seen {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Пути файлов, которые начинаются с prefix. К каталогам
+// добавляется /. Файлы на точку - только если prefix указывает на них.
+// ~/ в начале prefix раскрывается в home
+func completeFiles(prefix, home string) []string {
+	dir, base := "", prefix
+	if i := strings.LastIndexByte(prefix, '/'); i >= 0 {
+		dir, base = prefix[:i+1], prefix[i+1:]
+	}
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	} else if rest, ok := strings.CutPrefix(readDir, "~/"); ok && home != "" {
+		readDir = filepath.Join(home, rest)
+	}
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+	var res []string
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, base) || (name[0] == '.' && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		// Ссылку на каталог тоже дополняем как каталог
+		if info, err := os.Stat(filepath.Join(readDir, name)); err == nil && info.IsDir() {
+			name += "/"
+		}
+		res = append(res, dir+name)
+	}
+	return res
+}
+
+// Общее начало строк, не разрезающее символы UTF-8
+func commonPrefix(list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	prefix := list[0]
+	for _, s := range list[1:] {
+		n := 0
+		for n < len(prefix) && n < len(s) && prefix[n] == s[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompletionWord(t *testing.T) {
+	testCases := []struct {
+		has     string
+		start   int
+		word    string
+		command bool
+	}{
+		{"", 0, "", true},
+		{"ec", 0, "ec", true},
+		{"echo ", 5, "", false},
+		{"echo a", 5, "a", false},
+		{"ls | gr", 5, "gr", true},
+		{"a && (b", 6, "b", true},
+		{"cat <fi", 5, "fi", false},
+		{"> out cm", 6, "cm", true},
+		{`cat my\ fi`, 4, "my fi", false},
+		{`cat "my fi`, 4, "my fi", false},
+		{`cat 'a b'c`, 4, "a bc", false},
+	}
+	for _, tc := range testCases {
+		start, word, command := completionWord(tc.has)
+		if start != tc.start || word != tc.word || command != tc.command {
+			t.Errorf("%q: got %d %q %v; want %d %q %v", tc.has, start, word, command, tc.start, tc.word, tc.command)
+		}
+	}
+}
+
+func TestCompleteFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"file_one", "file_two", ".hidden", "sub/inner"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("err should be nil: %s", err)
+		}
+		if err := os.WriteFile(path, nil, 0o755); err != nil {
+			t.Fatalf("err should be nil: %s", err)
+		}
+	}
+	testCases := []struct {
+		has  string
+		home string
+		want []string
+	}{
+		{dir + "/fi", "", []string{dir + "/file_one", dir + "/file_two"}},
+		{dir + "/", "", []string{dir + "/file_one", dir + "/file_two", dir + "/sub/"}},
+		{dir + "/.h", "", []string{dir + "/.hidden"}},
+		{dir + "/sub/", "", []string{dir + "/sub/inner"}},
+		{"~/s", dir, []string{"~/sub/"}},
+		{dir + "/none", "", nil},
+	}
+	for _, tc := range testCases {
+		if got := completeFiles(tc.has, tc.home); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q; want %q", tc.has, got, tc.want)
+		}
+	}
+
+	// Программы - только исполняемые файлы
+	bin := filepath.Join(dir, "bin")
+	os.Mkdir(bin, 0o755)
+	os.WriteFile(filepath.Join(bin, "echo2"), nil, 0o755)
+	os.WriteFile(filepath.Join(bin, "echo3"), nil, 0o644)
+	os.Mkdir(filepath.Join(bin, "echo4"), 0o755)
+	got := completeCommands("ec", []string{"echo", "exit", "echo2"}, bin+":"+filepath.Join(dir, "none"))
+	if want := []string{"echo", "echo2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	testCases := []struct {
+		has  []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{"abc"}, "abc"},
+		{[]string{"abc", "abd", "ab"}, "ab"},
+		{[]string{"привет", "прибой"}, "при"},
+		// Буквы д и ж отличаются только вторым байтом
+		{[]string{"ад", "аж"}, "а"},
+	}
+	for _, tc := range testCases {
+		if got := commonPrefix(tc.has); got != tc.want {
+			t.Errorf("%q: got %q; want %q", tc.has, got, tc.want)
+		}
+	}
+	if got, want := escapeWord(`a b'c$d*~`), `a\ b\'c\$d\*~`; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,498 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Дополняет строку до курсора: возвращает начало дополняемого
+// слова в line и варианты этого слова без экранирования
+type Completer func(line string) (start int, candidates []string)
+
+// Клавиши-управляющие символы
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyCtrlJ     = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyCtrlY     = 25
+	keyEsc       = 27
+	keyBackspace = 127
+)
+
+// Клавиши, которые терминал передает escape-последовательностями
+const (
+	keyUp = utf8.MaxRune + 1 + iota
+	keyDown
+	keyRight
+	keyLeft
+	keyHome
+	keyEnd
+	keyDelete
+	keyWordLeft
+	keyWordRight
+	keyKillWord     // Alt-d
+	keyBackKillWord // Alt-Backspace
+	keyUnknown
+)
+
+// Редактор строки в сыром режиме терминала, как readline:
+// перемещение курсора, удаление в буфер и вставка из него (kill/yank),
+// листание истории, обратный поиск по ней Ctrl-R и дополнение по Tab
+type LineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	fd       int // Терминал, -1 - сырой режим не включается
+	history  *History
+	complete Completer
+
+	prompt  string
+	buf     []rune // Редактируемая строка
+	pos     int    // Курсор в buf
+	killed  []rune // Последний удаленный текст для Ctrl-Y
+	histPos int    // Номер показанной строки истории, Len()+1 - новая строка
+	edited  []rune // Новая строка, пока листается история
+	lastKey rune
+}
+
+// Редактор, который читает клавиши из in и рисует строку в out.
+// Если in - терминал, на время чтения строки он переводится в сырой режим
+func NewLineEditor(in, out *os.File, history *History, complete Completer) *LineEditor {
+	fd := int(in.Fd())
+	if !isTerminal(fd) {
+		fd = -1
+	}
+	return &LineEditor{
+		in:       bufio.NewReader(in),
+		out:      out,
+		fd:       fd,
+		history:  history,
+		complete: complete,
+	}
+}
+
+// Читает строку, реализует LineReader. Ctrl-D на пустой строке - io.EOF,
+// Ctrl-C отменяет строку и возвращает пустую
+func (e *LineEditor) ReadLine(prompt string) (string, error) {
+	if e.fd >= 0 {
+		old, err := makeRaw(e.fd)
+		if err != nil {
+			return "", err
+		}
+		defer restoreTerminal(e.fd, old)
+	}
+	e.prompt, e.buf, e.pos = prompt, nil, 0
+	e.histPos, e.edited = e.historyLen()+1, nil
+	e.lastKey = 0
+	e.refresh()
+	for {
+		key, err := e.readKey()
+		if err == io.EOF && len(e.buf) > 0 {
+			// Ввод закончился посреди строки, отдаем то, что есть
+			e.write("\r\n")
+			return string(e.buf), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		line, done, err := e.handle(key)
+		e.lastKey = key
+		if done || err != nil {
+			return line, err
+		}
+	}
+}
+
+// Обрабатывает клавишу. done - строка введена
+func (e *LineEditor) handle(key rune) (line string, done bool, err error) {
+	switch key {
+	case keyEnter, keyCtrlJ:
+		e.pos = len(e.buf)
+		e.refresh()
+		e.write("\r\n")
+		return string(e.buf), true, nil
+	case keyCtrlC:
+		e.write("^C\r\n")
+		return "", true, nil
+	case keyCtrlD:
+		if len(e.buf) == 0 {
+			e.write("\r\n")
+			return "", true, io.EOF
+		}
+		e.deleteRange(e.pos, e.pos+1)
+	case keyDelete:
+		e.deleteRange(e.pos, e.pos+1)
+	case keyCtrlH, keyBackspace:
+		if e.pos > 0 {
+			e.deleteRange(e.pos-1, e.pos)
+		}
+	case keyCtrlA, keyHome:
+		e.pos = 0
+	case keyCtrlE, keyEnd:
+		e.pos = len(e.buf)
+	case keyCtrlB, keyLeft:
+		if e.pos > 0 {
+			e.pos--
+		}
+	case keyCtrlF, keyRight:
+		if e.pos < len(e.buf) {
+			e.pos++
+		}
+	case keyWordLeft:
+		e.pos = e.wordStart()
+	case keyWordRight:
+		e.pos = e.wordEnd()
+	case keyCtrlK:
+		e.kill(e.pos, len(e.buf))
+	case keyCtrlU:
+		e.kill(0, e.pos)
+	case keyCtrlW, keyBackKillWord:
+		e.kill(e.wordStart(), e.pos)
+	case keyKillWord:
+		e.kill(e.pos, e.wordEnd())
+	case keyCtrlY:
+		e.insert(e.killed)
+	case keyCtrlP, keyUp:
+		e.showHistory(e.histPos - 1)
+	case keyCtrlN, keyDown:
+		e.showHistory(e.histPos + 1)
+	case keyCtrlL:
+		e.write("\x1b[H\x1b[2J")
+	case keyCtrlR:
+		return e.search()
+	case keyTab:
+		e.completeWord()
+	default:
+		if key >= ' ' && key <= utf8.MaxRune {
+			e.insert([]rune{key})
+		}
+	}
+	e.refresh()
+	return "", false, nil
+}
+
+// Читает клавишу, escape-последовательности стрелок и т.п.
+// возвращаются как одна клавиша
+func (e *LineEditor) readKey() (rune, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != keyEsc {
+		return r, err
+	}
+	c, _, err := e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	switch c {
+	case 'b':
+		return keyWordLeft, nil
+	case 'f':
+		return keyWordRight, nil
+	case 'd':
+		return keyKillWord, nil
+	case keyBackspace, keyCtrlH:
+		return keyBackKillWord, nil
+	case 'O':
+		// ESC O H и ESC O F - Home и End в некоторых терминалах
+		c, _, err = e.in.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		switch c {
+		case 'H':
+			return keyHome, nil
+		case 'F':
+			return keyEnd, nil
+		}
+		return keyUnknown, nil
+	case '[':
+	default:
+		return keyUnknown, nil
+	}
+	// CSI: параметры из цифр и ; до завершающего символа
+	var params strings.Builder
+	for {
+		c, _, err = e.in.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		if c >= 0x40 && c <= 0x7e {
+			break
+		}
+		params.WriteRune(c)
+	}
+	// С модификатором, например ESC [1;5C - Ctrl-стрелка
+	mod := strings.Contains(params.String(), ";")
+	switch c {
+	case 'A':
+		return keyUp, nil
+	case 'B':
+		return keyDown, nil
+	case 'C':
+		if mod {
+			return keyWordRight, nil
+		}
+		return keyRight, nil
+	case 'D':
+		if mod {
+			return keyWordLeft, nil
+		}
+		return keyLeft, nil
+	case 'H':
+		return keyHome, nil
+	case 'F':
+		return keyEnd, nil
+	case '~':
+		switch params.String() {
+		case "1", "7":
+			return keyHome, nil
+		case "4", "8":
+			return keyEnd, nil
+		case "3":
+			return keyDelete, nil
+		}
+	}
+	return keyUnknown, nil
+}
+
+func (e *LineEditor) write(s string) {
+	io.WriteString(e.out, s)
+}
+
+// Перерисовывает строку с приглашением и ставит курсор на место
+func (e *LineEditor) refresh() {
+	e.draw(e.prompt, e.buf, e.pos)
+}
+
+func (e *LineEditor) draw(prompt string, buf []rune, pos int) {
+	var b strings.Builder
+	b.WriteString("\r")
+	b.WriteString(prompt)
+	b.WriteString(string(buf))
+	// Стираем остаток прежней строки
+	b.WriteString("\x1b[K")
+	if n := len(buf) - pos; n > 0 {
+		fmt.Fprintf(&b, "\x1b[%dD", n)
+	}
+	e.write(b.String())
+}
+
+func (e *LineEditor) insert(rs []rune) {
+	buf := make([]rune, 0, len(e.buf)+len(rs))
+	buf = append(buf, e.buf[:e.pos]...)
+	buf = append(buf, rs...)
+	e.buf = append(buf, e.buf[e.pos:]...)
+	e.pos += len(rs)
+}
+
+func (e *LineEditor) deleteRange(from, to int) {
+	if from >= to || to > len(e.buf) {
+		return
+	}
+	e.buf = append(e.buf[:from], e.buf[to:]...)
+	e.pos = from
+}
+
+// Удаляет текст в буфер для Ctrl-Y
+func (e *LineEditor) kill(from, to int) {
+	if from >= to {
+		return
+	}
+	e.killed = append([]rune(nil), e.buf[from:to]...)
+	e.deleteRange(from, to)
+}
+
+// Начало слова слева от курсора
+func (e *LineEditor) wordStart() int {
+	i := e.pos
+	for i > 0 && unicode.IsSpace(e.buf[i-1]) {
+		i--
+	}
+	for i > 0 && !unicode.IsSpace(e.buf[i-1]) {
+		i--
+	}
+	return i
+}
+
+// Конец слова справа от курсора
+func (e *LineEditor) wordEnd() int {
+	i := e.pos
+	for i < len(e.buf) && unicode.IsSpace(e.buf[i]) {
+		i++
+	}
+	for i < len(e.buf) && !unicode.IsSpace(e.buf[i]) {
+		i++
+	}
+	return i
+}
+
+func (e *LineEditor) historyLen() int {
+	if e.history == nil {
+		return 0
+	}
+	return e.history.Len()
+}
+
+// Показывает строку истории с номером n, Len()+1 - новую строку
+func (e *LineEditor) showHistory(n int) {
+	if n < 1 || n > e.historyLen()+1 {
+		return
+	}
+	if e.histPos == e.historyLen()+1 {
+		e.edited = e.buf
+	}
+	e.histPos = n
+	if n == e.historyLen()+1 {
+		e.buf = e.edited
+	} else {
+		line, _ := e.history.Get(n)
+		e.buf = []rune(line)
+	}
+	e.pos = len(e.buf)
+}
+
+// Обратный поиск по истории. Набранный текст ищется в строках
+// от новых к старым, Ctrl-R - следующее совпадение, Enter выполняет
+// найденную строку, Ctrl-G и Ctrl-C возвращают исходную, остальные
+// клавиши оставляют найденную строку для редактирования
+func (e *LineEditor) search() (line string, done bool, err error) {
+	var query []rune
+	found := e.historyLen() + 1 // Номер найденной строки
+	match := string(e.buf)
+	failed := false
+	// Ищет запрос в строках с номером from и меньше
+	find := func(from int) {
+		for n := from; n >= 1; n-- {
+			if l, _ := e.history.Get(n); strings.Contains(l, string(query)) {
+				found, match, failed = n, l, false
+				return
+			}
+		}
+		failed = true
+	}
+	for {
+		label := "reverse-i-search"
+		if failed {
+			label = "failed " + label
+		}
+		prompt := fmt.Sprintf("(%s)`%s': ", label, string(query))
+		rs := []rune(match)
+		pos := len(rs)
+		if i := strings.Index(match, string(query)); i >= 0 && len(query) > 0 {
+			pos = utf8.RuneCountInString(match[:i])
+		}
+		e.draw(prompt, rs, pos)
+
+		key, err := e.readKey()
+		if err != nil {
+			return "", false, err
+		}
+		switch {
+		case key == keyCtrlR:
+			if len(query) > 0 {
+				find(found - 1)
+			}
+		case key == keyBackspace || key == keyCtrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				find(e.historyLen())
+			}
+		case key == keyCtrlG || key == keyCtrlC:
+			e.refresh()
+			return "", false, nil
+		case key >= ' ' && key <= utf8.MaxRune:
+			query = append(query, key)
+			find(min(found, e.historyLen()))
+		default:
+			// Берем найденную строку и обрабатываем клавишу как обычно
+			e.buf, e.pos = []rune(match), pos
+			e.histPos = found
+			return e.handle(key)
+		}
+	}
+}
+
+// Дополняет слово перед курсором. Если вариантов несколько, дописывает
+// их общее начало, а на повторный Tab выводит список вариантов
+func (e *LineEditor) completeWord() {
+	if e.complete == nil {
+		return
+	}
+	line := string(e.buf[:e.pos])
+	start, candidates := e.complete(line)
+	if len(candidates) == 0 {
+		e.write("\a")
+		return
+	}
+	word := commonPrefix(candidates)
+	if len(candidates) == 1 && !strings.HasSuffix(word, "/") {
+		word += " "
+	}
+	replace := []rune(escapeWord(strings.TrimSuffix(word, " ")))
+	if strings.HasSuffix(word, " ") {
+		replace = append(replace, ' ')
+	}
+	from := utf8.RuneCountInString(line[:start])
+	if string(replace) != line[start:] {
+		e.buf = append(e.buf[:from:from], append(replace, e.buf[e.pos:]...)...)
+		e.pos = from + len(replace)
+		return
+	}
+	if e.lastKey != keyTab {
+		e.write("\a")
+		return
+	}
+	e.listCandidates(candidates)
+}
+
+// Выводит варианты дополнения в столбцы под строкой
+func (e *LineEditor) listCandidates(candidates []string) {
+	names := make([]string, len(candidates))
+	width := 0
+	for i, c := range candidates {
+		// Для путей показываем только последнюю часть
+		name := strings.TrimSuffix(c, "/")
+		name = name[strings.LastIndexByte(name, '/')+1:]
+		if strings.HasSuffix(c, "/") {
+			name += "/"
+		}
+		names[i] = name
+		width = max(width, utf8.RuneCountInString(name))
+	}
+	width += 2
+	cols := 1
+	if e.fd >= 0 {
+		cols = max(1, terminalWidth(e.fd)/width)
+	}
+	var b strings.Builder
+	b.WriteString("\r\n")
+	for i, name := range names {
+		b.WriteString(name)
+		if (i+1)%cols == 0 || i == len(names)-1 {
+			b.WriteString("\r\n")
+		} else {
+			b.WriteString(strings.Repeat(" ", width-utf8.RuneCountInString(name)))
+		}
+	}
+	e.write(b.String())
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLineEditor(t *testing.T) {
+	h := NewHistory(10)
+	h.Add("echo hello")
+	h.Add("ls -l")
+	complete := func(line string) (int, []string) {
+		start, word, _ := completionWord(line)
+		var res []string
+		for _, c := range []string{"file one", "file_two", "dir/"} {
+			if strings.HasPrefix(c, word) {
+				res = append(res, c)
+			}
+		}
+		return start, res
+	}
+	testCases := []struct {
+		name string
+		keys string
+		want []string
+	}{
+		{"ввод", "abc\r", []string{"abc"}},
+		{"курсор", "ac\x1b[DB\x01>\x05<\r", []string{">aBc<"}},
+		{"удаление", "abcd\x7f\x02\x02\x04\x1b[3~\r", []string{"a"}},
+		{"kill и yank", "one two\x17three \x19\x01\x0b\x19\x19\r", []string{"one three twoone three two"}},
+		{"слова", "aa bb cc\x1bb\x1bb\x1bd\x1b[1;5C!\r", []string{"aa  cc!"}},
+		{"ctrl-u", "abc\x02\x15x\r", []string{"xc"}},
+		{"ctrl-c", "abc\x03def\r", []string{"", "def"}},
+		{"история", "new\x1b[A\x1b[A\x1b[B\x1b[B\x10\r", []string{"ls -l"}},
+		{"возврат новой строки", "new\x1b[A\x1b[B!\r", []string{"new!"}},
+		{"поиск", "\x12ech\r", []string{"echo hello"}},
+		{"поиск дальше", "\x12l\x12\x1b[C!\x05?\r", []string{"echo hel!lo?"}},
+		{"поиск отмена", "x\x12zzz\x07y\r", []string{"xy"}},
+		{"дополнение", "cat d\t\r", []string{"cat dir/"}},
+		{"одно дополнение", "cat file\\ \t\r", []string{`cat file\ one `}},
+		{"общее начало", "cat f\t\t\r", []string{"cat file"}},
+		{"utf-8", "привет\x02\x7fы\r", []string{"привыт"}},
+		{"конец ввода", "abc", []string{"abc"}},
+	}
+	for _, tc := range testCases {
+		e := &LineEditor{
+			in:       bufio.NewReader(strings.NewReader(tc.keys)),
+			out:      io.Discard,
+			fd:       -1,
+			history:  h,
+			complete: complete,
+		}
+		var got []string
+		for {
+			line, err := e.ReadLine("$ ")
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: err should be nil: %s", tc.name, err)
+			}
+			got = append(got, line)
+		}
+		if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+			t.Errorf("%s: got %q; want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -13,34 +14,58 @@ import (
 
 // Настраиваем команды
 func SetupCmds(intr *Interpreter) {
-	intr.AddCmd("exit", &exit{})
+	intr.AddCmd("exit", &exit{intr: intr})
 	intr.AddCmd("cd", &cd{})
 	intr.AddCmd("pwd", &pwd{})
 	intr.AddCmd("echo", &echo{})
 	intr.AddCmd("kill", &kill{})
 	intr.AddCmd("ps", &ps{})
-
+	intr.AddCmd("jobs", &jobs{table: intr.jobs})
+	intr.AddCmd("fg", &fg{intr: intr})
+	intr.AddCmd("bg", &bg{table: intr.jobs})
+	intr.AddCmd("wait", &wait{table: intr.jobs})
+	intr.AddCmd("set", &set{intr: intr})
+	intr.AddCmd("export", &export{vars: intr.vars})
+	intr.AddCmd("unset", &unset{vars: intr.vars})
+	intr.AddCmd("history", &history{hist: intr.history})
 }
 
-// Команда exit
-type exit struct{}
+// Команда exit [n]
+// Без аргумента выходит с кодом последней команды
+type exit struct {
+	intr *Interpreter
+}
 
-func (_ *exit) Exec(r io.Reader, w io.Writer, args []string) int {
-	os.Exit(0)
-	return 0
+func (e *exit) Exec(r io.Reader, w, errw io.Writer, args []string) int {
+	status := e.intr.Status()
+	switch len(args) {
+	case 1:
+	case 2:
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Fprintf(errw, "%s: %s: нужен числовой аргумент\n", args[0], args[1])
+			return 2
+		}
+		status = n & 0xff
+	default:
+		fmt.Fprintf(errw, "usage: %s [n]\n", args[0])
+		return 2
+	}
+	os.Exit(status)
+	return status
 }
 
 // Команда cd <args>
 type cd struct{}
 
-func (_ *cd) Exec(r io.Reader, w io.Writer, args []string) int {
+func (_ *cd) Exec(r io.Reader, w, errw io.Writer, args []string) int {
 	if len(args) != 2 {
-		fmt.Fprintf(os.Stderr, "usage: %s <path>\n", args[0])
+		fmt.Fprintf(errw, "usage: %s <path>\n", args[0])
 		return 2
 	}
 
 	if err := os.Chdir(args[1]); err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(errw, err)
 		return 1
 	}
 
@@ -50,11 +75,11 @@ func (_ *cd) Exec(r io.Reader, w io.Writer, args []string) int {
 // Команда pwd
 type pwd struct{}
 
-func (_ *pwd) Exec(r io.Reader, w io.Writer, args []string) int {
+func (_ *pwd) Exec(r io.Reader, w, errw io.Writer, args []string) int {
 	if wd, err := os.Getwd(); err == nil {
 		fmt.Fprintln(w, wd)
 	} else {
-		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(errw, err)
 		return 1
 	}
 
@@ -64,10 +89,10 @@ func (_ *pwd) Exec(r io.Reader, w io.Writer, args []string) int {
 // Команда echo <args>
 type echo struct{}
 
-func (_ *echo) Exec(r io.Reader, w io.Writer, args []string) int {
+func (_ *echo) Exec(r io.Reader, w, errw io.Writer, args []string) int {
 	s := strings.Join(args[1:], " ") + "\n"
 	if _, err := w.Write([]byte(s)); err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(errw, err)
 		return 1
 	}
 	return 0
@@ -76,13 +101,13 @@ func (_ *echo) Exec(r io.Reader, w io.Writer, args []string) int {
 // Команда kill <sig> <pid>
 type kill struct{}
 
-func (k *kill) ArgsFatal(args []string) int {
-	fmt.Fprintf(os.Stderr, "usage: %s TERM|KILL <pid>\n", args[0])
+func (k *kill) ArgsFatal(errw io.Writer, args []string) int {
+	fmt.Fprintf(errw, "usage: %s TERM|KILL <pid>\n", args[0])
 	return 2
 }
-func (k *kill) Exec(r io.Reader, w io.Writer, args []string) int {
+func (k *kill) Exec(r io.Reader, w, errw io.Writer, args []string) int {
 	if len(args) != 3 {
-		return k.ArgsFatal(args)
+		return k.ArgsFatal(errw, args)
 	}
 	var sig syscall.Signal
 	switch args[1] {
@@ -91,17 +116,17 @@ func (k *kill) Exec(r io.Reader, w io.Writer, args []string) int {
 	case "KILL":
 		sig = syscall.SIGKILL
 	default:
-		fmt.Fprintln(os.Stderr, "wrong sig")
-		return k.ArgsFatal(args)
+		fmt.Fprintln(errw, "wrong sig")
+		return k.ArgsFatal(errw, args)
 	}
 
 	pid, err := strconv.Atoi(args[2])
 	if err != nil {
-		return k.ArgsFatal(args)
+		return k.ArgsFatal(errw, args)
 	}
 
 	if err := syscall.Kill(pid, sig); err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(errw, err)
 		return 1
 	}
 	return 0
@@ -128,10 +153,10 @@ func (p *ps) setStats(n *proc) error {
 	return nil
 }
 
-func (p *ps) Exec(r io.Reader, w io.Writer, args []string) int {
+func (p *ps) Exec(r io.Reader, w, errw io.Writer, args []string) int {
 	entries, err := os.ReadDir("/proc/")
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(errw, err)
 		return 1
 	}
 
@@ -157,16 +182,233 @@ func (p *ps) Exec(r io.Reader, w io.Writer, args []string) int {
 	// Теперь выведем их
 	_, err = fmt.Fprintf(w, "%6s %7s %8s\n", "PID", "STATE", "NAME")
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(errw, err)
 		return 1
 	}
 	for _, proc := range procs {
 		_, err := fmt.Fprintf(w, "%-10d %c    %s\n", proc.pid, proc.state, proc.name)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(errw, err)
 			return 1
 		}
 	}
 
 	return 0
 }
+
+// Команда jobs
+type jobs struct {
+	table *JobTable
+}
+
+func (j *jobs) Exec(r io.Reader, w, errw io.Writer, args []string) int {
+	j.table.Reap()
+	j.table.List(w)
+	return 0
+}
+
+// Команда fg [%n]
+type fg struct {
+	intr *Interpreter
+}
+
+func (f *fg) Exec(r io.Reader, w, errw io.Writer, args []string) int {
+	if len(args) > 2 {
+		fmt.Fprintf(errw, "usage: %s [%%n]\n", args[0])
+		return 2
+	}
+	job, err := f.intr.jobs.Find(strings.Join(args[1:], ""))
+	if err != nil {
+		fmt.Fprintf(errw, "%s: %s\n", args[0], err)
+		return 1
+	}
+	fmt.Fprintln(w, job.Cmd)
+	state, err := f.intr.foreground(job, true)
+	if err != nil {
+		fmt.Fprintln(errw, err)
+		return 1
+	}
+	if state == JobStopped {
+		fmt.Fprintf(errw, "\n[%d]+  Stopped    %s\n", job.Id, job.Cmd)
+		return 128 + int(syscall.SIGTSTP)
+	}
+	return job.Status()
+}
+
+// Команда bg [%n]
+type bg struct {
+	table *JobTable
+}
+
+func (b *bg) Exec(r io.Reader, w, errw io.Writer, args []string) int {
+	if len(args) > 2 {
+		fmt.Fprintf(errw, "usage: %s [%%n]\n", args[0])
+		return 2
+	}
+	job, err := b.table.Find(strings.Join(args[1:], ""))
+	if err != nil {
+		fmt.Fprintf(errw, "%s: %s\n", args[0], err)
+		return 1
+	}
+	if b.table.State(job) != JobStopped {
+		fmt.Fprintf(errw, "%s: задание %d уже в фоне\n", args[0], job.Id)
+		return 0
+	}
+	if err := b.table.Continue(job); err != nil {
+		fmt.Fprintln(errw, err)
+		return 1
+	}
+	fmt.Fprintf(w, "[%d]  %s &\n", job.Id, job.Cmd)
+	return 0
+}
+
+// Команда wait [%n...]
+// Без аргументов ждет все фоновые задания
+type wait struct {
+	table *JobTable
+}
+
+func (wt *wait) Exec(r io.Reader, w, errw io.Writer, args []string) int {
+	var list []*Job
+	if len(args) == 1 {
+		list = wt.table.Running()
+	}
+	for _, spec := range args[1:] {
+		job, err := wt.table.Find(spec)
+		if err != nil {
+			fmt.Fprintf(errw, "%s: %s\n", args[0], err)
+			return 127
+		}
+		list = append(list, job)
+	}
+
+	status := 0
+	for _, job := range list {
+		if wt.table.Wait(job) == JobStopped {
+			status = 128 + int(syscall.SIGTSTP)
+			continue
+		}
+		status = job.Status()
+	}
+	return status
+}
+
+// Команда set -o|+o [option]
+// Без имени опции выводит все опции
+type set struct {
+	intr *Interpreter
+}
+
+func (s *set) Exec(r io.Reader, w, errw io.Writer, args []string) int {
+	if len(args) == 1 || len(args) > 3 || (args[1] != "-o" && args[1] != "+o") {
+		fmt.Fprintf(errw, "usage: %s -o|+o [option]\n", args[0])
+		return 2
+	}
+	if len(args) == 2 {
+		names := make([]string, 0, len(s.intr.options))
+		for name := range s.intr.options {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			state := "off"
+			if s.intr.options[name] {
+				state = "on"
+			}
+			fmt.Fprintf(w, "%-15s %s\n", name, state)
+		}
+		return 0
+	}
+	if _, ok := s.intr.options[args[2]]; !ok {
+		fmt.Fprintf(errw, "%s: %s: неизвестная опция\n", args[0], args[2])
+		return 2
+	}
+	s.intr.options[args[2]] = args[1] == "-o"
+	return 0
+}
+
+// Команда export [NAME[=value]...]
+// Без аргументов выводит экспортированные переменные
+type export struct {
+	vars *Vars
+}
+
+func (e *export) Exec(r io.Reader, w, errw io.Writer, args []string) int {
+	if len(args) == 1 {
+		for _, kv := range e.vars.Environ() {
+			name, value := splitAssign(kv)
+			fmt.Fprintf(w, "export %s=%s\n", name, shellQuote(value))
+		}
+		return 0
+	}
+	status := 0
+	for _, arg := range args[1:] {
+		name, value, set := strings.Cut(arg, "=")
+		if !isAssignment(Word{Value: name + "="}) {
+			fmt.Fprintf(errw, "%s: %s: неверное имя\n", args[0], name)
+			status = 1
+			continue
+		}
+		if err := e.vars.Export(name, value, set); err != nil {
+			fmt.Fprintln(errw, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// Значение в одинарных кавычках, которое шелл прочитает обратно как есть
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Команда unset NAME...
+type unset struct {
+	vars *Vars
+}
+
+func (u *unset) Exec(r io.Reader, w, errw io.Writer, args []string) int {
+	status := 0
+	for _, name := range args[1:] {
+		if err := u.vars.Unset(name); err != nil {
+			fmt.Fprintln(errw, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// Команда history [-c] [n]
+// Выводит историю с номерами для !n, n - только последние n строк.
+// -c очищает историю
+type history struct {
+	hist *History
+}
+
+func (h *history) Exec(r io.Reader, w, errw io.Writer, args []string) int {
+	from := 1
+	switch {
+	case len(args) == 1:
+	case len(args) == 2 && args[1] == "-c":
+		if err := h.hist.Clear(); err != nil {
+			fmt.Fprintf(errw, "%s: %s\n", args[0], err)
+			return 1
+		}
+		return 0
+	case len(args) == 2:
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			fmt.Fprintf(errw, "%s: %s: нужен числовой аргумент\n", args[0], args[1])
+			return 2
+		}
+		from = max(1, h.hist.Len()-n+1)
+	default:
+		fmt.Fprintf(errw, "usage: %s [-c] [n]\n", args[0])
+		return 2
+	}
+	for n := from; n <= h.hist.Len(); n++ {
+		line, _ := h.hist.Get(n)
+		fmt.Fprintf(w, "%5d  %s\n", n, line)
+	}
+	return 0
+}
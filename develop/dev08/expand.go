@@ -0,0 +1,285 @@
+package main
+
+import (
+	"strings"
+)
+
+// Как байт слова был записан в командной строке
+const (
+	qNone        byte = iota // Без кавычек: раскрывается и делится на поля
+	qDouble                  // В двойных кавычках: раскрывается, но не делится
+	qSingle                  // В одинарных кавычках или после \: берется как есть
+	qSubst                   // Начало подстановки команды $(...) или `...` без кавычек
+	qSubstDouble             // Начало подстановки команды в двойных кавычках
+	qSubstBody               // Остальные байты подстановки команды
+)
+
+// Слово командной строки до раскрытия
+type Word struct {
+	Value  string // Слово без кавычек
+	Quote  []byte // Как был записан каждый байт Value, nil - все без кавычек
+	Quoted bool   // В слове были кавычки, поэтому даже пустое оно дает поле
+}
+
+func (w Word) quote(i int) byte {
+	if w.Quote == nil {
+		return qNone
+	}
+	return w.Quote[i]
+}
+
+// Есть ли в слове байты в кавычках
+func (w Word) hasQuotes() bool {
+	for _, q := range w.Quote {
+		if q == qDouble || q == qSingle {
+			return true
+		}
+	}
+	return false
+}
+
+// Нужно ли что-то раскрывать в слове: $ вне одинарных кавычек,
+// подстановка команды или символы шаблонов, тильда и фигурные скобки без кавычек
+func (w Word) needsExpand() bool {
+	for i := 0; i < len(w.Value); i++ {
+		switch q := w.quote(i); {
+		case w.Value[i] == '$' && q != qSingle, q == qSubst, q == qSubstDouble:
+			return true
+		case q == qNone && strings.IndexByte("*?[~{", w.Value[i]) >= 0:
+			return true
+		}
+	}
+	return false
+}
+
+// Значение переменной по имени, ok - переменная задана
+type lookupFunc func(name string) (value string, ok bool)
+
+// Выполняет команду подстановки и возвращает ее вывод
+type substFunc func(cmd string) string
+
+// Символы, разделяющие поля при раскрытии без кавычек
+const ifs = " \t\n"
+
+// Раскрывает слово так же, как sh, по порядку: фигурные скобки {a,b},
+// тильду ~ и ~user, параметры $NAME, ${NAME} и ${NAME:-word}, подстановки
+// команд $(cmd) и `cmd`, деление на поля и шаблоны имен файлов *, ? и [...].
+// Поэтому слово может дать несколько полей или ни одного.
+// Если subst равен nil, подстановки команд раскрываются в пустую строку
+func expandWord(w Word, lookup lookupFunc, subst substFunc) []string {
+	var res []string
+	for _, b := range braces(w) {
+		for _, f := range expand(tilde(b, lookup), lookup, subst, true) {
+			res = append(res, glob(f)...)
+		}
+	}
+	return res
+}
+
+// Раскрывает параметры и подстановки команд в слове в одну строку
+// без деления на поля, как в NAME=value
+func expandString(w Word, lookup lookupFunc, subst substFunc) string {
+	var res strings.Builder
+	for _, f := range expand(w, lookup, subst, false) {
+		res.WriteString(f.Value)
+	}
+	return res.String()
+}
+
+// Раскрывает параметры и подстановки команд. Байты полей помечены
+// так же, как в слове, значение без кавычек - qNone, чтобы потом
+// раскрылись шаблоны
+func expand(w Word, lookup lookupFunc, subst substFunc, split bool) []Word {
+	var fields []Word
+	var cur wordBuilder
+	have := w.Quoted // Поле начато, даже если оно пустое
+	next := func() {
+		f := cur.take()
+		if have {
+			fields = append(fields, f)
+		}
+		have = false
+	}
+
+	for i := 0; i < len(w.Value); i++ {
+		c, q := w.Value[i], w.quote(i)
+		var value string
+		quoted := false // Значение не делится на поля
+		switch {
+		case q == qSubst || q == qSubstDouble:
+			end := i + 1
+			for end < len(w.Value) && w.quote(end) == qSubstBody {
+				end++
+			}
+			value = cmdSubst(w.Value[i:end], subst)
+			quoted = q == qSubstDouble
+			i = end - 1
+		case c == '$' && q != qSingle:
+			expr, n := paramExpr(w, i+1)
+			if n == 0 {
+				// После $ нет имени, это обычный символ
+				cur.add(c, q)
+				have = true
+				continue
+			}
+			i += n
+			value, quoted = paramValue(expr, lookup, subst)
+			quoted = quoted || q == qDouble
+		default:
+			cur.add(c, q)
+			have = true
+			continue
+		}
+		if quoted || !split {
+			cur.addString(value, qDouble)
+			have = have || quoted || value != ""
+			continue
+		}
+		// Деление на поля
+		for j, part := range strings.FieldsFunc(value, isIFS) {
+			if j > 0 || strings.IndexByte(ifs, value[0]) >= 0 {
+				next()
+			}
+			cur.addString(part, qNone)
+			have = true
+		}
+		if value != "" && strings.IndexByte(ifs, value[len(value)-1]) >= 0 {
+			next()
+		}
+	}
+	next()
+	return fields
+}
+
+// Вывод подстановки команды $(cmd) или `cmd` без переводов строки в конце
+func cmdSubst(text string, subst substFunc) string {
+	if subst == nil {
+		return ""
+	}
+	var cmd string
+	if text[0] == '`' {
+		// Внутри `...` \ экранирует только $, ` и \
+		var b strings.Builder
+		body := text[1 : len(text)-1]
+		for i := 0; i < len(body); i++ {
+			if body[i] == '\\' && i+1 < len(body) && strings.IndexByte("$`\\", body[i+1]) >= 0 {
+				i++
+			}
+			b.WriteByte(body[i])
+		}
+		cmd = b.String()
+	} else {
+		cmd = text[2 : len(text)-1]
+	}
+	return strings.TrimRight(subst(cmd), "\n")
+}
+
+// Часть слова с from по to
+func (w Word) sub(from, to int) Word {
+	res := Word{Value: w.Value[from:to]}
+	if w.Quote != nil {
+		res.Quote = w.Quote[from:to]
+	}
+	return res
+}
+
+// Выражение параметра после $ в позиции i: NAME, {...} или один из
+// специальных ?, $, #, 0-9. Возвращает выражение без скобок и сколько
+// байт заняла запись, 0 - имени нет. Имя должно быть записано так же, как $
+func paramExpr(w Word, i int) (Word, int) {
+	s := w.Value
+	if i >= len(s) || w.quote(i) != w.quote(i-1) {
+		return Word{}, 0
+	}
+	switch c := s[i]; {
+	case c == '{':
+		// Ищем парную }, внутри могут быть вложенные ${...}
+		depth := 0
+		for j := i + 1; j < len(s); j++ {
+			if q := w.quote(j); q != qNone && q != qDouble {
+				continue
+			}
+			switch {
+			case s[j] == '{' && s[j-1] == '$':
+				depth++
+			case s[j] == '}' && depth > 0:
+				depth--
+			case s[j] == '}':
+				return w.sub(i+1, j), j - i + 1
+			}
+		}
+		return Word{}, 0
+	case c == '?' || c == '$' || c == '#' || (c >= '0' && c <= '9'):
+		return w.sub(i, i+1), 1
+	case isNameStart(c):
+		j := i + 1
+		for j < len(s) && isNameChar(s[j]) && w.quote(j) == w.quote(i) {
+			j++
+		}
+		return w.sub(i, j), j - i
+	}
+	return Word{}, 0
+}
+
+// Значение выражения параметра: NAME, NAME[i] и операторы
+// :- и - (значение по умолчанию), :+ и + (альтернативное значение).
+// Двоеточие означает, что пустая переменная считается незаданной.
+// quoted - значение взято из слова с кавычками и не делится на поля
+func paramValue(expr Word, lookup lookupFunc, subst substFunc) (value string, quoted bool) {
+	s := expr.Value
+	n := 0
+	if n < len(s) && !isNameStart(s[n]) {
+		// Специальный параметр из одного символа
+		n = 1
+	}
+	for n < len(s) && isNameChar(s[n]) {
+		n++
+	}
+	// Индекс массива: PIPESTATUS[1]
+	if n < len(s) && s[n] == '[' {
+		if end := strings.IndexByte(s[n:], ']'); end > 0 {
+			n += end + 1
+		}
+	}
+	value, set := lookup(s[:n])
+	rest := s[n:]
+	if rest == "" {
+		return value, false
+	}
+
+	op := rest[:1]
+	if op == ":" && len(rest) > 1 {
+		op = rest[:2]
+		set = set && value != ""
+	}
+	word := expr.sub(n+len(op), len(s))
+	alt := func() (string, bool) {
+		return expandString(word, lookup, subst), word.hasQuotes()
+	}
+	switch op {
+	case ":-", "-":
+		if !set {
+			return alt()
+		}
+		return value, false
+	case ":+", "+":
+		if set {
+			return alt()
+		}
+		return "", false
+	}
+	// Неизвестный оператор раскрывается в пустую строку
+	return "", false
+}
+
+func isIFS(r rune) bool {
+	return strings.ContainsRune(ifs, r)
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
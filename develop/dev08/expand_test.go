@@ -0,0 +1,228 @@
+package main
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExpandWord(t *testing.T) {
+	vars := map[string]string{
+		"?":     "1",
+		"A":     "a",
+		"SP":    " x  y ",
+		"EMPTY": "",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+	testCases := []struct {
+		has  string
+		want []string
+	}{
+		{`$?`, []string{"1"}},
+		{`'$?' "$?" \$?`, []string{"$?", "1", "$?"}},
+		{`$A${A}b "${A}b" $Ab`, []string{"aab", "ab"}},
+		{`$SP`, []string{"x", "y"}},
+		{`"$SP"`, []string{" x  y "}},
+		{`1${SP}2`, []string{"1", "x", "y", "2"}},
+		{`$EMPTY "$EMPTY" ''$EMPTY $NONE`, []string{"", ""}},
+		{`$ a$ $- "$"'A'`, []string{"$", "a$", "$-", "$A"}},
+		{`"$"A`, []string{"$A"}},
+		{`${NONE:-$SP} ${NONE:-"x y"} "${NONE:-x y}"`, []string{"x", "y", "x y", "x y"}},
+		{`${EMPTY:-d} ${EMPTY-d} ${A:-d} ${NONE-$A}`, []string{"d", "a", "a"}},
+		{`${A:+alt} ${EMPTY:+alt} ${EMPTY+alt} ${NONE+alt}`, []string{"alt", "alt"}},
+		{`${NONE:-${NONE2:-$A}}b '${A}'`, []string{"ab", "${A}"}},
+	}
+	for _, tc := range testCases {
+		tokens, err := lex(tc.has)
+		if err != nil {
+			t.Fatalf("%s: err should be nil: %s", tc.has, err)
+		}
+		var got []string
+		for _, tok := range tokens {
+			got = append(got, expandWord(tok.word, lookup, nil)...)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q; want %q", tc.has, got, tc.want)
+		}
+	}
+}
+
+func TestExpandString(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		if name == "SP" {
+			return " x  y ", true
+		}
+		return "", false
+	}
+	tokens, err := lex(`A=$SP${NONE:-"d"}`)
+	if err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	if got, want := expandString(tokens[0].word, lookup, nil), "A= x  y d"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestExpandSubst(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		if name == "A" {
+			return "a", true
+		}
+		return "", false
+	}
+	// Вместо выполнения команда возвращается в скобках
+	subst := func(cmd string) string {
+		if cmd == "sp" {
+			return " x  y \n\n"
+		}
+		return "[" + cmd + "]\n"
+	}
+	testCases := []struct {
+		has  string
+		want []string
+	}{
+		{`$(echo $A) "$(echo "b c")"`, []string{"[echo", "$A]", "[echo \"b c\"]"}},
+		{`$(sp) "$(sp)" x$(sp)z`, []string{"x", "y", " x  y ", "x", "x", "y", "z"}},
+		{"`echo \\$A \\\\ \\a` '$(no)'", []string{"[echo", "$A", "\\", "\\a]", "$(no)"}},
+		{`$(a)$(b) ${NONE:-$(sp)} A=$(sp)`, []string{"[a][b]", "x", "y", "A=", "x", "y"}},
+	}
+	for _, tc := range testCases {
+		tokens, err := lex(tc.has)
+		if err != nil {
+			t.Fatalf("%s: err should be nil: %s", tc.has, err)
+		}
+		var got []string
+		for _, tok := range tokens {
+			got = append(got, expandWord(tok.word, lookup, subst)...)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q; want %q", tc.has, got, tc.want)
+		}
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		if name == "A" {
+			return "x,y", true
+		}
+		return "", false
+	}
+	testCases := []struct {
+		has  string
+		want []string
+	}{
+		{`a{b,c}d`, []string{"abd", "acd"}},
+		{`{a,b}{1,2}`, []string{"a1", "a2", "b1", "b2"}},
+		{`{a,{b,c}x}`, []string{"a", "bx", "cx"}},
+		{`{,a}`, []string{"a"}},
+		{`"{"a,b} '{a,b}' {a\,b} {a}`, []string{"{a,b}", "{a,b}", "{a,b}", "{a}"}},
+		{`{$A,z} ${A} {a,b`, []string{"x,y", "z", "x,y", "{a,b"}},
+	}
+	for _, tc := range testCases {
+		tokens, err := lex(tc.has)
+		if err != nil {
+			t.Fatalf("%s: err should be nil: %s", tc.has, err)
+		}
+		var got []string
+		for _, tok := range tokens {
+			got = append(got, expandWord(tok.word, lookup, nil)...)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q; want %q", tc.has, got, tc.want)
+		}
+	}
+}
+
+func TestExpandTilde(t *testing.T) {
+	root, err := user.Lookup("root")
+	if err != nil {
+		t.Skip("нет пользователя root")
+	}
+	lookup := func(name string) (string, bool) {
+		if name == "HOME" {
+			return "/home/a b", true
+		}
+		return "", false
+	}
+	testCases := []struct {
+		has  string
+		want []string
+	}{
+		{`~ ~/x a~`, []string{"/home/a b", "/home/a b/x", "a~"}},
+		{`'~' "~"/x \~`, []string{"~", "~/x", "~"}},
+		{`~root/x ~no_such_user_dev08`, []string{root.HomeDir + "/x", "~no_such_user_dev08"}},
+		{`{~,b}`, []string{"/home/a b", "b"}},
+	}
+	for _, tc := range testCases {
+		tokens, err := lex(tc.has)
+		if err != nil {
+			t.Fatalf("%s: err should be nil: %s", tc.has, err)
+		}
+		var got []string
+		for _, tok := range tokens {
+			got = append(got, expandWord(tok.word, lookup, nil)...)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q; want %q", tc.has, got, tc.want)
+		}
+	}
+}
+
+func TestExpandGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.txt", ".hidden.go", "*.go", "sub/d.go", "sub/e.txt", "other/f.go"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("err should be nil: %s", err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatalf("err should be nil: %s", err)
+		}
+	}
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	defer os.Chdir(wd)
+
+	lookup := func(name string) (string, bool) {
+		if name == "P" {
+			return "*.txt", true
+		}
+		return "", false
+	}
+	testCases := []struct {
+		has  string
+		want []string
+	}{
+		{`*.go`, []string{"*.go", "a.go", "b.go"}},
+		{`?.txt [ab].go [!ab].*`, []string{"c.txt", "a.go", "b.go", "*.go", "c.txt"}},
+		{`.*.go`, []string{".hidden.go"}},
+		{`*/*.go`, []string{"other/f.go", "sub/d.go"}},
+		{`s*/e.txt */`, []string{"sub/e.txt", "other/", "sub/"}},
+		{dir + `/sub/*.txt`, []string{dir + "/sub/e.txt"}},
+		{`"*".go '*'.go \*.go`, []string{"*.go", "*.go", "*.go"}},
+		{`*.none [ x*[`, []string{"*.none", "[", "x*["}},
+		{`$P "$P"`, []string{"c.txt", "*.txt"}},
+		{`{a,c}.*`, []string{"a.go", "c.txt"}},
+	}
+	for _, tc := range testCases {
+		tokens, err := lex(tc.has)
+		if err != nil {
+			t.Fatalf("%s: err should be nil: %s", tc.has, err)
+		}
+		var got []string
+		for _, tok := range tokens {
+			got = append(got, expandWord(tok.word, lookup, nil)...)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q; want %q", tc.has, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"os"
+	"os/user"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Склеивает слова, сохраняя кавычки каждого байта
+func joinWords(ws ...Word) Word {
+	var b wordBuilder
+	for _, w := range ws {
+		for i := 0; i < len(w.Value); i++ {
+			b.add(w.Value[i], w.quote(i))
+		}
+	}
+	return b.take()
+}
+
+// Раскрывает фигурные скобки: a{b,c}d дает abd и acd, скобки могут быть
+// вложенными. Скобки без запятой, в кавычках и ${...} остаются как есть
+func braces(w Word) []Word {
+	s := w.Value
+	for i := 0; i < len(s); i++ {
+		if s[i] != '{' || w.quote(i) != qNone {
+			continue
+		}
+		if i > 0 && s[i-1] == '$' && w.quote(i-1) == qNone {
+			// ${...} - это параметр
+			if _, n := paramExpr(w, i); n > 0 {
+				i += n - 1
+			}
+			continue
+		}
+		bounds := braceBounds(w, i)
+		if len(bounds) < 3 {
+			continue
+		}
+		prefix, suffix := w.sub(0, i), w.sub(bounds[len(bounds)-1]+1, len(s))
+		var res []Word
+		for j := 1; j < len(bounds); j++ {
+			alt := w.sub(bounds[j-1]+1, bounds[j])
+			// Следующие скобки могут быть и в варианте, и после него
+			for _, rest := range braces(joinWords(alt, suffix)) {
+				word := joinWords(prefix, rest)
+				word.Quoted = w.Quoted
+				res = append(res, word)
+			}
+		}
+		return res
+	}
+	return []Word{w}
+}
+
+// Позиции { в i, запятых верхнего уровня и парной }. Если парной
+// скобки нет, возвращает nil
+func braceBounds(w Word, i int) []int {
+	s := w.Value
+	bounds := []int{i}
+	depth := 0
+	for j := i + 1; j < len(s); j++ {
+		if w.quote(j) != qNone {
+			continue
+		}
+		switch s[j] {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return append(bounds, j)
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				bounds = append(bounds, j)
+			}
+		}
+	}
+	return nil
+}
+
+// Раскрывает ~ в начале слова в домашний каталог из $HOME,
+// а ~user - в домашний каталог пользователя user. Каталог
+// берется как есть, без дальнейшего раскрытия
+func tilde(w Word, lookup lookupFunc) Word {
+	s := w.Value
+	if s == "" || s[0] != '~' || w.quote(0) != qNone {
+		return w
+	}
+	end := strings.IndexByte(s, '/')
+	if end < 0 {
+		end = len(s)
+	}
+	for i := 1; i < end; i++ {
+		if w.quote(i) != qNone {
+			return w
+		}
+	}
+	var home string
+	if name := s[1:end]; name == "" {
+		var ok bool
+		if home, ok = lookup("HOME"); !ok {
+			return w
+		}
+	} else {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return w
+		}
+		home = u.HomeDir
+	}
+	var b wordBuilder
+	b.addString(home, qSingle)
+	res := joinWords(b.take(), w.sub(end, len(s)))
+	res.Quoted = w.Quoted
+	return res
+}
+
+// Раскрывает шаблон имени файла из *, ? и [...] без кавычек.
+// Если в поле нет шаблона или подходящих файлов нет, поле остается как есть.
+// Файлы, начинающиеся с точки, подходят, только если точка есть в шаблоне
+func glob(w Word) []string {
+	pattern, ok := globPattern(w)
+	if !ok {
+		return []string{w.Value}
+	}
+	matches := globPath(pattern)
+	if len(matches) == 0 {
+		return []string{w.Value}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// Шаблон для path.Match, в котором символы в кавычках экранированы.
+// ok - в шаблоне есть *, ? или [ без кавычек
+func globPattern(w Word) (pattern string, ok bool) {
+	var b strings.Builder
+	s := w.Value
+	for i := 0; i < len(s); i++ {
+		c, q := s[i], w.quote(i)
+		switch {
+		case q == qNone && (c == '*' || c == '?' || c == '['):
+			ok = true
+			b.WriteByte(c)
+			// [!...] в sh - то же, что [^...] в path.Match
+			if c == '[' && i+1 < len(s) && s[i+1] == '!' && w.quote(i+1) == qNone {
+				b.WriteByte('^')
+				i++
+			}
+		case c == '\\' || (q != qNone && strings.IndexByte("*?[]", c) >= 0):
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), ok
+}
+
+// Есть ли в части пути неэкранированные *, ? или [
+func hasMeta(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+// Убирает экранирование из части пути без шаблона
+func unescape(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			i++
+		}
+		b.WriteByte(pattern[i])
+	}
+	return b.String()
+}
+
+// Путь dir/name, пустой dir - текущий каталог
+func joinPath(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	if strings.HasSuffix(dir, "/") {
+		return dir + name
+	}
+	return dir + "/" + name
+}
+
+// Находит файлы, подходящие под шаблон, по одной части пути за раз
+func globPath(pattern string) []string {
+	dirs := []string{""}
+	if strings.HasPrefix(pattern, "/") {
+		dirs = []string{"/"}
+		pattern = strings.TrimLeft(pattern, "/")
+	}
+	parts := strings.Split(pattern, "/")
+	for i, part := range parts {
+		last := i == len(parts)-1
+		var next []string
+		for _, dir := range dirs {
+			if !hasMeta(part) {
+				p := joinPath(dir, unescape(part))
+				if last {
+					// Последняя часть без шаблона: файл должен существовать
+					if _, err := os.Lstat(p); err != nil {
+						continue
+					}
+				}
+				next = append(next, p)
+				continue
+			}
+			readDir := dir
+			if readDir == "" {
+				readDir = "."
+			}
+			entries, err := os.ReadDir(readDir)
+			if err != nil {
+				continue
+			}
+			for _, e := range entries {
+				name := e.Name()
+				if name[0] == '.' && part[0] != '.' {
+					continue
+				}
+				matched, err := path.Match(part, name)
+				if err != nil {
+					return nil
+				}
+				if matched && (last || e.IsDir() || e.Type()&os.ModeSymlink != 0) {
+					next = append(next, joinPath(dir, name))
+				}
+			}
+		}
+		dirs = next
+	}
+	return dirs
+}
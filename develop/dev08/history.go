@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Сколько строк истории хранится по умолчанию
+const DefaultHistorySize = 1000
+
+// Строки не найдено для !n
+var ErrNoEvent = errors.New("событие не найдено")
+
+// История введенных команд. Если задан файл, каждая новая
+// команда сразу дописывается в него
+type History struct {
+	lines []string
+	max   int
+	path  string // Файл истории, пустой - история не сохраняется
+}
+
+func NewHistory(max int) *History {
+	return &History{max: max}
+}
+
+// Загружает историю из файла и дальше сохраняет ее туда же.
+// Отсутствующий файл - пустая история
+func (h *History) Load(path string) error {
+	h.path = path
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scn := bufio.NewScanner(f)
+	total := 0
+	for scn.Scan() {
+		if line := scn.Text(); strings.TrimSpace(line) != "" {
+			h.lines = append(h.lines, line)
+			total++
+		}
+	}
+	if err := scn.Err(); err != nil {
+		return err
+	}
+	h.trim()
+	// Файл перезаписывается, только когда он вырос больше max
+	if total > len(h.lines) {
+		return h.save()
+	}
+	return nil
+}
+
+// Оставляет только последние max строк
+func (h *History) trim() {
+	if h.max > 0 && len(h.lines) > h.max {
+		h.lines = append([]string(nil), h.lines[len(h.lines)-h.max:]...)
+	}
+}
+
+func (h *History) save() error {
+	var b strings.Builder
+	for _, line := range h.lines {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return os.WriteFile(h.path, []byte(b.String()), 0o600)
+}
+
+// Добавляет команду в историю. Пустые строки и повтор
+// предыдущей команды не добавляются
+func (h *History) Add(line string) error {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	if n := len(h.lines); n > 0 && h.lines[n-1] == line {
+		return nil
+	}
+	h.lines = append(h.lines, line)
+	h.trim()
+	if h.path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(f, line)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Удаляет всю историю, в том числе из файла
+func (h *History) Clear() error {
+	h.lines = nil
+	if h.path == "" {
+		return nil
+	}
+	return h.save()
+}
+
+func (h *History) Len() int {
+	return len(h.lines)
+}
+
+// Строка с номером n, нумерация с 1
+func (h *History) Get(n int) (string, bool) {
+	if n < 1 || n > len(h.lines) {
+		return "", false
+	}
+	return h.lines[n-1], true
+}
+
+// Заменяет в строке !! на предыдущую команду, !n - на команду с номером n,
+// !-n - на n-ю с конца. В одинарных кавычках и после \ ! не раскрывается.
+// changed - в строке что-то заменено
+func (h *History) Expand(line string) (res string, changed bool, err error) {
+	var b strings.Builder
+	single, double := false, false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && !single && i+1 < len(line):
+			b.WriteByte(c)
+			i++
+			b.WriteByte(line[i])
+			continue
+		case c == '\'' && !double:
+			single = !single
+		case c == '"' && !single:
+			double = !double
+		case c == '!' && !single && i+1 < len(line):
+			n, end, ok := h.event(line[i+1:])
+			if !ok {
+				break
+			}
+			ev, found := h.Get(n)
+			if !found {
+				return "", false, fmt.Errorf("%s: %w", line[i:i+1+end], ErrNoEvent)
+			}
+			b.WriteString(ev)
+			changed = true
+			i += end
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), changed, nil
+}
+
+// Номер команды для ссылки после !: !, n или -n.
+// end - длина ссылки, ok - после ! есть ссылка
+func (h *History) event(s string) (n, end int, ok bool) {
+	if s[0] == '!' {
+		return len(h.lines), 1, true
+	}
+	if s[0] == '-' {
+		end = 1
+	}
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	num, err := strconv.Atoi(s[:end])
+	if err != nil {
+		return 0, 0, false
+	}
+	if num < 0 {
+		num += len(h.lines) + 1
+	}
+	return num, end, true
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	if err := os.WriteFile(path, []byte("a\n\nb\nc\nd\n"), 0o600); err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	h := NewHistory(3)
+	if err := h.Load(path); err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	// Пустые строки пропускаются, лишние старые отбрасываются
+	if !reflect.DeepEqual(h.lines, []string{"b", "c", "d"}) {
+		t.Errorf("got %q; want %q", h.lines, []string{"b", "c", "d"})
+	}
+	for _, line := range []string{"d", " ", "e"} {
+		if err := h.Add(line); err != nil {
+			t.Fatalf("err should be nil: %s", err)
+		}
+	}
+	if got, _ := h.Get(3); h.Len() != 3 || got != "e" {
+		t.Errorf("last line should be e, got %q of %d", got, h.Len())
+	}
+
+	// Новая история из того же файла
+	h2 := NewHistory(10)
+	if err := h2.Load(path); err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	if !reflect.DeepEqual(h2.lines, []string{"b", "c", "d", "e"}) {
+		t.Errorf("got %q; want %q", h2.lines, []string{"b", "c", "d", "e"})
+	}
+	if err := h2.Clear(); err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	if b, _ := os.ReadFile(path); len(b) != 0 || h2.Len() != 0 {
+		t.Errorf("history should be empty, got %q", b)
+	}
+}
+
+func TestHistoryExpand(t *testing.T) {
+	h := NewHistory(10)
+	for _, line := range []string{"echo a", "ls -l", "pwd"} {
+		h.Add(line)
+	}
+	testCases := []struct {
+		has     string
+		want    string
+		changed bool
+		err     error
+	}{
+		{has: "!!", want: "pwd", changed: true},
+		{has: "!1 && !-2", want: "echo a && ls -l", changed: true},
+		{has: `"!!" '!!' \!! ! !x a!`, want: `"pwd" '!!' \!! ! !x a!`, changed: true},
+		{has: "echo b", want: "echo b"},
+		{has: "!4", err: ErrNoEvent},
+		{has: "!-5", err: ErrNoEvent},
+	}
+	for _, tc := range testCases {
+		got, changed, err := h.Expand(tc.has)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: got err %v; want %v", tc.has, err, tc.err)
+			continue
+		}
+		if got != tc.want || changed != tc.changed {
+			t.Errorf("%s: got %q %v; want %q %v", tc.has, got, changed, tc.want, tc.changed)
+		}
+	}
+}
@@ -8,6 +8,10 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 )
 
@@ -15,112 +19,103 @@ import (
 // которая хранит в себе информацию о том, что нужно сделать
 type Parser interface {
 	Parse() Entity
+	SetPrompt(prompt string) // Приглашение перед следующей командой
 }
 
 // Структура, которая представляет собой результат Parse.
 type Entity struct {
 	Cmds [][]string // Коамнды, которые нужно выполнить
-	Bg   bool       // Нужно ли запустить их в фоновом режиме
-	EOF  bool       // Если True, то это команда завершения работы
+	// Перенаправления для каждой команды из Cmds, nil - перенаправлений нет
+	Redirs [][]Redirect
+	// Слова каждой команды из Cmds до раскрытия, nil - раскрывать нечего
+	Words [][]Word
+	// Подоболочки ( ... ) вместо команд из Cmds, nil - подоболочек нет
+	Subs []*Entity
+	// Присваивания NAME=value перед каждой командой из Cmds, nil - присваиваний нет
+	Assigns [][]Word
+	// Список команд через ;, && и ||. Если не nil, то остальные поля,
+	// кроме EOF, не используются
+	List []ListItem
+	Bg   bool // Нужно ли запустить их в фоновом режиме
+	EOF  bool // Если True, то это команда завершения работы
 }
 
-// Представялет из себя встроенную команду
-type Executor interface {
-	Exec(r io.Reader, w io.Writer, args []string) int
-}
-
-type forkFunc func() int
+// Как элемент списка зависит от предыдущего
+type ListOp int
 
-// Делаем форк и вызываем функцию forkFunc
-// in -> forkFunc -> out
-// ...............\_ err
-func forkout(in, out, err *os.File, forkFunc forkFunc) (int, error) {
-	pid, _, errno := syscall.Syscall(syscall.SYS_FORK, 0, 0, 0)
-	if errno != 0 {
-		return 0, fmt.Errorf("can't fork: %d", errno)
-	}
-	if pid == 0 {
-		// child
-		// Заемняем stdin на in
-		err := syscall.Dup2(int(in.Fd()), int(os.Stdin.Fd()))
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
-		}
-		// Заемняем stdout на out
-		err = syscall.Dup2(int(out.Fd()), int(os.Stdout.Fd()))
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
-		}
+const (
+	OpSeq ListOp = iota // ; - выполняется всегда
+	OpAnd               // && - если предыдущий успешен
+	OpOr                // || - если предыдущий неуспешен
+)
 
-		exitStatus := forkFunc()
-		os.Exit(exitStatus)
-	}
-	return int(pid), nil
+// Элемент списка команд
+type ListItem struct {
+	Op ListOp
+	Entity
 }
 
-// Делаем форк и вызываем функцию forkFunc
-// in -> forkFunc -> out
-// ...............\_ err
-// создает pipe out
-func fork(in, errf *os.File, forkFunc forkFunc) (out *os.File, pid int, err error) {
-	// Создаем Pipe
-	r, w, err := os.Pipe()
-	if err != nil {
-		return nil, 0, err
-	}
+// Представялет из себя встроенную команду.
+// Ошибки пишутся в errw
+type Executor interface {
+	Exec(r io.Reader, w, errw io.Writer, args []string) int
+}
 
-	// Делаем fork
-	_pid, _, errno := syscall.Syscall(syscall.SYS_FORK, 0, 0, 0)
-	pid = int(_pid)
-	if errno != 0 {
-		return nil, 0, fmt.Errorf("can't fork: %d", errno)
-	}
-	if pid == 0 {
-		// child
-		// Закрываем не нужный для ребенка reader
-		r.Close()
-		// stdin заменяем на in
-		err := syscall.Dup2(int(in.Fd()), int(os.Stdin.Fd()))
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
-		}
-		// stdout заменяем на out
-		err = syscall.Dup2(int(w.Fd()), int(os.Stdout.Fd()))
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
-		}
+// Группа процессов, в которую попадают процессы конвейера
+type procGroup struct {
+	on   bool // Управление заданиями включено, иначе процессы в группе интерпретатора
+	pgid int  // 0 - первый процесс создаст новую группу
+	fg   bool // Группа получает терминал tty
+	tty  int
+}
 
-		exitStatus := forkFunc()
-		os.Exit(exitStatus)
+// Атрибуты exec.Cmd для входа в группу
+func (pg *procGroup) attr() *syscall.SysProcAttr {
+	if !pg.on {
+		return nil
 	}
-
-	// Закрываем не нужный для родителя writer
-	w.Close()
-
-	return r, int(pid), nil
+	return &syscall.SysProcAttr{Setpgid: true, Pgid: pg.pgid, Foreground: pg.fg, Ctty: pg.tty}
 }
 
-// Обворачивает Executor для fork
-func wrapExecForFork(e Executor, args []string) forkFunc {
-	return func() int {
-		return e.Exec(os.Stdin, os.Stdout, args)
+// Добавляет в группу запущенный процесс. setpgid делает и родитель,
+// чтобы группа была готова до того, как в нее войдет следующий процесс
+func (pg *procGroup) join(pid int) {
+	if !pg.on {
+		return
 	}
+	if pg.pgid == 0 {
+		pg.pgid = pid
+	}
+	_ = syscall.Setpgid(pid, pg.pgid)
 }
 
 // Отвечает за работу с парсером и управлениями командами
 type Interpreter struct {
 	parser   Parser
 	commands map[string]Executor
+	jobs     *JobTable
+	vars     *Vars
+	history  *History
+	tty      int // Терминал, -1 - управление заданиями выключено
+	pgid     int // Группа процессов интерпретатора
+
+	status      int             // Код возврата последнего конвейера, $?
+	substStatus int             // Код возврата последней подстановки команды
+	pipeStatus  []int           // Коды команд последнего конвейера, $PIPESTATUS
+	options     map[string]bool // Опции set -o
 }
 
 func NewInterpreter() *Interpreter {
 	var itrpr Interpreter
-	itrpr.parser = NewDefaultParser(os.Stdin)
+	parser := NewDefaultParser(os.Stdin)
+	parser.SetContinuationPrompt(os.Stdout, "> ")
+	itrpr.parser = parser
 	itrpr.commands = make(map[string]Executor)
+	itrpr.jobs = NewJobTable()
+	itrpr.vars = NewVars()
+	itrpr.history = NewHistory(DefaultHistorySize)
+	itrpr.tty = -1
+	itrpr.options = map[string]bool{"pipefail": false}
 	return &itrpr
 }
 
@@ -128,116 +123,451 @@ func (itrpr *Interpreter) AddCmd(name string, e Executor) {
 	itrpr.commands[name] = e
 }
 
-// Выполняем команду и дожидаемся ее исполнения
+// Команда конвейера после раскрытия
+type stage struct {
+	args    []string
+	redirs  []Redirect
+	sub     *Entity  // Подоболочка, тогда args пустые
+	assigns []string // NAME=value, окружение команды
+}
+
+// Нечего запускать, только перенаправления
+func (st stage) empty() bool {
+	return len(st.args) == 0 && st.sub == nil
+}
+
+func (st stage) name() string {
+	if st.sub != nil {
+		return "("
+	}
+	return st.args[0]
+}
+
+// Выполняем встроенную команду в процессе интерпретатора
 // in -> cmd -> stdout
 // ..........\_ stderr
-func (itrpr *Interpreter) ewait(in *os.File, args []string) error {
-	if len(args) == 0 {
-		return nil
+func (itrpr *Interpreter) ewait(e Executor, in *os.File, st stage) (int, error) {
+	std, err := openRedirects(st.redirs, in, os.Stdout, os.Stderr)
+	if err != nil {
+		return 0, err
 	}
-	if e, ok := itrpr.commands[args[0]]; ok {
-		// Встроенная команда
-		exitStatus := e.Exec(in, os.Stdout, args)
-		if exitStatus != 0 {
-			return fmt.Errorf("exit status %d", exitStatus)
-		}
-	} else {
-		// Используем exec, который является кроссплатформенной оберткой над fork, dup2, exec, wait
-		cmd := exec.Command(args[0], args[1:]...)
-		cmd.Stdin = in
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		err := cmd.Run()
-		return err
+	defer std.Close()
+	restore, err := itrpr.vars.With(st.assigns)
+	if err != nil {
+		return 0, err
 	}
-	return nil
+	defer restore()
+	return e.Exec(std.files[0], std.files[1], std.files[2], st.args), nil
 }
 
 // Создаем новый процесс, который выполняет команду
 // in -> cmd -> out
 // ..........\_ stderr
-func (itrpr *Interpreter) eforkout(in *os.File, out *os.File, args []string) (int, error) {
-	if len(args) == 0 {
+func (itrpr *Interpreter) eforkout(in *os.File, out *os.File, st stage, pg *procGroup) (int, error) {
+	std, err := openRedirects(st.redirs, in, out, os.Stderr)
+	if err != nil {
+		return 0, err
+	}
+	// Процесс уже унаследовал открытые файлы, родителю они не нужны
+	defer std.Close()
+	if st.empty() {
 		return 0, nil
 	}
+	args := st.args
+	// Окружение команды с присваиваниями, процесс получает его при запуске
+	restore, err := itrpr.vars.With(st.assigns)
+	if err != nil {
+		return 0, err
+	}
+	defer restore()
 
-	if e, ok := itrpr.commands[args[0]]; ok {
-		// Встроенная команда
-		return forkout(in, out, os.Stderr, wrapExecForFork(e, args))
+	if st.sub != nil {
+		return itrpr.startSubshell(*st.sub, std.files[0], std.files[1], std.files[2], pg)
+	} else if _, ok := itrpr.commands[args[0]]; ok {
+		// Встроенная команда выполняется в подоболочке из одной команды
+		return itrpr.startSubshell(Entity{Cmds: [][]string{args}}, std.files[0], std.files[1], std.files[2], pg)
 	} else {
 		cmd := exec.Command(args[0], args[1:]...)
-		cmd.Stdin = in
-		cmd.Stderr = os.Stderr
-		cmd.Stdout = out
-		err := cmd.Start()
-		return cmd.Process.Pid, err
+		cmd.Stdin = std.files[0]
+		cmd.Stdout = std.files[1]
+		cmd.Stderr = std.files[2]
+		cmd.SysProcAttr = pg.attr()
+		if err := cmd.Start(); err != nil {
+			return 0, err
+		}
+		pg.join(cmd.Process.Pid)
+		return cmd.Process.Pid, nil
 	}
 }
 
 // Создаем новый процесс, который выполняет команду
 // in -> cmd -> out
 // ..........\_ stderr
-func (itrpr *Interpreter) efork(in *os.File, args []string) (out *os.File, pid int, err error) {
-	if len(args) == 0 {
-		return in, 0, nil
+// создает pipe out
+func (itrpr *Interpreter) efork(in *os.File, st stage, pg *procGroup) (out *os.File, pid int, err error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, 0, err
 	}
+	pid, err = itrpr.eforkout(in, w, st, pg)
+	// Закрываем не нужный для родителя writer
+	w.Close()
+	if err != nil {
+		r.Close()
+		return nil, 0, err
+	}
+	return r, pid, nil
+}
 
-	if e, ok := itrpr.commands[args[0]]; ok {
-		// Встроенная команда
-		return fork(in, os.Stderr, wrapExecForFork(e, args))
-	} else {
-		cmd := exec.Command(args[0], args[1:]...)
-		cmd.Stdin = in
-		cmd.Stderr = os.Stderr
+// Текст конвейера или списка для таблицы заданий
+func jobText(e Entity) string {
+	if e.List != nil {
+		var b strings.Builder
+		for i, item := range e.List {
+			if i > 0 {
+				b.WriteString([]string{"; ", " && ", " || "}[item.Op])
+			}
+			b.WriteString(jobText(item.Entity))
+		}
+		return b.String()
+	}
+	cmds := make([]string, 0, len(e.Cmds))
+	for i, cmd := range e.Cmds {
+		if i < len(e.Subs) && e.Subs[i] != nil {
+			cmds = append(cmds, "("+jobText(*e.Subs[i])+")")
+			continue
+		}
+		var words []string
+		if i < len(e.Assigns) {
+			for _, w := range e.Assigns[i] {
+				words = append(words, w.Value)
+			}
+		}
+		cmds = append(cmds, strings.Join(append(words, cmd...), " "))
+	}
+	return strings.Join(cmds, " | ")
+}
 
-		if rp, err := cmd.StdoutPipe(); err == nil {
-			out = rp.(*os.File) // TODO да, это не очень хорошо, но буду рассчитывать на неизменность модуля exec
-		} else {
-			return out, 0, err
+// Отдает заданию терминал и ждет его. Если cont, то задание
+// сначала продолжается. После ожидания терминал возвращается интерпретатору
+func (itrpr *Interpreter) foreground(job *Job, cont bool) (JobState, error) {
+	if itrpr.tty >= 0 && job.Pgid > 0 {
+		// Группа могла уже завершиться, тогда отдавать терминал некому
+		_ = tcsetpgrp(itrpr.tty, job.Pgid)
+		defer func() {
+			if err := tcsetpgrp(itrpr.tty, itrpr.pgid); err != nil {
+				fmt.Fprintf(os.Stderr, "can't reclaim terminal: %s\n", err)
+			}
+		}()
+	}
+	if cont {
+		if err := itrpr.jobs.Continue(job); err != nil {
+			return JobStopped, err
+		}
+	}
+	state := itrpr.jobs.Wait(job)
+	if state == JobDone && job.Status() == 128+int(syscall.SIGINT) {
+		// После ^C в терминале курсор остался в строке
+		fmt.Fprintln(os.Stderr)
+	}
+	return state, nil
+}
+
+// Код возврата команды, которую не удалось запустить
+func errStatus(err error) int {
+	if errors.Is(err, exec.ErrNotFound) {
+		return 127
+	}
+	if errors.Is(err, os.ErrPermission) {
+		return 126
+	}
+	return 1
+}
+
+// Аргументы i-й команды после раскрытия
+func (itrpr *Interpreter) args(e Entity, i int) []string {
+	if i >= len(e.Words) || e.Words[i] == nil {
+		return e.Cmds[i]
+	}
+	var args []string
+	for _, w := range e.Words[i] {
+		args = append(args, expandWord(w, itrpr.lookup, itrpr.subst)...)
+	}
+	return args
+}
+
+// i-я команда конвейера после раскрытия
+func (itrpr *Interpreter) stage(e Entity, i int) (stage, error) {
+	redirs, err := itrpr.redirs(e, i)
+	if err != nil {
+		return stage{}, err
+	}
+	st := stage{args: itrpr.args(e, i), redirs: redirs}
+	if i < len(e.Subs) {
+		st.sub = e.Subs[i]
+	}
+	if i < len(e.Assigns) {
+		for _, w := range e.Assigns[i] {
+			st.assigns = append(st.assigns, expandString(w, itrpr.lookup, itrpr.subst))
+		}
+	}
+	return st, nil
+}
+
+// Перенаправления i-й команды после раскрытия имен файлов
+func (itrpr *Interpreter) redirs(e Entity, i int) ([]Redirect, error) {
+	if i >= len(e.Redirs) {
+		return nil, nil
+	}
+	redirs := append([]Redirect(nil), e.Redirs[i]...)
+	for j, r := range redirs {
+		if r.Word == nil {
+			continue
+		}
+		fields := expandWord(*r.Word, itrpr.lookup, itrpr.subst)
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("%s: неоднозначное перенаправление", r.Word.Value)
 		}
-		err := cmd.Start()
-		return out, cmd.Process.Pid, err
+		redirs[j].Path = fields[0]
 	}
+	return redirs, nil
 }
 
-func (itrpr *Interpreter) do(e Entity) error {
+// Выполняет конвейер и возвращает его код возврата. Коды всех
+// команд конвейера сохраняются для $PIPESTATUS, код конвейера - для $?
+func (itrpr *Interpreter) do(e Entity) int {
+	statuses, err := itrpr.pipeline(e)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+	}
+	itrpr.pipeStatus = statuses
+	itrpr.status = 0
+	if len(statuses) > 0 {
+		itrpr.status = statuses[len(statuses)-1]
+	}
+	if itrpr.options["pipefail"] {
+		// Код последней неуспешной команды
+		for _, st := range statuses {
+			if st != 0 {
+				itrpr.status = st
+			}
+		}
+	}
+	return itrpr.status
+}
+
+// Запускает конвейер и возвращает коды возврата его команд
+func (itrpr *Interpreter) pipeline(e Entity) ([]int, error) {
 	if len(e.Cmds) == 0 {
-		return errors.New("empty command")
+		return []int{1}, errors.New("empty command")
 	}
+	statuses := make([]int, len(e.Cmds))
 
 	in := os.Stdin
-	defer func() {
+	closeIn := func() {
 		if in != os.Stdin {
-			in.Close()
+			in.Close() // Закрываем pipe, через который мы связывали два процесса
+		}
+		in = os.Stdin
+	}
+	defer closeIn()
+	// Процессы конвейера, все они попадают в одно задание и одну группу
+	var pids []int
+	var stages []int // Номер команды каждого процесса из pids
+	pg := procGroup{on: itrpr.tty >= 0, fg: !e.Bg, tty: itrpr.tty}
+	// Ждет процессы конвейера на переднем плане и собирает их коды
+	wait := func() {
+		if len(pids) == 0 {
+			return
+		}
+		job := itrpr.jobs.Add(jobText(e), pg.pgid, pids, true)
+		if state, _ := itrpr.foreground(job, false); state == JobStopped {
+			fmt.Fprintf(os.Stderr, "\n[%d]+  Stopped    %s\n", job.Id, job.Cmd)
+			for i := range statuses {
+				statuses[i] = 128 + int(syscall.SIGTSTP)
+			}
+			return
+		}
+		for k, st := range job.Statuses() {
+			statuses[stages[k]] = st
+		}
+	}
+	// Если конвейер не удалось запустить целиком, дожидаемся уже запущенных,
+	// чтобы они не остались зомби. Незапущенные команды считаются неуспешными
+	fail := func(i int, err error) ([]int, error) {
+		closeIn()
+		for j := i; j < len(statuses); j++ {
+			statuses[j] = errStatus(err)
+		}
+		wait()
+		return statuses, err
+	}
+	start := func(i int, pid int, st stage) {
+		if !st.empty() {
+			pids = append(pids, pid)
+			stages = append(stages, i)
+		}
+	}
+	for i := range e.Cmds[:len(e.Cmds)-1] {
+		st, err := itrpr.stage(e, i)
+		if err != nil {
+			return fail(i, err)
 		}
-	}()
-	for _, cmd := range e.Cmds[:len(e.Cmds)-1] {
 		// Нужно сделать fork и выполнить ее там
-		out, pid, err := itrpr.efork(in, cmd)
+		out, pid, err := itrpr.efork(in, st, &pg)
 		if err != nil {
-			return err
+			return fail(i, err)
 		}
-		fmt.Fprintf(os.Stderr, "forked: %-10s pid: %6d\n", cmd[0], pid)
-
-		if in != os.Stdin {
-			in.Close() // Закрываем pipe, через который мы связывали два процесса
+		if !st.empty() {
+			fmt.Fprintf(os.Stderr, "forked: %-10s pid: %6d\n", st.name(), pid)
 		}
+		start(i, pid, st)
+		closeIn()
 		in = out
 	}
 
 	// Выполняем последнюю команду особенно
-	lastArgs := e.Cmds[len(e.Cmds)-1]
+	last := len(e.Cmds) - 1
+	itrpr.substStatus = 0
+	lastSt, err := itrpr.stage(e, last)
+	if err != nil {
+		return fail(last, err)
+	}
+	if lastSt.empty() && len(e.Cmds) == 1 && !e.Bg {
+		// NAME=value без команды задает переменные интерпретатора,
+		// код возврата - как у последней подстановки: A=$(false)
+		for _, a := range lastSt.assigns {
+			if err := itrpr.vars.Set(splitAssign(a)); err != nil {
+				return fail(last, err)
+			}
+		}
+		statuses[last] = itrpr.substStatus
+	}
+	if lastSt.sub == nil && len(lastSt.args) > 0 {
+		if ex, ok := itrpr.commands[lastSt.args[0]]; ok && !e.Bg {
+			// Встроенная команда на переднем плане выполняется в интерпретаторе,
+			// чтобы cd, fg и другие меняли его состояние
+			status, err := itrpr.ewait(ex, in, lastSt)
+			if err != nil {
+				return fail(last, err)
+			}
+			closeIn()
+			wait()
+			statuses[last] = status
+			return statuses, nil
+		}
+	}
+	// Нужно сделать fork и выполнить ее там
+	pid, err := itrpr.eforkout(in, os.Stdout, lastSt, &pg)
+	if err != nil {
+		return fail(last, err)
+	}
+	start(last, pid, lastSt)
+	// Читающий конец pipe больше не нужен, иначе пишущие не получат SIGPIPE
+	closeIn()
 	if e.Bg {
-		// Нужно сделать fork и выполнить ее там
-		pid, err := itrpr.eforkout(in, os.Stdout, lastArgs)
-		if err != nil {
-			return err
+		if len(pids) > 0 {
+			job := itrpr.jobs.Add(jobText(e), pg.pgid, pids, false)
+			fmt.Fprintf(os.Stderr, "[%d] %d\n", job.Id, pids[len(pids)-1])
 		}
-		fmt.Fprintf(os.Stderr, "forked: %-10s pid: %6d\n", lastArgs[0], pid)
-		return nil
-	} else {
-		return itrpr.ewait(in, lastArgs)
+		return []int{0}, nil
+	}
+	wait()
+	return statuses, nil
+}
+
+// Выполняет список команд или конвейер и возвращает код возврата.
+// && и || пропускают команду по коду возврата предыдущей выполненной
+func (itrpr *Interpreter) run(e Entity) int {
+	if e.List == nil {
+		return itrpr.do(e)
+	}
+	status := itrpr.status
+	for _, item := range e.List {
+		if (item.Op == OpAnd && status != 0) || (item.Op == OpOr && status == 0) {
+			continue
+		}
+		status = itrpr.run(item.Entity)
+	}
+	return status
+}
+
+// Выполняет команду подстановки $(cmd) в подоболочке - новом процессе
+// интерпретатора - и возвращает то, что она вывела в stdout
+func (itrpr *Interpreter) subst(cmd string) string {
+	tokens, err := lex(cmd)
+	var e Entity
+	if err == nil {
+		e, err = parseTokens(tokens)
 	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s.\n", err)
+		itrpr.substStatus = 2
+		return ""
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		itrpr.substStatus = 1
+		return ""
+	}
+	// Подоболочка остается в группе интерпретатора, как и в sh
+	pid, err := itrpr.startSubshell(e, os.Stdin, w, os.Stderr, &procGroup{})
+	w.Close()
+	if err != nil {
+		r.Close()
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		itrpr.substStatus = 1
+		return ""
+	}
+	out, _ := io.ReadAll(r)
+	r.Close()
+	job := itrpr.jobs.Add("$("+cmd+")", 0, []int{pid}, true)
+	if itrpr.jobs.Wait(job) == JobDone {
+		itrpr.substStatus = job.Status()
+	}
+	// Дальше в той же команде $? - код подстановки, как в sh
+	itrpr.status = itrpr.substStatus
+	return string(out)
+}
+
+// Значение переменной для раскрытия
+func (itrpr *Interpreter) lookup(name string) (string, bool) {
+	switch name {
+	case "?":
+		return strconv.Itoa(itrpr.status), true
+	case "$":
+		return strconv.Itoa(os.Getpid()), true
+	}
+	// PIPESTATUS - массив: $PIPESTATUS - первый элемент,
+	// ${PIPESTATUS[n]} - n-й, ${PIPESTATUS[@]} - все через пробел
+	if rest, ok := strings.CutPrefix(name, "PIPESTATUS"); ok && (rest == "" || rest[0] == '[') {
+		idx := "0"
+		if rest != "" {
+			if !strings.HasSuffix(rest, "]") {
+				return "", false
+			}
+			idx = rest[1 : len(rest)-1]
+		}
+		if idx == "@" || idx == "*" {
+			list := make([]string, len(itrpr.pipeStatus))
+			for i, st := range itrpr.pipeStatus {
+				list[i] = strconv.Itoa(st)
+			}
+			return strings.Join(list, " "), len(list) > 0
+		}
+		n, err := strconv.Atoi(idx)
+		if err != nil || n < 0 || n >= len(itrpr.pipeStatus) {
+			return "", false
+		}
+		return strconv.Itoa(itrpr.pipeStatus[n]), true
+	}
+	return itrpr.vars.Get(name)
+}
+
+// Код возврата последнего конвейера
+func (itrpr *Interpreter) Status() int {
+	return itrpr.status
 }
 
 func (itrpr *Interpreter) inviteFunc() string {
@@ -250,15 +580,99 @@ func (itrpr *Interpreter) inviteFunc() string {
 	return msg
 }
 
+// Сообщает о завершившихся фоновых заданиях и задает приглашение,
+// которое парсер выведет перед чтением команды
+func (itrpr *Interpreter) prompt() {
+	itrpr.jobs.Reap()
+	itrpr.jobs.Notify(os.Stderr)
+	itrpr.parser.SetPrompt(itrpr.inviteFunc())
+}
+
+// Включает управление заданиями, если ввод - терминал: интерпретатор
+// получает свою группу процессов и терминал, а сигналы от терминала игнорирует
+func (itrpr *Interpreter) setupJobControl() {
+	tty := int(os.Stdin.Fd())
+	if !isTerminal(tty) {
+		return
+	}
+	// signal.Ignore ставит SIG_IGN, который унаследуют запущенные программы.
+	// Сигналы в этот канал никто не читает, Notify их просто отбрасывает
+	signal.Notify(make(chan os.Signal, 1), sigs(jobSignals)...)
+	// Лидер сессии не может сменить группу, тогда он уже в своей
+	_ = syscall.Setpgid(0, 0)
+	itrpr.pgid = syscall.Getpgrp()
+	if err := tcsetpgrp(tty, itrpr.pgid); err != nil {
+		fmt.Fprintf(os.Stderr, "job control disabled: %s\n", err)
+		return
+	}
+	itrpr.tty = tty
+}
+
+// Если ввод - терминал, включает редактор строки и историю,
+// которая хранится в $HISTFILE или ~/.dev08_history
+func (itrpr *Interpreter) setupEditor() {
+	parser, ok := itrpr.parser.(*DefaultParser)
+	if !ok || !isTerminal(int(os.Stdin.Fd())) {
+		return
+	}
+	path, ok := itrpr.lookup("HISTFILE")
+	if !ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, ".dev08_history")
+		}
+	}
+	if path != "" {
+		if err := itrpr.history.Load(path); err != nil {
+			fmt.Fprintf(os.Stderr, "history: %s\n", err)
+		}
+	}
+	parser.SetLineReader(NewLineEditor(os.Stdin, os.Stdout, itrpr.history, itrpr.complete))
+	parser.SetHistory(itrpr.history, os.Stdout)
+}
+
+// Дополнение для редактора строки: первое слово команды дополняется
+// встроенными командами и программами из $PATH, остальные - путями файлов
+func (itrpr *Interpreter) complete(line string) (int, []string) {
+	start, word, command := completionWord(line)
+	if command && !strings.Contains(word, "/") {
+		builtins := make([]string, 0, len(itrpr.commands))
+		for name := range itrpr.commands {
+			builtins = append(builtins, name)
+		}
+		path, _ := itrpr.lookup("PATH")
+		return start, completeCommands(word, builtins, path)
+	}
+	home, _ := itrpr.lookup("HOME")
+	return start, completeFiles(word, home)
+}
+
+func sigs(list []syscall.Signal) []os.Signal {
+	res := make([]os.Signal, len(list))
+	for i, sig := range list {
+		res[i] = sig
+	}
+	return res
+}
+
 func (itrpr *Interpreter) Start() {
+	itrpr.setupJobControl()
+	itrpr.setupEditor()
+	// Собираем фоновые задания по SIGCHLD, сообщения выводятся перед приглашением
+	sigchld := make(chan os.Signal, 1)
+	signal.Notify(sigchld, syscall.SIGCHLD)
+	defer signal.Stop(sigchld)
+	go func() {
+		for range sigchld {
+			itrpr.jobs.Reap()
+		}
+	}()
+
 	var e Entity
-	fmt.Print(itrpr.inviteFunc())
+	itrpr.prompt()
 	e = itrpr.parser.Parse()
 	for !e.EOF {
-		if err := itrpr.do(e); err != nil {
-			fmt.Fprintf(os.Stderr, "error: %s\n", err)
-		}
-		fmt.Print(itrpr.inviteFunc())
+		itrpr.run(e)
+		itrpr.prompt()
 		e = itrpr.parser.Parse()
 	}
 }
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Подоболочки запускают этот же файл, то есть тесты
+	if isSubshell() {
+		os.Exit(runSubshell())
+	}
+	os.Exit(m.Run())
+}
+
+func parseLine(line string) Entity {
+	return NewDefaultParser(strings.NewReader(line)).Parse()
+}
+
+func TestInterpreterStatus(t *testing.T) {
+	intr := NewInterpreter()
+	SetupCmds(intr)
+	testCases := []struct {
+		line       string
+		pipefail   bool
+		status     int
+		pipeStatus []int
+	}{
+		{"true", false, 0, []int{0}},
+		{"false", false, 1, []int{1}},
+		{"sh -c 'exit 3' | true", false, 0, []int{3, 0}},
+		{"sh -c 'exit 3' | true", true, 3, []int{3, 0}},
+		{"true | false | true", true, 1, []int{0, 1, 0}},
+		{"true | nonexistent_dev08_cmd", false, 127, []int{0, 127}},
+		{"cd /nonexistent_dev08_dir", false, 1, []int{1}},
+	}
+	for _, tc := range testCases {
+		intr.options["pipefail"] = tc.pipefail
+		status := intr.do(parseLine(tc.line))
+		if status != tc.status || intr.Status() != tc.status {
+			t.Errorf("%s: status should be %d, got %d", tc.line, tc.status, status)
+		}
+		if !reflect.DeepEqual(intr.pipeStatus, tc.pipeStatus) {
+			t.Errorf("%s: PIPESTATUS should be %v, got %v", tc.line, tc.pipeStatus, intr.pipeStatus)
+		}
+	}
+}
+
+func TestInterpreterStatusExpand(t *testing.T) {
+	intr := NewInterpreter()
+	SetupCmds(intr)
+	intr.do(parseLine("sh -c 'exit 2' | false"))
+	got := intr.args(parseLine(`echo $? "${PIPESTATUS[@]}" ${PIPESTATUS[0]} $PIPESTATUS ${PIPESTATUS[5]}`), 0)
+	want := []string{"echo", "1", "2 1", "2", "2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestInterpreterList(t *testing.T) {
+	intr := NewInterpreter()
+	SetupCmds(intr)
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	out := filepath.Join(dir, "out")
+	testCases := []struct {
+		line   string
+		status int
+		want   string // Содержимое out после команды
+	}{
+		{"true && echo a > " + out, 0, "a\n"},
+		{"false && echo b > " + out, 1, "a\n"},
+		{"false && echo b || echo c > " + out, 0, "c\n"},
+		{"true || echo d > " + out + "; echo e >> " + out, 0, "c\ne\n"},
+		{"(exit 3) || (exit 4)", 4, "c\ne\n"},
+		{"(cd " + dir + "; pwd) > " + out, 0, dir + "\n"},
+		{"(echo f; echo g) | cat > " + out, 0, "f\ng\n"},
+	}
+	for _, tc := range testCases {
+		status := intr.run(parseLine(tc.line))
+		if status != tc.status {
+			t.Errorf("%s: status should be %d, got %d", tc.line, tc.status, status)
+		}
+		b, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatalf("%s: err should be nil: %s", tc.line, err)
+		}
+		if string(b) != tc.want {
+			t.Errorf("%s: got %q; want %q", tc.line, b, tc.want)
+		}
+	}
+	// cd в подоболочке не меняет каталог интерпретатора
+	if got, _ := os.Getwd(); got != wd {
+		t.Errorf("wd should be %s, got %s", wd, got)
+	}
+}
+
+func TestInterpreterVars(t *testing.T) {
+	const name = "DEV08_TEST_X"
+	os.Unsetenv(name)
+	defer os.Unsetenv(name)
+	intr := NewInterpreter()
+	SetupCmds(intr)
+	out := filepath.Join(t.TempDir(), "out")
+	testCases := []struct {
+		line string
+		want string // Содержимое out после команды
+	}{
+		// Неэкспортированная переменная не видна программам
+		{name + "=a; sh -c 'echo [$" + name + "]' > " + out, "[]\n"},
+		{name + "=b sh -c 'echo [$" + name + "]' > " + out, "[b]\n"},
+		{"echo ${" + name + "} > " + out, "a\n"},
+		{"export " + name + "; sh -c 'echo [$" + name + "]' > " + out, "[a]\n"},
+		{"unset " + name + "; echo ${" + name + ":-unset} > " + out, "unset\n"},
+	}
+	for _, tc := range testCases {
+		intr.run(parseLine(tc.line))
+		b, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatalf("%s: err should be nil: %s", tc.line, err)
+		}
+		if string(b) != tc.want {
+			t.Errorf("%s: got %q; want %q", tc.line, b, tc.want)
+		}
+	}
+}
+
+func TestInterpreterSubst(t *testing.T) {
+	intr := NewInterpreter()
+	SetupCmds(intr)
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	testCases := []struct {
+		line   string
+		status int
+		want   string // Содержимое out после команды
+	}{
+		{"echo $(echo 'a   b') \"$(echo 'a   b')\" > " + out, 0, "a b a   b\n"},
+		{"echo x`echo y`z $(echo \"$(echo nested)\") > " + out, 0, "xyz nested\n"},
+		{"echo $(cd " + dir + "; pwd) > " + out, 0, dir + "\n"},
+		{"echo $(echo a | tr a b) $(exit 3) $? > " + out, 0, "b 3\n"},
+		{"DEV08_SUBST=$(echo c; false)", 1, "b 3\n"},
+		{"echo $DEV08_SUBST > $(echo " + out + ")", 0, "c\n"},
+	}
+	for _, tc := range testCases {
+		if status := intr.run(parseLine(tc.line)); status != tc.status {
+			t.Errorf("%s: status should be %d, got %d", tc.line, tc.status, status)
+		}
+		b, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatalf("%s: err should be nil: %s", tc.line, err)
+		}
+		if string(b) != tc.want {
+			t.Errorf("%s: got %q; want %q", tc.line, b, tc.want)
+		}
+	}
+	// cd в подстановке не меняет каталог интерпретатора
+	if got, _ := os.Getwd(); got != wd {
+		t.Errorf("wd should be %s, got %s", wd, got)
+	}
+}
@@ -0,0 +1,335 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Состояние задания
+type JobState int
+
+const (
+	JobRunning JobState = iota
+	JobStopped
+	JobDone
+)
+
+func (s JobState) String() string {
+	switch s {
+	case JobRunning:
+		return "Running"
+	case JobStopped:
+		return "Stopped"
+	}
+	return "Done"
+}
+
+// Процесс конвейера
+type jobProc struct {
+	pid     int
+	done    bool
+	stopped bool
+	status  int // Код возврата, для убитых сигналом 128+номер сигнала
+}
+
+// Задание - запущенный конвейер
+type Job struct {
+	Id    int
+	Cmd   string // Текст команды для вывода
+	Pgid  int    // Группа процессов, 0 - процессы в группе интерпретатора
+	procs []jobProc
+	fg    bool // Задание ждет основной цикл, фоновый reap его не трогает
+}
+
+// Состояние задания по состояниям процессов
+func (j *Job) State() JobState {
+	state := JobDone
+	for _, p := range j.procs {
+		if p.stopped {
+			return JobStopped
+		}
+		if !p.done {
+			state = JobRunning
+		}
+	}
+	return state
+}
+
+// Код возврата задания - код последнего процесса
+func (j *Job) Status() int {
+	if len(j.procs) == 0 {
+		return 0
+	}
+	return j.procs[len(j.procs)-1].status
+}
+
+// Коды возврата процессов в порядке pids
+func (j *Job) Statuses() []int {
+	res := make([]int, 0, len(j.procs))
+	for _, p := range j.procs {
+		res = append(res, p.status)
+	}
+	return res
+}
+
+func (j *Job) Pids() []int {
+	res := make([]int, 0, len(j.procs))
+	for _, p := range j.procs {
+		res = append(res, p.pid)
+	}
+	return res
+}
+
+// Обновляет состояние процесса по результату wait4
+func (j *Job) update(pid int, ws syscall.WaitStatus) {
+	for i := range j.procs {
+		p := &j.procs[i]
+		if p.pid != pid {
+			continue
+		}
+		switch {
+		case ws.Stopped():
+			p.stopped = true
+		case ws.Continued():
+			p.stopped = false
+		case ws.Signaled():
+			p.done, p.stopped = true, false
+			p.status = 128 + int(ws.Signal())
+		default:
+			p.done, p.stopped = true, false
+			p.status = ws.ExitStatus()
+		}
+	}
+}
+
+// Таблица заданий интерпретатора
+type JobTable struct {
+	lock sync.Mutex
+	jobs []*Job // По возрастанию Id
+}
+
+func NewJobTable() *JobTable {
+	return &JobTable{}
+}
+
+// Добавляет задание из процессов pids группы pgid, возвращает его
+func (t *JobTable) Add(cmd string, pgid int, pids []int, fg bool) *Job {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	j := &Job{Id: 1, Cmd: cmd, Pgid: pgid, fg: fg}
+	if len(t.jobs) > 0 {
+		j.Id = t.jobs[len(t.jobs)-1].Id + 1
+	}
+	for _, pid := range pids {
+		j.procs = append(j.procs, jobProc{pid: pid})
+	}
+	t.jobs = append(t.jobs, j)
+	return j
+}
+
+func (t *JobTable) remove(j *Job) {
+	for i, v := range t.jobs {
+		if v == j {
+			t.jobs = append(t.jobs[:i], t.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Собирает завершившиеся и остановленные процессы фоновых заданий.
+// Вызывается по SIGCHLD и перед выводом приглашения
+func (t *JobTable) Reap() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, j := range t.jobs {
+		if j.fg {
+			continue
+		}
+		for _, p := range j.procs {
+			if p.done {
+				continue
+			}
+			var ws syscall.WaitStatus
+			pid, err := syscall.Wait4(p.pid, &ws, syscall.WNOHANG|syscall.WUNTRACED|syscall.WCONTINUED, nil)
+			if err == nil && pid == p.pid {
+				j.update(pid, ws)
+			} else if errors.Is(err, syscall.ECHILD) {
+				// Процесс уже собран кем-то другим
+				j.update(p.pid, 0)
+			}
+		}
+	}
+}
+
+// Выводит сообщения о завершенных фоновых заданиях и удаляет их
+func (t *JobTable) Notify(w io.Writer) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, j := range append([]*Job(nil), t.jobs...) {
+		if j.fg || j.State() != JobDone {
+			continue
+		}
+		fmt.Fprintln(w, t.format(j))
+		t.remove(j)
+	}
+}
+
+// Выводит все задания, как builtin jobs. Завершенные задания
+// после вывода удаляются
+func (t *JobTable) List(w io.Writer) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, j := range append([]*Job(nil), t.jobs...) {
+		if j.fg {
+			continue
+		}
+		fmt.Fprintln(w, t.format(j))
+		if j.State() == JobDone {
+			t.remove(j)
+		}
+	}
+}
+
+// Состояние задания, которое может менять фоновый Reap
+func (t *JobTable) State(j *Job) JobState {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return j.State()
+}
+
+// Продолжает остановленные процессы задания сигналом SIGCONT.
+// Если у задания своя группа, сигнал получает вся группа
+func (t *JobTable) Continue(j *Job) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if j.Pgid > 0 {
+		if err := syscall.Kill(-j.Pgid, syscall.SIGCONT); err != nil {
+			return err
+		}
+	}
+	for i := range j.procs {
+		p := &j.procs[i]
+		if p.done {
+			continue
+		}
+		if j.Pgid == 0 {
+			if err := syscall.Kill(p.pid, syscall.SIGCONT); err != nil {
+				return err
+			}
+		}
+		p.stopped = false
+	}
+	return nil
+}
+
+// Строка задания в стиле bash: [1]+  Running    sleep 10 &.
+// Вызывающий должен держать lock
+func (t *JobTable) format(j *Job) string {
+	mark := ' '
+	if n := len(t.jobs); n > 0 && t.jobs[n-1] == j {
+		mark = '+'
+	} else if n > 1 && t.jobs[n-2] == j {
+		mark = '-'
+	}
+	state := j.State().String()
+	if state == "Done" && j.Status() != 0 {
+		state = fmt.Sprintf("Exit %d", j.Status())
+	}
+	cmd := j.Cmd
+	if j.State() == JobRunning {
+		cmd += " &"
+	}
+	return fmt.Sprintf("[%d]%c  %-10s %s", j.Id, mark, state, cmd)
+}
+
+// Ищет задание по спецификации: %n, n, %%, %+ или пустая строка - текущее, %- - предыдущее
+func (t *JobTable) Find(spec string) (*Job, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	n := len(t.jobs)
+	switch spec {
+	case "", "%", "%%", "%+":
+		if n == 0 {
+			return nil, errors.New("нет текущего задания")
+		}
+		return t.jobs[n-1], nil
+	case "%-":
+		if n < 2 {
+			return nil, errors.New("нет предыдущего задания")
+		}
+		return t.jobs[n-2], nil
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(spec, "%"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: неверное задание", spec)
+	}
+	for _, j := range t.jobs {
+		if j.Id == id {
+			return j, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: нет такого задания", spec)
+}
+
+// Фоновые задания, которые еще работают
+func (t *JobTable) Running() []*Job {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var res []*Job
+	for _, j := range t.jobs {
+		if !j.fg && j.State() == JobRunning {
+			res = append(res, j)
+		}
+	}
+	return res
+}
+
+// Ждет, пока все процессы задания завершатся или одно из них остановится.
+// Пока задание ждут, фоновый Reap его не трогает.
+// Завершенное задание удаляется из таблицы
+func (t *JobTable) Wait(j *Job) JobState {
+	t.lock.Lock()
+	j.fg = true
+	procs := append([]jobProc(nil), j.procs...)
+	t.lock.Unlock()
+
+	for _, p := range procs {
+		if p.done {
+			continue
+		}
+		for {
+			var ws syscall.WaitStatus
+			pid, err := syscall.Wait4(p.pid, &ws, syscall.WUNTRACED, nil)
+			if errors.Is(err, syscall.EINTR) {
+				continue
+			}
+			t.lock.Lock()
+			if err != nil {
+				j.update(p.pid, 0)
+			} else {
+				j.update(pid, ws)
+			}
+			t.lock.Unlock()
+			if err == nil && ws.Stopped() {
+				// Задание остановлено, возвращаем его в фон
+				t.lock.Lock()
+				j.fg = false
+				t.lock.Unlock()
+				return JobStopped
+			}
+			break
+		}
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.remove(j)
+	return JobDone
+}
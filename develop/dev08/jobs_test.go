@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Запускает команду и возвращает ее pid
+func startProc(t *testing.T, name string, args ...string) int {
+	t.Helper()
+	cmd := exec.Command(name, args...)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	return cmd.Process.Pid
+}
+
+// Ждет, пока Reap переведет задание в состояние state
+func reapUntil(t *testing.T, table *JobTable, job *Job, state JobState) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		table.Reap()
+		if table.State(job) == state {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job state should be %s, got %s", state, table.State(job))
+}
+
+func TestJobTableFind(t *testing.T) {
+	table := NewJobTable()
+	if _, err := table.Find(""); err == nil {
+		t.Error("err should not be nil for empty table")
+	}
+	j1 := table.Add("a", 0, nil, false)
+	j2 := table.Add("b", 0, nil, false)
+	if j1.Id != 1 || j2.Id != 2 {
+		t.Fatalf("ids should be 1 and 2, got %d and %d", j1.Id, j2.Id)
+	}
+	testCases := []struct {
+		spec string
+		want *Job
+	}{
+		{"", j2},
+		{"%%", j2},
+		{"%+", j2},
+		{"%-", j1},
+		{"%1", j1},
+		{"2", j2},
+	}
+	for _, tc := range testCases {
+		got, err := table.Find(tc.spec)
+		if err != nil {
+			t.Fatalf("%q: err should be nil: %s", tc.spec, err)
+		}
+		if got != tc.want {
+			t.Errorf("%q: got job %d; want %d", tc.spec, got.Id, tc.want.Id)
+		}
+	}
+	for _, spec := range []string{"%3", "%x"} {
+		if _, err := table.Find(spec); err == nil {
+			t.Errorf("%q: err should not be nil", spec)
+		}
+	}
+}
+
+func TestJobTableReapNotify(t *testing.T) {
+	table := NewJobTable()
+	job := table.Add("sh -c exit 3", 0, []int{startProc(t, "true"), startProc(t, "sh", "-c", "exit 3")}, false)
+	reapUntil(t, table, job, JobDone)
+
+	var buf bytes.Buffer
+	table.Notify(&buf)
+	if want := "[1]+  Exit 3     sh -c exit 3\n"; buf.String() != want {
+		t.Errorf("got %q; want %q", buf.String(), want)
+	}
+	if _, err := table.Find("%1"); err == nil {
+		t.Error("done job should be removed after notify")
+	}
+}
+
+func TestJobTableWait(t *testing.T) {
+	table := NewJobTable()
+	job := table.Add("sh -c exit 5", 0, []int{startProc(t, "sh", "-c", "exit 5")}, true)
+	if state := table.Wait(job); state != JobDone {
+		t.Fatalf("state should be Done, got %s", state)
+	}
+	if job.Status() != 5 {
+		t.Errorf("status should be 5, got %d", job.Status())
+	}
+	if _, err := table.Find("%1"); err == nil {
+		t.Error("waited job should be removed")
+	}
+}
+
+func TestJobTableStopContinue(t *testing.T) {
+	table := NewJobTable()
+	pid := startProc(t, "sleep", "10")
+	job := table.Add("sleep 10", 0, []int{pid}, false)
+	defer syscall.Kill(pid, syscall.SIGKILL)
+
+	if err := syscall.Kill(pid, syscall.SIGSTOP); err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	reapUntil(t, table, job, JobStopped)
+
+	var buf bytes.Buffer
+	table.List(&buf)
+	if !strings.Contains(buf.String(), "Stopped") {
+		t.Errorf("jobs should list stopped job, got %q", buf.String())
+	}
+
+	if err := table.Continue(job); err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	if table.State(job) != JobRunning {
+		t.Errorf("state should be Running, got %s", table.State(job))
+	}
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	if state := table.Wait(job); state != JobDone {
+		t.Fatalf("state should be Done, got %s", state)
+	}
+	if want := 128 + int(syscall.SIGTERM); job.Status() != want {
+		t.Errorf("status should be %d, got %d", want, job.Status())
+	}
+}
+
+func TestJobTableGroupContinue(t *testing.T) {
+	table := NewJobTable()
+	cmd := exec.Command("sleep", "10")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	pid := cmd.Process.Pid
+	defer syscall.Kill(-pid, syscall.SIGKILL)
+	job := table.Add("sleep 10", pid, []int{pid}, false)
+
+	// Сигнал всей группе, как от ^Z в терминале
+	if err := syscall.Kill(-pid, syscall.SIGTSTP); err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	reapUntil(t, table, job, JobStopped)
+	if err := table.Continue(job); err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	reapUntil(t, table, job, JobRunning)
+
+	if err := syscall.Kill(-pid, syscall.SIGINT); err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	if state := table.Wait(job); state != JobDone {
+		t.Fatalf("state should be Done, got %s", state)
+	}
+	if want := 128 + int(syscall.SIGINT); job.Status() != want {
+		t.Errorf("status should be %d, got %d", want, job.Status())
+	}
+}
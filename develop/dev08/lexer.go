@@ -0,0 +1,331 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// Вид токена
+type TokenKind int
+
+const (
+	TokWord   TokenKind = iota // Слово, кавычки и экранирование уже сняты
+	TokPipe                    // |
+	TokAmp                     // &
+	TokRedir                   // Перенаправление: >, >>, <, 2>, 2>&1, &> и т.п.
+	TokAnd                     // &&
+	TokOr                      // ||
+	TokSemi                    // ;
+	TokLParen                  // (
+	TokRParen                  // )
+)
+
+// Токен командной строки
+type Token struct {
+	Kind  TokenKind
+	Value string
+}
+
+// Токен вместе со словом до раскрытия, для TokWord
+type lexToken struct {
+	Token
+	word Word
+}
+
+// Собирает слово и запоминает, как в него попал каждый байт
+type wordBuilder struct {
+	strings.Builder
+	quote  []byte
+	quoted bool
+}
+
+func (w *wordBuilder) add(c byte, q byte) {
+	w.WriteByte(c)
+	w.quote = append(w.quote, q)
+}
+
+func (w *wordBuilder) addString(s string, q byte) {
+	for i := 0; i < len(s); i++ {
+		w.add(s[i], q)
+	}
+}
+
+// Забирает собранное слово
+func (w *wordBuilder) take() Word {
+	res := Word{Value: w.String(), Quoted: w.quoted}
+	for _, q := range w.quote {
+		if q != qNone {
+			res.Quote = w.quote
+			break
+		}
+	}
+	w.Reset()
+	w.quote = nil
+	w.quoted = false
+	return res
+}
+
+// Строка закончилась внутри кавычек или на \, нужно дочитать следующую
+var ErrIncomplete = errors.New("незавершенная строка")
+
+// Разбивает строку на токены. Поддерживаются одинарные и двойные кавычки,
+// экранирование \ и перенос строки через \ в конце строки.
+// Подстановки команд $(...) и `...` остаются в слове как есть.
+// Операторы |, &, &&, ||, ;, скобки и перенаправления распознаются только вне кавычек
+func Lex(s string) ([]Token, error) {
+	lexed, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []Token
+	for _, t := range lexed {
+		tokens = append(tokens, t.Token)
+	}
+	return tokens, nil
+}
+
+// Как Lex, но для слов сохраняет кавычки, нужные для раскрытия
+func lex(s string) ([]lexToken, error) {
+	var tokens []lexToken
+	var word wordBuilder
+	inWord := false  // Нужно, чтобы "" давало пустое слово
+	literal := false // В слове были кавычки или \, тогда 2> - это не номер дескриптора
+	op := func(kind TokenKind, v string) {
+		tokens = append(tokens, lexToken{Token: Token{Kind: kind, Value: v}})
+	}
+
+	flush := func() {
+		if inWord {
+			w := word.take()
+			tokens = append(tokens, lexToken{Token: Token{Kind: TokWord, Value: w.Value}, word: w})
+			inWord = false
+			literal = false
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case ' ', '\t', '\n':
+			flush()
+		case '>', '<':
+			// Номер дескриптора пишется вплотную к оператору: 2>file
+			var fd string
+			if inWord && !literal && isDigits(word.String()) {
+				fd = word.take().Value
+				inWord = false
+			}
+			flush()
+			n := redirOpLen(s[i:])
+			op(TokRedir, fd+s[i:i+n])
+			i += n - 1
+		case '|':
+			flush()
+			if i+1 < len(s) && s[i+1] == '|' {
+				op(TokOr, "||")
+				i++
+				continue
+			}
+			op(TokPipe, "|")
+		case ';':
+			flush()
+			op(TokSemi, ";")
+		case '(':
+			flush()
+			op(TokLParen, "(")
+		case ')':
+			flush()
+			op(TokRParen, ")")
+		case '&':
+			flush()
+			if i+1 < len(s) && s[i+1] == '&' {
+				op(TokAnd, "&&")
+				i++
+				continue
+			}
+			// &> и &>> перенаправляют stdout и stderr
+			if i+1 < len(s) && s[i+1] == '>' {
+				n := 1 + redirOpLen(s[i+1:])
+				op(TokRedir, s[i:i+n])
+				i += n - 1
+				continue
+			}
+			op(TokAmp, "&")
+		case '\\':
+			if i+1 == len(s) {
+				return nil, ErrIncomplete
+			}
+			i++
+			// \<перевод строки> просто склеивает строки
+			if s[i] != '\n' {
+				word.add(s[i], qSingle)
+				inWord = true
+				literal = true
+			}
+		case '\'':
+			// Внутри одинарных кавычек все символы берутся как есть
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, ErrIncomplete
+			}
+			word.addString(s[i+1:i+1+end], qSingle)
+			word.quoted = true
+			inWord = true
+			literal = true
+			i += end + 1
+		case '`':
+			n, err := lexSubst(s[i:], &word, qSubst)
+			if err != nil {
+				return nil, err
+			}
+			inWord = true
+			i += n - 1
+		case '"':
+			n, err := lexDoubleQuoted(s[i+1:], &word)
+			if err != nil {
+				return nil, err
+			}
+			word.quoted = true
+			inWord = true
+			literal = true
+			i += n
+		default:
+			if c == '$' && i+1 < len(s) && s[i+1] == '(' {
+				n, err := lexSubst(s[i:], &word, qSubst)
+				if err != nil {
+					return nil, err
+				}
+				inWord = true
+				i += n - 1
+				continue
+			}
+			word.add(c, qNone)
+			inWord = true
+		}
+	}
+	flush()
+	return tokens, nil
+}
+
+// Разбирает содержимое двойных кавычек до закрывающей кавычки,
+// возвращает количество прочитанных байт вместе с ней.
+// Внутри \ экранирует только $ ` " \ и перевод строки
+func lexDoubleQuoted(s string, word *wordBuilder) (int, error) {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return i + 1, nil
+		case '\\':
+			if i+1 == len(s) {
+				return 0, ErrIncomplete
+			}
+			switch next := s[i+1]; next {
+			case '$', '`', '"', '\\':
+				word.add(next, qSingle)
+				i++
+			case '\n':
+				i++
+			default:
+				word.add(c, qDouble)
+			}
+		case '`', '$':
+			if c == '$' && (i+1 == len(s) || s[i+1] != '(') {
+				word.add(c, qDouble)
+				continue
+			}
+			n, err := lexSubst(s[i:], word, qSubstDouble)
+			if err != nil {
+				return 0, err
+			}
+			i += n - 1
+		default:
+			word.add(c, qDouble)
+		}
+	}
+	return 0, ErrIncomplete
+}
+
+// Добавляет в слово подстановку команды $(...) или `...` из начала s
+// как есть: первый байт помечается mark, остальные - qSubstBody.
+// Возвращает длину подстановки
+func lexSubst(s string, word *wordBuilder, mark byte) (int, error) {
+	n, err := substLen(s)
+	if err != nil {
+		return 0, err
+	}
+	word.add(s[0], mark)
+	word.addString(s[1:n], qSubstBody)
+	return n, nil
+}
+
+// Длина подстановки команды $(...) или `...` в начале s. Скобки
+// внутри кавычек и вложенных подстановок не считаются
+func substLen(s string) (int, error) {
+	if s[0] == '`' {
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '`':
+				return i + 1, nil
+			}
+		}
+		return 0, ErrIncomplete
+	}
+	depth := 0
+	inDouble := false
+	for i := 2; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case c == '"':
+			inDouble = !inDouble
+		case c == '\'' && !inDouble:
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return 0, ErrIncomplete
+			}
+			i += end + 1
+		case c == '`' || (c == '$' && i+1 < len(s) && s[i+1] == '('):
+			n, err := substLen(s[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n - 1
+		case inDouble:
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth == 0 {
+				return i + 1, nil
+			}
+			depth--
+		}
+	}
+	return 0, ErrIncomplete
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// Длина оператора перенаправления в начале s, s начинается с > или <.
+// Поддерживаются >, >>, <, >&N и <&N
+func redirOpLen(s string) int {
+	n := 1
+	if s[0] == '>' && len(s) > 1 && s[1] == '>' {
+		return 2
+	}
+	if len(s) > 1 && s[1] == '&' {
+		n = 2
+		for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+			n++
+		}
+	}
+	return n
+}
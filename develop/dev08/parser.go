@@ -2,54 +2,356 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"strings"
 )
 
-type DefaultParser struct {
+// Источник строк ввода
+type LineReader interface {
+	// Возвращает следующую строку без перевода строки,
+	// prompt - приглашение перед ней. В конце ввода - io.EOF
+	ReadLine(prompt string) (string, error)
+}
+
+// Строки из io.Reader, приглашение выводится в out, если он задан
+type scanLines struct {
 	scn *bufio.Scanner
+	out io.Writer
+}
+
+func (s *scanLines) ReadLine(prompt string) (string, error) {
+	if s.out != nil && prompt != "" {
+		fmt.Fprint(s.out, prompt)
+	}
+	if !s.scn.Scan() {
+		return "", io.EOF
+	}
+	return s.scn.Text(), nil
+}
+
+type DefaultParser struct {
+	lines   LineReader
+	scan    *scanLines // lines, если строки читаются из io.Reader
+	ps1     string     // Приглашение перед командой
+	ps2     string     // Приглашение для продолжения строки
+	history *History   // nil - история не ведется
+	echo    io.Writer  // Куда выводить строку после раскрытия !!
 }
 
 func NewDefaultParser(reader io.Reader) *DefaultParser {
+	scan := &scanLines{scn: bufio.NewScanner(reader)}
 	return &DefaultParser{
-		scn: bufio.NewScanner(reader),
+		lines: scan,
+		scan:  scan,
 	}
 }
 
-func (p *DefaultParser) Parse() Entity {
-	var e Entity
+// Задает приглашение, которое выводится в w,
+// когда команда продолжается на следующей строке
+func (p *DefaultParser) SetContinuationPrompt(w io.Writer, prompt string) {
+	if p.scan != nil {
+		p.scan.out = w
+	}
+	p.ps2 = prompt
+}
+
+// Задает приглашение перед следующей командой
+func (p *DefaultParser) SetPrompt(prompt string) {
+	p.ps1 = prompt
+}
+
+// Задает источник строк вместо io.Reader, например редактор строки
+func (p *DefaultParser) SetLineReader(r LineReader) {
+	p.lines = r
+	p.scan = nil
+}
 
-	for ok := true; ok; {
-		ok = false
-		e = Entity{}
-		if !p.scn.Scan() {
+// Включает историю: каждая команда добавляется в h, а !! и !n
+// в ней раскрываются. Раскрытая строка выводится в echo
+func (p *DefaultParser) SetHistory(h *History, echo io.Writer) {
+	p.history = h
+	p.echo = echo
+}
+
+// Считывает строку и токенизирует ее. Если строка не завершена
+// (открытая кавычка или \ в конце), то дочитывает следующие
+func (p *DefaultParser) readTokens() ([]lexToken, error) {
+	line, err := p.readLine(p.ps1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tokens, err := lex(line)
+		if !errors.Is(err, ErrIncomplete) {
+			if p.history != nil {
+				if err := p.history.Add(line); err != nil {
+					fmt.Fprintf(os.Stderr, "history: %s\n", err)
+				}
+			}
+			return tokens, err
+		}
+		next, err := p.readLine(p.ps2)
+		if err == io.EOF {
+			return nil, errors.New("неожиданный конец ввода")
+		}
+		if err != nil {
+			return nil, err
+		}
+		line += "\n" + next
+	}
+}
+
+// Считывает одну строку и раскрывает в ней ссылки на историю
+func (p *DefaultParser) readLine(prompt string) (string, error) {
+	line, err := p.lines.ReadLine(prompt)
+	if err != nil || p.history == nil {
+		return line, err
+	}
+	res, changed, err := p.history.Expand(line)
+	if err != nil {
+		return "", err
+	}
+	if changed && p.echo != nil {
+		fmt.Fprintln(p.echo, res)
+	}
+	return res, nil
+}
+
+func (p *DefaultParser) Parse() Entity {
+	for {
+		tokens, err := p.readTokens()
+		if err == io.EOF {
+			return Entity{
+				EOF: true,
+			}
+		}
+		if errors.Is(err, ErrNoEvent) {
+			// Строка со ссылкой на несуществующую команду пропускается
+			fmt.Fprintf(os.Stderr, "%s.\n", err)
+			continue
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
 			return Entity{
 				EOF: true,
 			}
 		}
-		line := p.scn.Text()
-		tokens := strings.Fields(line)
 
-		var currcmd []string
-		for i := 0; i < len(tokens); i++ {
-			if tokens[i] == "&" {
-				if len(tokens)-1 != i {
-					fmt.Fprintln(os.Stderr, "& может быть только в конце.")
-					ok = true // Нужно еще раз проитерироваться
-					break
-				}
-				e.Bg = true
-			} else if tokens[i] == "|" { // Если встретили канал, то сохраняем команду
-				e.Cmds = append(e.Cmds, currcmd)
-				currcmd = nil // Обнуляем массив
-			} else { // Добавляем токен в команду
-				currcmd = append(currcmd, tokens[i])
+		e, err := parseTokens(tokens)
+		if err != nil {
+			// Ошибочная строка пропускается
+			fmt.Fprintf(os.Stderr, "%s.\n", err)
+			continue
+		}
+		return e
+	}
+}
+
+// Разбирает токены строки рекурсивным спуском:
+//
+//	список    = и-или {; и-или} [;] [&]
+//	и-или     = конвейер {&& конвейер | || конвейер}
+//	конвейер  = команда {| команда}
+//	команда   = {слово | перенаправление} | ( список ) {перенаправление}
+//
+// Строка из одного конвейера возвращается как есть, иначе Entity.List
+func parseTokens(tokens []lexToken) (Entity, error) {
+	if len(tokens) == 0 {
+		return Entity{Cmds: [][]string{nil}}, nil
+	}
+	tp := &tokenParser{tokens: tokens}
+	items, err := tp.list(false)
+	if err != nil {
+		return Entity{}, err
+	}
+	if !tp.end() {
+		return Entity{}, fmt.Errorf("неожиданный %s", tp.peek().Value)
+	}
+	if len(items) == 1 {
+		return items[0].Entity, nil
+	}
+	return Entity{List: items}, nil
+}
+
+type tokenParser struct {
+	tokens []lexToken
+	pos    int
+}
+
+func (tp *tokenParser) end() bool {
+	return tp.pos == len(tp.tokens)
+}
+
+// Текущий токен, в конце строки - пустой
+func (tp *tokenParser) peek() lexToken {
+	if tp.end() {
+		return lexToken{Token: Token{Kind: -1}}
+	}
+	return tp.tokens[tp.pos]
+}
+
+func (tp *tokenParser) is(kind TokenKind) bool {
+	return !tp.end() && tp.tokens[tp.pos].Kind == kind
+}
+
+// Ошибка для пустой команды перед текущим токеном
+func (tp *tokenParser) emptyErr() error {
+	switch t := tp.peek(); t.Kind {
+	case TokAmp, TokSemi:
+		return fmt.Errorf("перед %s нет команды", t.Value)
+	case TokRParen:
+		return errors.New("пустые скобки")
+	case -1:
+		// Строка закончилась после оператора
+		if tp.pos > 0 {
+			return fmt.Errorf("%s должен стоять между командами", tp.tokens[tp.pos-1].Value)
+		}
+		return errors.New("пустая команда")
+	default:
+		return fmt.Errorf("%s должен стоять между командами", t.Value)
+	}
+}
+
+// Список и-или цепочек. В скобках список заканчивается на )
+func (tp *tokenParser) list(inParen bool) ([]ListItem, error) {
+	var items []ListItem
+	op := OpSeq
+	chain := 0 // Начало текущей и-или цепочки в items
+	for {
+		e, err := tp.pipeline()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, ListItem{Op: op, Entity: e})
+
+		switch tp.peek().Kind {
+		case TokAnd:
+			op = OpAnd
+		case TokOr:
+			op = OpOr
+		case TokSemi:
+			op, chain = OpSeq, len(items)
+			tp.pos++
+			// ; в конце списка допустима
+			if tp.end() || (inParen && tp.is(TokRParen)) {
+				return items, nil
+			}
+			continue
+		case TokAmp:
+			tp.pos++
+			if !tp.end() {
+				return nil, errors.New("& может быть только в конце")
 			}
+			if len(items)-chain == 1 {
+				items[chain].Bg = true
+				return items, nil
+			}
+			// Цепочка && и || целиком уходит в фон как подоболочка
+			sub := &Entity{List: append([]ListItem(nil), items[chain:]...)}
+			items = append(items[:chain], ListItem{Op: OpSeq, Entity: Entity{
+				Cmds: [][]string{nil},
+				Subs: []*Entity{sub},
+				Bg:   true,
+			}})
+			return items, nil
+		default:
+			return items, nil
 		}
-		e.Cmds = append(e.Cmds, currcmd)
+		tp.pos++
 	}
+}
+
+// Конвейер команд через |
+func (tp *tokenParser) pipeline() (Entity, error) {
+	var e Entity
+	hasRedirs, hasWords, hasSubs, hasAssigns := false, false, false, false
+	for {
+		if tp.end() || !(tp.is(TokWord) || tp.is(TokRedir) || tp.is(TokLParen)) {
+			return Entity{}, tp.emptyErr()
+		}
+		var cmd []string
+		var words []Word
+		var assigns []Word
+		var redirs []Redirect
+		var sub *Entity
+		if tp.is(TokLParen) {
+			tp.pos++
+			items, err := tp.list(true)
+			if err != nil {
+				return Entity{}, err
+			}
+			if !tp.is(TokRParen) {
+				return Entity{}, errors.New("нет закрывающей )")
+			}
+			tp.pos++
+			sub = &Entity{List: items}
+			if len(items) == 1 {
+				sub = &items[0].Entity
+			}
+			hasSubs = true
+		}
+		for tp.is(TokWord) || tp.is(TokRedir) {
+			t := tp.tokens[tp.pos]
+			tp.pos++
+			if t.Kind == TokWord {
+				if sub != nil {
+					return Entity{}, fmt.Errorf("после ) не может быть %s", t.Value)
+				}
+				// NAME=value перед командой - присваивания
+				if len(cmd) == 0 && isAssignment(t.word) {
+					assigns = append(assigns, t.word)
+					hasAssigns = true
+					continue
+				}
+				cmd = append(cmd, t.Value)
+				words = append(words, t.word)
+				hasWords = hasWords || t.word.needsExpand()
+				continue
+			}
+			r, needPath, err := parseRedirOp(t.Value)
+			if err == nil && needPath {
+				if !tp.is(TokWord) {
+					err = fmt.Errorf("после %s нужно имя файла", t.Value)
+				} else {
+					w := tp.tokens[tp.pos].word
+					tp.pos++
+					r[0].Path = w.Value
+					if w.needsExpand() {
+						r[0].Word = &w
+					}
+				}
+			}
+			if err != nil {
+				return Entity{}, err
+			}
+			redirs = append(redirs, r...)
+			hasRedirs = true
+		}
+		e.Cmds = append(e.Cmds, cmd)
+		e.Words = append(e.Words, words)
+		e.Redirs = append(e.Redirs, redirs)
+		e.Subs = append(e.Subs, sub)
+		e.Assigns = append(e.Assigns, assigns)
 
-	return e
+		if !tp.is(TokPipe) {
+			break
+		}
+		tp.pos++
+	}
+	// Параллельные Cmds срезы заполняем, только если в них что-то есть
+	if !hasRedirs {
+		e.Redirs = nil
+	}
+	if !hasWords {
+		e.Words = nil
+	}
+	if !hasSubs {
+		e.Subs = nil
+	}
+	if !hasAssigns {
+		e.Assigns = nil
+	}
+	return e, nil
 }
@@ -55,6 +55,133 @@ func TestDefaultParser(t *testing.T) {
 				EOF: true,
 			},
 		},
+		{
+			has: []byte(`echo "hello world" 'a|b' a\ b`),
+			want: Entity{
+				Cmds: [][]string{
+					{"echo", "hello world", "a|b", "a b"},
+				},
+			},
+		},
+		{
+			has: []byte(`grep 'a|b' file|wc -l&`),
+			want: Entity{
+				Cmds: [][]string{
+					{"grep", "a|b", "file"},
+					{"wc", "-l"},
+				},
+				Bg: true,
+			},
+		},
+		{
+			has: []byte("echo \"a \\&\" \\|"),
+			want: Entity{
+				Cmds: [][]string{
+					{"echo", "a \\&", "|"},
+				},
+			},
+		},
+		{
+			// Продолжение на следующей строке
+			has: []byte("echo a \\\nb \"c\nd\" 'e\nf'"),
+			want: Entity{
+				Cmds: [][]string{
+					{"echo", "a", "b", "c\nd", "e\nf"},
+				},
+			},
+		},
+		{
+			// Ошибочные строки пропускаются
+			has: []byte("a | | b\n| b\na & b\n& a\na |\nb c"),
+			want: Entity{
+				Cmds: [][]string{
+					{"b", "c"},
+				},
+			},
+		},
+		{
+			has: []byte("sort < in.txt 2>/dev/null | uniq -c >> out.txt 2>&1"),
+			want: Entity{
+				Cmds: [][]string{
+					{"sort"},
+					{"uniq", "-c"},
+				},
+				Redirs: [][]Redirect{
+					{{Kind: RedirIn, Fd: 0, Path: "in.txt"}, {Kind: RedirOut, Fd: 2, Path: "/dev/null"}},
+					{{Kind: RedirAppend, Fd: 1, Path: "out.txt"}, {Kind: RedirDup, Fd: 2, DupFd: 1}},
+				},
+			},
+		},
+		{
+			has: []byte(`make &> "build log" &`),
+			want: Entity{
+				Cmds: [][]string{
+					{"make"},
+				},
+				Redirs: [][]Redirect{
+					{{Kind: RedirOut, Fd: 1, Path: "build log"}, {Kind: RedirDup, Fd: 2, DupFd: 1}},
+				},
+				Bg: true,
+			},
+		},
+		{
+			// В кавычках это обычные слова
+			has: []byte(`echo '>' "2>" \< a2>b`),
+			want: Entity{
+				Cmds: [][]string{
+					{"echo", ">", "2>", "<", "a2"},
+				},
+				Redirs: [][]Redirect{
+					{{Kind: RedirOut, Fd: 1, Path: "b"}},
+				},
+			},
+		},
+		{
+			// Ошибки в перенаправлениях пропускаются
+			has: []byte("echo >\necho > | cat\necho 2>&x\n> empty"),
+			want: Entity{
+				Cmds: [][]string{
+					nil,
+				},
+				Redirs: [][]Redirect{
+					{{Kind: RedirOut, Fd: 1, Path: "empty"}},
+				},
+			},
+		},
+		{
+			// Слова, которые нужно раскрыть
+			has: []byte(`echo $? '$x' > $HOME/f`),
+			want: Entity{
+				Cmds: [][]string{
+					{"echo", "$?", "$x"},
+				},
+				Words: [][]Word{
+					{{Value: "echo"}, {Value: "$?"}, {Value: "$x", Quote: []byte{qSingle, qSingle}, Quoted: true}},
+				},
+				Redirs: [][]Redirect{
+					{{Kind: RedirOut, Fd: 1, Path: "$HOME/f", Word: &Word{Value: "$HOME/f"}}},
+				},
+			},
+		},
+		{
+			// Присваивания перед командой
+			has: []byte(`A=1 B='x y' env C=2`),
+			want: Entity{
+				Cmds: [][]string{
+					{"env", "C=2"},
+				},
+				Assigns: [][]Word{
+					{{Value: "A=1"}, {Value: "B=x y", Quote: []byte{0, 0, qSingle, qSingle, qSingle}, Quoted: true}},
+				},
+			},
+		},
+		{
+			// Незакрытая кавычка до конца ввода
+			has: []byte("echo 'abc"),
+			want: Entity{
+				EOF: true,
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -66,3 +193,171 @@ func TestDefaultParser(t *testing.T) {
 		}
 	}
 }
+
+func TestDefaultParserList(t *testing.T) {
+	cmd := func(args ...string) Entity { return Entity{Cmds: [][]string{args}} }
+	testCases := []struct {
+		has  string
+		want Entity
+	}{
+		{
+			has: "make && ./run || echo failed",
+			want: Entity{List: []ListItem{
+				{Op: OpSeq, Entity: cmd("make")},
+				{Op: OpAnd, Entity: cmd("./run")},
+				{Op: OpOr, Entity: cmd("echo", "failed")},
+			}},
+		},
+		{
+			has: "cd x; ls | wc -l;",
+			want: Entity{List: []ListItem{
+				{Op: OpSeq, Entity: cmd("cd", "x")},
+				{Op: OpSeq, Entity: Entity{Cmds: [][]string{{"ls"}, {"wc", "-l"}}}},
+			}},
+		},
+		{
+			// Фоновой становится только последняя цепочка
+			has: "a; b &",
+			want: Entity{List: []ListItem{
+				{Op: OpSeq, Entity: cmd("a")},
+				{Op: OpSeq, Entity: Entity{Cmds: [][]string{{"b"}}, Bg: true}},
+			}},
+		},
+		{
+			has: "a; b && c &",
+			want: Entity{List: []ListItem{
+				{Op: OpSeq, Entity: cmd("a")},
+				{Op: OpSeq, Entity: Entity{
+					Cmds: [][]string{nil},
+					Subs: []*Entity{{List: []ListItem{
+						{Op: OpSeq, Entity: cmd("b")},
+						{Op: OpAnd, Entity: cmd("c")},
+					}}},
+					Bg: true,
+				}},
+			}},
+		},
+		{
+			has: "(cd /tmp; ls) | wc > out",
+			want: Entity{
+				Cmds: [][]string{nil, {"wc"}},
+				Redirs: [][]Redirect{
+					nil,
+					{{Kind: RedirOut, Fd: 1, Path: "out"}},
+				},
+				Subs: []*Entity{
+					{List: []ListItem{
+						{Op: OpSeq, Entity: cmd("cd", "/tmp")},
+						{Op: OpSeq, Entity: cmd("ls")},
+					}},
+					nil,
+				},
+			},
+		},
+		{
+			// Ошибочные строки пропускаются
+			has:  "a &&\n|| b\n; a\na;;b\n(a\na)\n()\n(a) b\na & b\nok",
+			want: cmd("ok"),
+		},
+	}
+	for _, tc := range testCases {
+		p := NewDefaultParser(bytes.NewBufferString(tc.has))
+		got := p.Parse()
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q:\ngot:  %+v\nwant: %+v\n", tc.has, got, tc.want)
+		}
+	}
+}
+
+func TestLex(t *testing.T) {
+	word := func(v string) Token { return Token{Kind: TokWord, Value: v} }
+	pipe := Token{Kind: TokPipe, Value: "|"}
+	amp := Token{Kind: TokAmp, Value: "&"}
+
+	testCases := []struct {
+		has  string
+		want []Token
+		err  error
+	}{
+		{has: "", want: nil},
+		{has: "  a\tb  ", want: []Token{word("a"), word("b")}},
+		{has: `a""b '' ""`, want: []Token{word("ab"), word(""), word("")}},
+		{has: `a|b&`, want: []Token{word("a"), pipe, word("b"), amp}},
+		{has: `"a|b" 'c&d' e\|f`, want: []Token{word("a|b"), word("c&d"), word("e|f")}},
+		{has: `'a\b' "a\b"`, want: []Token{word(`a\b`), word(`a\b`)}},
+		{has: `"\$ \" \\ \x"`, want: []Token{word(`$ " \ \x`)}},
+		{has: `\'a\"`, want: []Token{word(`'a"`)}},
+		{has: "a\\\nb", want: []Token{word("ab")}},
+		{has: "\"a\\\nb\"", want: []Token{word("ab")}},
+		{has: `a>b 2>>c <d 2>&1 &>e &>>f`, want: []Token{
+			word("a"), {TokRedir, ">"}, word("b"), {TokRedir, "2>>"}, word("c"), {TokRedir, "<"}, word("d"),
+			{TokRedir, "2>&1"}, {TokRedir, "&>"}, word("e"), {TokRedir, "&>>"}, word("f"),
+		}},
+		{has: `"2">a 2\>a`, want: []Token{word("2"), {TokRedir, ">"}, word("a"), word("2>a")}},
+		{has: `a&&b||c;(d)`, want: []Token{
+			word("a"), {TokAnd, "&&"}, word("b"), {TokOr, "||"}, word("c"), {TokSemi, ";"},
+			{TokLParen, "("}, word("d"), {TokRParen, ")"},
+		}},
+		{has: `"a&&b" 'c;d' \(e\)`, want: []Token{word("a&&b"), word("c;d"), word("(e)")}},
+		{has: `a$(b | c; (d)) "$(e ")" 'f')"x` + "`g h`", want: []Token{
+			word("a$(b | c; (d))"), word(`$(e ")" 'f')x` + "`g h`"),
+		}},
+		{has: `$(a`, err: ErrIncomplete},
+		{has: `$(a ")"`, err: ErrIncomplete},
+		{has: "`a", err: ErrIncomplete},
+		{has: `'abc`, err: ErrIncomplete},
+		{has: `"abc`, err: ErrIncomplete},
+		{has: `"abc\"`, err: ErrIncomplete},
+		{has: `abc\`, err: ErrIncomplete},
+	}
+
+	for _, tc := range testCases {
+		got, err := Lex(tc.has)
+		if err != tc.err {
+			t.Errorf("%q: got err %v; want %v", tc.has, err, tc.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q:\ngot:  %v\nwant: %v\n", tc.has, got, tc.want)
+		}
+	}
+}
+
+func TestDefaultParserContinuationPrompt(t *testing.T) {
+	var out bytes.Buffer
+	p := NewDefaultParser(bytes.NewBufferString("echo 'a\nb\nc'\n"))
+	p.SetContinuationPrompt(&out, "> ")
+	got := p.Parse()
+	want := Entity{Cmds: [][]string{{"echo", "a\nb\nc"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:  %v\nwant: %v\n", got, want)
+	}
+	if out.String() != "> > " {
+		t.Errorf("got prompt %q; want %q", out.String(), "> > ")
+	}
+}
+
+func TestDefaultParserHistory(t *testing.T) {
+	var out bytes.Buffer
+	h := NewHistory(10)
+	p := NewDefaultParser(bytes.NewBufferString("echo a\n!9\necho 'b\nc'\n!-2 !!\n"))
+	p.SetHistory(h, &out)
+	want := []Entity{
+		{Cmds: [][]string{{"echo", "a"}}},
+		// !9 не найдено, строка пропускается
+		{Cmds: [][]string{{"echo", "b\nc"}}},
+		{Cmds: [][]string{{"echo", "a", "echo", "b\nc"}}},
+		{EOF: true},
+	}
+	for _, w := range want {
+		if got := p.Parse(); !reflect.DeepEqual(got, w) {
+			t.Errorf("\ngot:  %v\nwant: %v\n", got, w)
+		}
+	}
+	if out.String() != "echo a echo 'b\nc'\n" {
+		t.Errorf("got echo %q", out.String())
+	}
+	if h.Len() != 3 {
+		t.Errorf("history should have 3 lines, got %q", h.lines)
+	}
+}
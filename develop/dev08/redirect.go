@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Вид перенаправления
+type RedirKind int
+
+const (
+	RedirOut    RedirKind = iota // > file
+	RedirAppend                  // >> file
+	RedirIn                      // < file
+	RedirDup                     // 2>&1: Fd становится копией DupFd
+)
+
+// Перенаправление дескриптора одной команды
+type Redirect struct {
+	Kind  RedirKind
+	Fd    int    // Какой дескриптор перенаправляется: 0, 1 или 2
+	Path  string // Файл для RedirOut, RedirAppend и RedirIn
+	DupFd int    // Источник для RedirDup
+	Word  *Word  // Path до раскрытия, nil - раскрывать нечего
+}
+
+// Разбирает оператор перенаправления из токена TokRedir.
+// Для &> и &>> возвращает два перенаправления: stdout в файл и stderr в stdout.
+// needPath - нужно ли следующим словом имя файла
+func parseRedirOp(op string) (redirs []Redirect, needPath bool, err error) {
+	bad := fmt.Errorf("неизвестное перенаправление %s", op)
+	if rest, ok := strings.CutPrefix(op, "&"); ok {
+		r := Redirect{Kind: RedirOut, Fd: 1}
+		switch rest {
+		case ">":
+		case ">>":
+			r.Kind = RedirAppend
+		default:
+			return nil, false, bad
+		}
+		return []Redirect{r, {Kind: RedirDup, Fd: 2, DupFd: 1}}, true, nil
+	}
+
+	// Номер дескриптора перед оператором
+	i := strings.IndexAny(op, "<>")
+	r := Redirect{Fd: 1}
+	if op[i] == '<' {
+		r.Fd = 0
+	}
+	if i > 0 {
+		fd, err := strconv.Atoi(op[:i])
+		if err != nil {
+			return nil, false, bad
+		}
+		r.Fd = fd
+	}
+	switch o := op[i:]; {
+	case o == ">":
+		r.Kind = RedirOut
+	case o == ">>":
+		r.Kind = RedirAppend
+	case o == "<":
+		r.Kind = RedirIn
+	case strings.HasPrefix(o, ">&") || strings.HasPrefix(o, "<&"):
+		dup, err := strconv.Atoi(o[2:])
+		if err != nil {
+			return nil, false, bad
+		}
+		r.Kind = RedirDup
+		r.DupFd = dup
+		return []Redirect{r}, false, nil
+	default:
+		return nil, false, bad
+	}
+	return []Redirect{r}, true, nil
+}
+
+// Дескрипторы команды после перенаправлений. Открытые файлы
+// нужно закрыть через Close, когда команда их унаследует
+type stdio struct {
+	files  [3]*os.File // stdin, stdout, stderr
+	opened []*os.File
+}
+
+func (s *stdio) Close() {
+	for _, f := range s.opened {
+		f.Close()
+	}
+	s.opened = nil
+}
+
+// Применяет перенаправления по порядку к дескрипторам in, out и errf
+func openRedirects(redirs []Redirect, in, out, errf *os.File) (*stdio, error) {
+	s := &stdio{files: [3]*os.File{in, out, errf}}
+	for _, r := range redirs {
+		if r.Fd < 0 || r.Fd > 2 {
+			s.Close()
+			return nil, fmt.Errorf("дескриптор %d не поддерживается", r.Fd)
+		}
+		var f *os.File
+		var err error
+		switch r.Kind {
+		case RedirOut:
+			f, err = os.OpenFile(r.Path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+		case RedirAppend:
+			f, err = os.OpenFile(r.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		case RedirIn:
+			f, err = os.Open(r.Path)
+		case RedirDup:
+			if r.DupFd < 0 || r.DupFd > 2 {
+				s.Close()
+				return nil, fmt.Errorf("дескриптор %d не поддерживается", r.DupFd)
+			}
+			s.files[r.Fd] = s.files[r.DupFd]
+			continue
+		}
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.opened = append(s.opened, f)
+		s.files[r.Fd] = f
+	}
+	return s, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseRedirOp(t *testing.T) {
+	testCases := []struct {
+		op       string
+		want     []Redirect
+		needPath bool
+	}{
+		{">", []Redirect{{Kind: RedirOut, Fd: 1}}, true},
+		{"2>>", []Redirect{{Kind: RedirAppend, Fd: 2}}, true},
+		{"<", []Redirect{{Kind: RedirIn, Fd: 0}}, true},
+		{"2>&1", []Redirect{{Kind: RedirDup, Fd: 2, DupFd: 1}}, false},
+		{">&2", []Redirect{{Kind: RedirDup, Fd: 1, DupFd: 2}}, false},
+		{"&>>", []Redirect{{Kind: RedirAppend, Fd: 1}, {Kind: RedirDup, Fd: 2, DupFd: 1}}, true},
+	}
+	for _, tc := range testCases {
+		got, needPath, err := parseRedirOp(tc.op)
+		if err != nil {
+			t.Fatalf("%s: err should be nil: %s", tc.op, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) || needPath != tc.needPath {
+			t.Errorf("%s: got %v %v; want %v %v", tc.op, got, needPath, tc.want, tc.needPath)
+		}
+	}
+	for _, op := range []string{">&", "2>&x", "&>&1"} {
+		if _, _, err := parseRedirOp(op); err == nil {
+			t.Errorf("%s: err should not be nil", op)
+		}
+	}
+}
+
+func TestOpenRedirects(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	if err := os.WriteFile(out, []byte("old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// stdout дописывается в файл, stderr идет туда же
+	std, err := openRedirects([]Redirect{
+		{Kind: RedirAppend, Fd: 1, Path: out},
+		{Kind: RedirDup, Fd: 2, DupFd: 1},
+	}, os.Stdin, os.Stdout, os.Stderr)
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if std.files[0] != os.Stdin || std.files[1] != std.files[2] || std.files[1] == os.Stdout {
+		t.Fatalf("unexpected files %v", std.files)
+	}
+	_, _ = io.WriteString(std.files[1], "a\n")
+	_, _ = io.WriteString(std.files[2], "b\n")
+	std.Close()
+	if data, _ := os.ReadFile(out); string(data) != "old\na\nb\n" {
+		t.Fatalf("got %q", data)
+	}
+
+	// Порядок важен: 2>&1 до > оставляет stderr на старом stdout
+	std, err = openRedirects([]Redirect{
+		{Kind: RedirDup, Fd: 2, DupFd: 1},
+		{Kind: RedirOut, Fd: 1, Path: out},
+		{Kind: RedirIn, Fd: 0, Path: out},
+	}, os.Stdin, os.Stdout, os.Stderr)
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if std.files[2] != os.Stdout || std.files[0] == os.Stdin {
+		t.Fatalf("unexpected files %v", std.files)
+	}
+	std.Close()
+	if data, _ := os.ReadFile(out); len(data) != 0 {
+		t.Fatalf("file should be truncated: %q", data)
+	}
+
+	for _, redirs := range [][]Redirect{
+		{{Kind: RedirIn, Fd: 0, Path: filepath.Join(dir, "nope")}},
+		{{Kind: RedirOut, Fd: 3, Path: out}},
+		{{Kind: RedirDup, Fd: 1, DupFd: 5}},
+	} {
+		if _, err := openRedirects(redirs, os.Stdin, os.Stdout, os.Stderr); err == nil {
+			t.Errorf("%v: err should not be nil", redirs)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Аргумент, с которым программа запускается как подоболочка
+const subshellArg = "--subshell"
+
+// Подоболочка получает состояние через этот дескриптор
+const subshellFd = 3
+
+// Состояние интерпретатора, которое получает подоболочка
+type subshellState struct {
+	Entity     Entity
+	Vars       map[string]string // Неэкспортированные переменные, остальные - в окружении
+	Options    map[string]bool
+	Status     int
+	PipeStatus []int
+	History    []string
+}
+
+// Запущена ли программа как подоболочка
+func isSubshell() bool {
+	return len(os.Args) == 2 && os.Args[1] == subshellArg
+}
+
+// Выполняет подоболочку в процессе, который запустил startSubshell,
+// и возвращает ее код возврата
+func runSubshell() int {
+	f := os.NewFile(subshellFd, "subshell")
+	var state subshellState
+	err := json.NewDecoder(f).Decode(&state)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "subshell: %s\n", err)
+		return 2
+	}
+	itrpr := NewInterpreter()
+	SetupCmds(itrpr)
+	for name, value := range state.Vars {
+		itrpr.vars.local[name] = value
+	}
+	for name, on := range state.Options {
+		itrpr.options[name] = on
+	}
+	itrpr.status = state.Status
+	itrpr.pipeStatus = state.PipeStatus
+	itrpr.history.lines = state.History
+	return itrpr.run(state.Entity)
+}
+
+// Запускает e в новом процессе этой же программы. Go не умеет
+// продолжать работу после fork, поэтому подоболочка - это exec себя,
+// состояние интерпретатора она получает через дескриптор 3.
+// Управления заданиями в подоболочке нет, задания родителя ей не принадлежат
+func (itrpr *Interpreter) startSubshell(e Entity, in, out, errf *os.File, pg *procGroup) (int, error) {
+	state, err := json.Marshal(subshellState{
+		Entity:     e,
+		Vars:       itrpr.vars.local,
+		Options:    itrpr.options,
+		Status:     itrpr.status,
+		PipeStatus: itrpr.pipeStatus,
+		History:    itrpr.history.lines,
+	})
+	if err != nil {
+		return 0, err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer w.Close()
+	cmd := &exec.Cmd{
+		Path:        "/proc/self/exe",
+		Args:        []string{os.Args[0], subshellArg},
+		Stdin:       in,
+		Stdout:      out,
+		Stderr:      errf,
+		ExtraFiles:  []*os.File{r},
+		SysProcAttr: pg.attr(),
+	}
+	err = cmd.Start()
+	r.Close()
+	if err != nil {
+		return 0, err
+	}
+	pg.join(cmd.Process.Pid)
+	// Подоболочка сразу читает состояние целиком, поэтому запись не
+	// заблокируется надолго. Если подоболочка не смогла его прочитать,
+	// она сама сообщит об ошибке и вернет ненулевой код
+	_, _ = w.Write(state)
+	return cmd.Process.Pid, nil
+}
@@ -1,5 +1,7 @@
 package main
 
+import "os"
+
 /*
 Необходимо реализовать свой собственный UNIX-шелл-утилиту с поддержкой ряда простейших команд:
 
@@ -32,7 +34,11 @@ package main
 // }
 
 func main() {
+	if isSubshell() {
+		os.Exit(runSubshell())
+	}
 	intr := NewInterpreter()
 	SetupCmds(intr)
 	intr.Start()
+	os.Exit(intr.Status())
 }
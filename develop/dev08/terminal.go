@@ -0,0 +1,99 @@
+//go:build linux
+
+package main
+
+import (
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// Сигналы управления заданиями, которые интерпретатор игнорирует,
+// а запущенные им процессы обрабатывают по умолчанию
+var jobSignals = []syscall.Signal{syscall.SIGINT, syscall.SIGTSTP, syscall.SIGTTIN, syscall.SIGTTOU}
+
+// Является ли fd терминалом
+func isTerminal(fd int) bool {
+	var t syscall.Termios
+	return termios(fd, syscall.TCGETS, &t) == nil
+}
+
+// Читает (TCGETS) или задает (TCSETS) настройки терминала
+func termios(fd int, req uintptr, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Переводит терминал в сырой режим для редактора строки: символы
+// приходят сразу, без эха, а Ctrl-C и Ctrl-Z не посылают сигналы.
+// Возвращает прежние настройки, их нужно вернуть через restoreTerminal
+func makeRaw(fd int) (syscall.Termios, error) {
+	var old syscall.Termios
+	if err := termios(fd, syscall.TCGETS, &old); err != nil {
+		return old, err
+	}
+	raw := old
+	raw.Iflag &^= syscall.BRKINT | syscall.ICRNL | syscall.INPCK | syscall.ISTRIP | syscall.IXON
+	raw.Cflag |= syscall.CS8
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.IEXTEN | syscall.ISIG
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	return old, termios(fd, syscall.TCSETS, &raw)
+}
+
+func restoreTerminal(fd int, t syscall.Termios) error {
+	return termios(fd, syscall.TCSETS, &t)
+}
+
+// Ширина терминала fd в символах, 80 - если ее не узнать
+func terminalWidth(fd int) int {
+	var ws struct{ row, col, xpixel, ypixel uint16 }
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 || ws.col == 0 {
+		return 80
+	}
+	return int(ws.col)
+}
+
+// Группа процессов, которой принадлежит терминал fd
+func tcgetpgrp(fd int) (int, error) {
+	var pgid int32
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCGPGRP, uintptr(unsafe.Pointer(&pgid)))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(pgid), nil
+}
+
+// Отдает терминал fd группе pgid. Фоновой группе ядро за это
+// посылает SIGTTOU, поэтому на время вызова он блокируется
+func tcsetpgrp(fd, pgid int) error {
+	// Маска сигналов у каждого потока своя
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	set := uint64(1) << (syscall.SIGTTOU - 1)
+	var old uint64
+	sigprocmask(sigBlock, &set, &old)
+	defer sigprocmask(sigSetmask, &old, nil)
+
+	p := int32(pgid)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCSPGRP, uintptr(unsafe.Pointer(&p)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Аргумент how для rt_sigprocmask
+const (
+	sigBlock   = 0
+	sigSetmask = 2
+)
+
+func sigprocmask(how int, set, old *uint64) {
+	syscall.RawSyscall6(syscall.SYS_RT_SIGPROCMASK, uintptr(how),
+		uintptr(unsafe.Pointer(set)), uintptr(unsafe.Pointer(old)), 8, 0, 0)
+}
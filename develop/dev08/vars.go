@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// Переменные интерпретатора. Экспортированные переменные хранятся
+// в окружении процесса, их видят exec.LookPath и запущенные программы.
+// Остальные видны только при раскрытии
+type Vars struct {
+	local map[string]string
+}
+
+func NewVars() *Vars {
+	return &Vars{local: make(map[string]string)}
+}
+
+func (v *Vars) Get(name string) (string, bool) {
+	if value, ok := v.local[name]; ok {
+		return value, true
+	}
+	return os.LookupEnv(name)
+}
+
+func (v *Vars) Exported(name string) bool {
+	_, ok := os.LookupEnv(name)
+	return ok
+}
+
+// Задает значение. Экспортированная переменная остается экспортированной
+func (v *Vars) Set(name, value string) error {
+	if v.Exported(name) {
+		return os.Setenv(name, value)
+	}
+	v.local[name] = value
+	return nil
+}
+
+// Экспортирует переменную, если set, то с новым значением value
+func (v *Vars) Export(name, value string, set bool) error {
+	if !set {
+		var ok bool
+		if value, ok = v.local[name]; !ok {
+			value, _ = os.LookupEnv(name)
+		}
+	}
+	delete(v.local, name)
+	return os.Setenv(name, value)
+}
+
+func (v *Vars) Unset(name string) error {
+	delete(v.local, name)
+	return os.Unsetenv(name)
+}
+
+// Экспортированные переменные в виде NAME=value по алфавиту
+func (v *Vars) Environ() []string {
+	env := os.Environ()
+	sort.Strings(env)
+	return env
+}
+
+// Временно экспортирует переменные assigns вида NAME=value,
+// как для NAME=value cmd. Возвращает функцию, которая все возвращает назад
+func (v *Vars) With(assigns []string) (restore func(), err error) {
+	type saved struct {
+		name           string
+		local, env     string
+		isLocal, isEnv bool
+	}
+	var list []saved
+	restore = func() {
+		for i := len(list) - 1; i >= 0; i-- {
+			s := list[i]
+			delete(v.local, s.name)
+			if s.isLocal {
+				v.local[s.name] = s.local
+			}
+			if s.isEnv {
+				os.Setenv(s.name, s.env)
+			} else {
+				os.Unsetenv(s.name)
+			}
+		}
+	}
+	for _, a := range assigns {
+		name, value := splitAssign(a)
+		s := saved{name: name}
+		s.local, s.isLocal = v.local[name]
+		s.env, s.isEnv = os.LookupEnv(name)
+		list = append(list, s)
+		delete(v.local, name)
+		if err := os.Setenv(name, value); err != nil {
+			restore()
+			return nil, err
+		}
+	}
+	return restore, nil
+}
+
+// Разбивает NAME=value
+func splitAssign(s string) (name, value string) {
+	name, value, _ = strings.Cut(s, "=")
+	return name, value
+}
+
+// Является ли слово присваиванием NAME=value: имя и = без кавычек
+func isAssignment(w Word) bool {
+	s := w.Value
+	if s == "" || !isNameStart(s[0]) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if w.quote(i) != qNone {
+			return false
+		}
+		if s[i] == '=' {
+			return true
+		}
+		if !isNameChar(s[i]) {
+			return false
+		}
+	}
+	return false
+}
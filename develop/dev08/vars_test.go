@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestVars(t *testing.T) {
+	const name = "DEV08_TEST_VAR"
+	os.Unsetenv(name)
+	defer os.Unsetenv(name)
+	v := NewVars()
+
+	if _, ok := v.Get(name); ok {
+		t.Fatal("var should be unset")
+	}
+	// Неэкспортированная переменная не попадает в окружение
+	if err := v.Set(name, "local"); err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	if got, _ := v.Get(name); got != "local" {
+		t.Errorf("got %q; want %q", got, "local")
+	}
+	if v.Exported(name) {
+		t.Error("var should not be exported")
+	}
+	// После export значение берется из окружения
+	if err := v.Export(name, "", false); err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	if got := os.Getenv(name); got != "local" {
+		t.Errorf("env: got %q; want %q", got, "local")
+	}
+	if err := v.Set(name, "exported"); err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	if got := os.Getenv(name); got != "exported" {
+		t.Errorf("env: got %q; want %q", got, "exported")
+	}
+	if err := v.Unset(name); err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	if _, ok := v.Get(name); ok {
+		t.Error("var should be unset")
+	}
+}
+
+func TestVarsWith(t *testing.T) {
+	const a, b = "DEV08_TEST_A", "DEV08_TEST_B"
+	os.Unsetenv(b)
+	defer os.Unsetenv(a)
+	defer os.Unsetenv(b)
+	v := NewVars()
+	v.Set(a, "local")
+
+	restore, err := v.With([]string{a + "=1", b + "=2"})
+	if err != nil {
+		t.Fatalf("err should be nil: %s", err)
+	}
+	if os.Getenv(a) != "1" || os.Getenv(b) != "2" {
+		t.Errorf("env should have %s=1 %s=2, got %q %q", a, b, os.Getenv(a), os.Getenv(b))
+	}
+	restore()
+	if got, _ := v.Get(a); got != "local" || v.Exported(a) {
+		t.Errorf("%s should be local again, got %q exported %v", a, got, v.Exported(a))
+	}
+	if _, ok := v.Get(b); ok {
+		t.Errorf("%s should be unset again", b)
+	}
+}
+
+func TestIsAssignment(t *testing.T) {
+	testCases := []struct {
+		has  string
+		want bool
+	}{
+		{"A=1", true},
+		{"_a1=", true},
+		{"A=$B", true},
+		{"A='x y'", true},
+		{"1A=1", false},
+		{"A-B=1", false},
+		{"'A'=1", false},
+		{"A\\=1", false},
+		{"=1", false},
+		{"A", false},
+	}
+	for _, tc := range testCases {
+		tokens, err := lex(tc.has)
+		if err != nil {
+			t.Fatalf("%s: err should be nil: %s", tc.has, err)
+		}
+		if got := isAssignment(tokens[0].word); got != tc.want {
+			t.Errorf("%s: got %v; want %v", tc.has, got, tc.want)
+		}
+	}
+}
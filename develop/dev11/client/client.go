@@ -0,0 +1,365 @@
+// Пакет client - Go клиент для HTTP API календаря.
+package client
+
+import (
+	"context"
+	"dev11/endpoints"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Событие в том виде, в котором его возвращает сервер.
+// Праздники, которые сервер накладывает на события, отмечены полем
+// Overlay, у них Id и UserID равны -1 и их нельзя изменить
+type Event = endpoints.JsonEvent
+
+// Формат дат в параметрах запросов
+const dateLayout = "2006-01-02"
+
+// Клиент календаря
+type Client struct {
+	hc       *http.Client
+	baseURL  string
+	actor    string
+	token    string        // Токен для административных запросов
+	tenant   string        // Арендатор, пустая строка - арендатор по умолчанию
+	retries  int           // Сколько раз повторять идемпотентные запросы
+	backoff  time.Duration // Пауза перед первым повтором, далее удваивается
+	maxPause time.Duration // Максимальная пауза между повторами
+}
+
+// Создает клиента для сервера по адресу baseURL (например, http://127.0.0.1:8080).
+// Если hc == nil, то используется http.DefaultClient
+func New(hc *http.Client, baseURL string) *Client {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &Client{
+		hc:       hc,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		retries:  3,
+		backoff:  100 * time.Millisecond,
+		maxPause: 5 * time.Second,
+	}
+}
+
+// Возвращает копию клиента, изменения которого попадут в историю от имени actor.
+// Имя передается в X-Actor, сервер учитывает его, только если доверяет
+// этому заголовку (-user-header X-Actor за аутентифицирующим прокси)
+func (c *Client) WithActor(actor string) *Client {
+	res := *c
+	res.actor = actor
+	return &res
+}
+
+// Возвращает копию клиента, запросы которой выполняются для арендатора tenant
+func (c *Client) WithTenant(tenant string) *Client {
+	res := *c
+	res.tenant = tenant
+	return &res
+}
+
+// Возвращает копию клиента, которая передает token в административных запросах
+func (c *Client) WithToken(token string) *Client {
+	res := *c
+	res.token = token
+	return &res
+}
+
+// Возвращает копию клиента, которая повторяет идемпотентные запросы
+// до retries раз, начиная с паузы backoff
+func (c *Client) WithRetries(retries int, backoff time.Duration) *Client {
+	res := *c
+	res.retries = retries
+	res.backoff = backoff
+	return &res
+}
+
+// Необязательное поле события
+type EventOption func(v url.Values)
+
+// Заголовок события
+func Title(title string) EventOption {
+	return func(v url.Values) {
+		v.Set("title", title)
+	}
+}
+
+// Описание события
+func Description(description string) EventOption {
+	return func(v url.Values) {
+		v.Set("description", description)
+	}
+}
+
+func (c *Client) CreateEvent(ctx context.Context, userId int, date time.Time, opts ...EventOption) (Event, error) {
+	var res endpoints.JsonResultEvent
+	v := url.Values{
+		"user_id": {strconv.Itoa(userId)},
+		"date":    {date.Format(dateLayout)},
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	err := c.post(ctx, "/create_event", v, &res)
+	return res.Result, err
+}
+
+// Изменяет событие. Поля, не переданные в opts, становятся пустыми
+func (c *Client) UpdateEvent(ctx context.Context, id, userId int, date time.Time, opts ...EventOption) (Event, error) {
+	var res endpoints.JsonResultEvent
+	v := url.Values{
+		"id":      {strconv.Itoa(id)},
+		"user_id": {strconv.Itoa(userId)},
+		"date":    {date.Format(dateLayout)},
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	err := c.post(ctx, "/update_event", v, &res)
+	return res.Result, err
+}
+
+func (c *Client) DeleteEvent(ctx context.Context, id int) error {
+	var res endpoints.JsonResultString
+	return c.post(ctx, "/delete_event", url.Values{
+		"id": {strconv.Itoa(id)},
+	}, &res)
+}
+
+// События за день toDate
+func (c *Client) EventsForDay(ctx context.Context, toDate time.Time) ([]Event, error) {
+	return c.eventsFor(ctx, "/events_for_day", toDate)
+}
+
+// События за 7 дней до toDate включительно
+func (c *Client) EventsForWeek(ctx context.Context, toDate time.Time) ([]Event, error) {
+	return c.eventsFor(ctx, "/events_for_week", toDate)
+}
+
+// События за 30 дней до toDate включительно
+func (c *Client) EventsForMonth(ctx context.Context, toDate time.Time) ([]Event, error) {
+	return c.eventsFor(ctx, "/events_for_month", toDate)
+}
+
+func (c *Client) eventsFor(ctx context.Context, path string, toDate time.Time) ([]Event, error) {
+	var res endpoints.JsonResultListOfEvents
+	err := c.get(ctx, path, url.Values{"to_date": {toDate.Format(dateLayout)}}, &res)
+	return res.Result, err
+}
+
+// Параметры поиска, нулевые значения не ограничивают поиск
+type SearchQuery struct {
+	Text   string
+	UserId *int
+	From   time.Time
+	To     time.Time
+	Limit  int
+}
+
+// Найденное событие и его релевантность
+type SearchHit = endpoints.JsonSearchHit
+
+// Ищет события по заголовку и описанию, самые релевантные - первые
+func (c *Client) Search(ctx context.Context, q SearchQuery) ([]SearchHit, error) {
+	v := url.Values{"q": {q.Text}}
+	if q.UserId != nil {
+		v.Set("user_id", strconv.Itoa(*q.UserId))
+	}
+	if !q.From.IsZero() {
+		v.Set("from", q.From.Format(dateLayout))
+	}
+	if !q.To.IsZero() {
+		v.Set("to", q.To.Format(dateLayout))
+	}
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	var res endpoints.JsonResultSearch
+	err := c.get(ctx, "/search", v, &res)
+	return res.Result, err
+}
+
+// Выполняет пакет операций. Если пакет атомарный и одна из операций
+// не выполнилась, то возвращается ошибка и ни одна операция не применяется
+func (c *Client) Batch(ctx context.Context, req endpoints.JsonBatchRequest) ([]endpoints.JsonBatchItem, error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var res endpoints.JsonResultBatch
+	err = c.postBody(ctx, "/batch", "application/json", string(b), &res)
+	return res.Result, err
+}
+
+// События с датами из [from, to], включая праздники (Overlay != nil).
+// Сервер отдает события не более чем за 30 дней, поэтому диапазон
+// обходится окнами по 31 дню
+func (c *Client) EventsBetween(ctx context.Context, from, to time.Time) ([]Event, error) {
+	const window = 31 * 24 * time.Hour
+	var res []Event
+	fromStr := from.Format(dateLayout)
+	for cur := to; !cur.Before(from); cur = cur.Add(-window) {
+		list, err := c.EventsForMonth(ctx, cur)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range list {
+			// Даты в формате 2006-01-02 можно сравнивать как строки
+			if e.Date >= fromStr {
+				res = append(res, e)
+			}
+		}
+	}
+	return res, nil
+}
+
+// Записывает в w снимок всех событий сервера. Требует токен администратора
+func (c *Client) Snapshot(ctx context.Context, w io.Writer) error {
+	return c.get(ctx, "/admin/snapshot", url.Values{}, w)
+}
+
+// Заменяет все события сервера снимком из r. Требует токен администратора
+func (c *Client) RestoreSnapshot(ctx context.Context, r io.Reader) (string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	var res endpoints.JsonResultString
+	err = c.postBody(ctx, "/admin/restore", "application/gzip", string(b), &res)
+	return res.Result, err
+}
+
+// POST запросы изменяют данные, поэтому не повторяются
+func (c *Client) post(ctx context.Context, path string, form url.Values, out interface{}) error {
+	return c.postBody(ctx, path, "application/x-www-form-urlencoded", form.Encode(), out)
+}
+
+func (c *Client) postBody(ctx context.Context, path, contentType, body string, out interface{}) error {
+	return c.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, strings.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", contentType)
+		return req, nil
+	}, 0, out)
+}
+
+// GET запросы идемпотентны и повторяются при временных ошибках
+func (c *Client) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	u := c.baseURL + path + "?" + query.Encode()
+	return c.do(ctx, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	}, c.retries, out)
+}
+
+// Выполняет запрос, созданный newReq, повторяя его до retries раз
+func (c *Client) do(ctx context.Context, newReq func() (*http.Request, error), retries int, out interface{}) error {
+	pause := c.backoff
+	for attempt := 0; ; attempt++ {
+		err := c.once(newReq, out)
+		if err == nil || attempt >= retries || !retryable(ctx, err) {
+			return err
+		}
+
+		// Сервер может подсказать, когда повторить
+		wait := pause
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > wait {
+			wait = apiErr.RetryAfter
+		}
+		if wait > c.maxPause {
+			wait = c.maxPause
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		pause *= 2
+	}
+}
+
+// Можно ли повторить запрос после ошибки err
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.temporary()
+	}
+	// Повторяем только сетевые ошибки. *url.Error сам реализует net.Error,
+	// поэтому смотрим на ошибку внутри него: неверный адрес повторять бессмысленно
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Выполняет один запрос и разбирает конверт ответа
+func (c *Client) once(newReq func() (*http.Request, error), out interface{}) error {
+	req, err := newReq()
+	if err != nil {
+		return err
+	}
+	if c.actor != "" {
+		req.Header.Set("X-Actor", c.actor)
+	}
+	if c.tenant != "" {
+		req.Header.Set("X-Tenant", c.tenant)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var jerr endpoints.JsonError
+		if json.Unmarshal(b, &jerr) == nil && jerr.Error != "" {
+			apiErr.Message = jerr.Error
+			apiErr.Code = jerr.Code
+		} else {
+			apiErr.Message = strings.TrimSpace(string(b))
+		}
+		if sec, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			apiErr.RetryAfter = time.Duration(sec) * time.Second
+		}
+		return apiErr
+	}
+
+	// Ответы, которые не являются json документом, отдаются как есть
+	if w, ok := out.(io.Writer); ok {
+		_, err := w.Write(b)
+		return err
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("can't decode response: %w", err)
+	}
+	return nil
+}
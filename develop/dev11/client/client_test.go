@@ -0,0 +1,373 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"dev11/endpoints"
+	"dev11/holidays"
+	"dev11/logic"
+	"dev11/models"
+	"dev11/search"
+	"dev11/server"
+	"dev11/structs"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const testToken = "secret"
+
+// Поднимает сервер с настоящим мультиплексером
+func startServer(t *testing.T, wrap func(http.Handler) http.Handler) *httptest.Server {
+	t.Helper()
+	mb := server.NewMuxBuilder()
+	e := endpoints.NewEventHTTP(logic.NewEventAPI(models.NewEventModelMemory()).WithSearch(search.NewIndex()))
+	mb.AddEventHTTP(e)
+	mb.AddAdmin(e, testToken)
+	var h http.Handler = mb.Build()
+	if wrap != nil {
+		h = wrap(h)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestClientCRUD(t *testing.T) {
+	srv := startServer(t, nil)
+	c := New(srv.Client(), srv.URL)
+	ctx := context.Background()
+
+	ev, err := c.CreateEvent(ctx, 3, date(2019, 9, 9))
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if ev.Id != 0 || ev.UserID != 3 || ev.Date != "2019-09-09" {
+		t.Fatalf("unexpected event: %v", ev)
+	}
+
+	ev, err = c.UpdateEvent(ctx, 0, 4, date(2019, 9, 10))
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if ev.UserID != 4 || ev.Date != "2019-09-10" {
+		t.Fatalf("unexpected event: %v", ev)
+	}
+
+	for _, fn := range []func(context.Context, time.Time) ([]Event, error){
+		c.EventsForDay, c.EventsForWeek, c.EventsForMonth,
+	} {
+		list, err := fn(ctx, date(2019, 9, 10))
+		if err != nil {
+			t.Fatal("err should be nil", err)
+		}
+		if len(list) != 1 || list[0] != ev {
+			t.Fatalf("got %v; want [%v]", list, ev)
+		}
+	}
+
+	if err := c.DeleteEvent(ctx, 0); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	list, err := c.EventsForDay(ctx, date(2019, 9, 10))
+	if err != nil || len(list) != 0 {
+		t.Fatalf("got %v, %v; want empty list", list, err)
+	}
+}
+
+func TestClientTypedErrors(t *testing.T) {
+	srv := startServer(t, nil)
+	c := New(srv.Client(), srv.URL)
+	ctx := context.Background()
+
+	_, err := c.CreateEvent(ctx, -1, date(2019, 9, 9))
+	if !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("got %v; want ErrBadRequest", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "can't parse" {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if err := c.DeleteEvent(ctx, 100); !errors.Is(err, ErrServer) {
+		t.Fatalf("got %v; want ErrServer", err)
+	}
+}
+
+func TestClientForbiddenErrors(t *testing.T) {
+	// Квота определяется по коду ошибки, остальные 403 - просто запрет,
+	// даже если текст похож на ошибку квоты
+	quota := &APIError{StatusCode: http.StatusForbidden, Message: "operation 1: event quota exceeded", Code: "quota_exceeded"}
+	if !errors.Is(quota, ErrQuotaExceeded) || errors.Is(quota, ErrForbidden) {
+		t.Fatalf("got %v; want ErrQuotaExceeded", quota)
+	}
+	for _, e := range []*APIError{
+		{StatusCode: http.StatusForbidden, Message: "too many tenants", Code: "permission_denied"},
+		{StatusCode: http.StatusForbidden, Message: "event quota exceeded"},
+	} {
+		if !errors.Is(e, ErrForbidden) || errors.Is(e, ErrQuotaExceeded) {
+			t.Fatalf("got %v; want ErrForbidden", e)
+		}
+	}
+
+	// Код приходит от сервера в конверте ошибки
+	m := models.NewEventModelMemory()
+	m.SetQuota(1)
+	mb := server.NewMuxBuilder()
+	mb.AddEventHTTP(endpoints.NewEventHTTP(logic.NewEventAPI(m)))
+	srv := httptest.NewServer(mb.Build())
+	t.Cleanup(srv.Close)
+	c := New(srv.Client(), srv.URL)
+	ctx := context.Background()
+	if _, err := c.CreateEvent(ctx, 3, date(2019, 9, 9)); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	_, err := c.CreateEvent(ctx, 3, date(2019, 9, 9))
+	var apiErr *APIError
+	if !errors.Is(err, ErrQuotaExceeded) || !errors.As(err, &apiErr) || apiErr.Code != "quota_exceeded" {
+		t.Fatalf("got %#v; want ErrQuotaExceeded", err)
+	}
+}
+
+// Отдает 503 первые n запросов
+func flaky(n int32, calls *int32) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(calls, 1) <= n {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = w.Write([]byte(`{"error":"try later"}`))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestClientRetriesIdempotent(t *testing.T) {
+	var calls int32
+	srv := startServer(t, flaky(2, &calls))
+	c := New(srv.Client(), srv.URL).WithRetries(3, time.Millisecond)
+
+	if _, err := c.EventsForDay(context.Background(), date(2019, 9, 9)); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if calls != 3 {
+		t.Fatalf("got %d calls; want 3", calls)
+	}
+}
+
+func TestClientDoesNotRetryPost(t *testing.T) {
+	var calls int32
+	srv := startServer(t, flaky(1, &calls))
+	c := New(srv.Client(), srv.URL).WithRetries(3, time.Millisecond)
+
+	_, err := c.CreateEvent(context.Background(), 3, date(2019, 9, 9))
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("got %v; want ErrUnavailable", err)
+	}
+	if calls != 1 {
+		t.Fatalf("got %d calls; want 1", calls)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"refused", &url.Error{Op: "Get", URL: "http://x", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}, true},
+		{"reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"timeout", &url.Error{Op: "Get", URL: "http://x", Err: &net.DNSError{IsTimeout: true}}, true},
+		{"bad scheme", &url.Error{Op: "Get", URL: "ftp://x", Err: errors.New("unsupported protocol scheme")}, false},
+		{"decode", fmt.Errorf("can't decode response: %w", &json.SyntaxError{}), false},
+		{"request", errors.New("net/http: invalid method"), false},
+		{"unavailable", &APIError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"bad request", &APIError{StatusCode: http.StatusBadRequest}, false},
+	}
+	for _, tc := range cases {
+		if got := retryable(ctx, tc.err); got != tc.want {
+			t.Errorf("%s: got %v; want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// Ответ, который не удалось разобрать, не повторяется
+func TestClientDoesNotRetryDecodeError(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		_, _ = w.Write([]byte(`{"result":`))
+	}))
+	t.Cleanup(srv.Close)
+	c := New(srv.Client(), srv.URL).WithRetries(3, time.Millisecond)
+
+	if _, err := c.EventsForDay(context.Background(), date(2019, 9, 9)); err == nil {
+		t.Fatal("err should not be nil")
+	}
+	if calls != 1 {
+		t.Fatalf("got %d calls; want 1", calls)
+	}
+}
+
+func TestClientContextCancel(t *testing.T) {
+	var calls int32
+	srv := startServer(t, flaky(100, &calls))
+	c := New(srv.Client(), srv.URL).WithRetries(100, time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.EventsForDay(ctx, date(2019, 9, 9))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v; want deadline exceeded", err)
+	}
+}
+
+func TestClientBatchAndRange(t *testing.T) {
+	srv := startServer(t, nil)
+	c := New(srv.Client(), srv.URL)
+	ctx := context.Background()
+
+	userId := 1
+	var ops []endpoints.JsonBatchOp
+	for _, d := range []string{"2019-01-01", "2019-02-15", "2019-04-01", "2019-06-01"} {
+		d := d
+		ops = append(ops, endpoints.JsonBatchOp{Op: "create", UserId: &userId, Date: &d})
+	}
+	items, err := c.Batch(ctx, endpoints.JsonBatchRequest{Ops: ops})
+	if err != nil || len(items) != len(ops) {
+		t.Fatalf("got %v, %v; want %d items", items, err, len(ops))
+	}
+
+	list, err := c.EventsBetween(ctx, date(2019, 1, 1), date(2019, 4, 1))
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("got %v; want 3 events", list)
+	}
+
+	// Атомарный пакет с ошибкой
+	badId := 100
+	_, err = c.Batch(ctx, endpoints.JsonBatchRequest{Ops: []endpoints.JsonBatchOp{{Op: "delete", Id: &badId}}})
+	if !errors.Is(err, ErrServer) {
+		t.Fatalf("got %v; want ErrServer", err)
+	}
+}
+
+// Праздники отличаются от событий полем Overlay
+func TestClientHolidayOverlay(t *testing.T) {
+	cal := holidays.NewCalendar([]structs.Holiday{{Date: date(2019, 3, 8), Name: "8 марта", Region: "ru"}})
+	mb := server.NewMuxBuilder()
+	mb.AddEventHTTP(endpoints.NewEventHTTP(logic.NewEventAPI(models.NewEventModelMemory()).WithHolidays(cal, "ru")))
+	srv := httptest.NewServer(mb.Build())
+	t.Cleanup(srv.Close)
+	c := New(srv.Client(), srv.URL)
+	ctx := context.Background()
+
+	if _, err := c.CreateEvent(ctx, 3, date(2019, 3, 1)); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	list, err := c.EventsBetween(ctx, date(2019, 1, 1), date(2019, 4, 1))
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	var events, overlays []Event
+	for _, e := range list {
+		if e.Overlay != nil {
+			overlays = append(overlays, e)
+		} else {
+			events = append(events, e)
+		}
+	}
+	if len(events) != 1 || events[0].Id != 0 {
+		t.Fatalf("got events %v; want one event", events)
+	}
+	if len(overlays) != 1 || overlays[0].Id != -1 || overlays[0].Overlay.Kind != "holiday" || overlays[0].Date != "2019-03-08" {
+		t.Fatalf("got overlays %v; want 8 марта", overlays)
+	}
+}
+
+func TestClientSnapshotRestore(t *testing.T) {
+	srv := startServer(t, nil)
+	c := New(srv.Client(), srv.URL)
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	if err := c.Snapshot(ctx, &buf); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("got %v; want ErrUnauthorized", err)
+	}
+
+	admin := c.WithToken(testToken)
+	if _, err := c.CreateEvent(ctx, 3, date(2019, 9, 9)); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if err := admin.Snapshot(ctx, &buf); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	snap := buf.Bytes()
+
+	// После восстановления событие, созданное после снимка, пропадает
+	if _, err := c.CreateEvent(ctx, 4, date(2019, 9, 9)); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if _, err := admin.RestoreSnapshot(ctx, bytes.NewReader(snap)); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	list, err := c.EventsForDay(ctx, date(2019, 9, 9))
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if len(list) != 1 || list[0].UserID != 3 {
+		t.Fatalf("got %+v; want one event of user 3", list)
+	}
+
+	if _, err := admin.RestoreSnapshot(ctx, bytes.NewReader([]byte("junk"))); !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("got %v; want ErrBadRequest", err)
+	}
+}
+
+func TestClientSearch(t *testing.T) {
+	srv := startServer(t, nil)
+	c := New(srv.Client(), srv.URL)
+	ctx := context.Background()
+
+	ev, err := c.CreateEvent(ctx, 3, date(2019, 9, 9), Title("Встреча"), Description("С заказчиком"))
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if ev.Title != "Встреча" || ev.Description != "С заказчиком" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+	if _, err := c.CreateEvent(ctx, 4, date(2019, 9, 9), Title("Встреча")); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+
+	user := 3
+	hits, err := c.Search(ctx, SearchQuery{Text: "встре", UserId: &user, From: date(2019, 9, 1)})
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if len(hits) != 1 || hits[0].Event.Id != ev.Id || hits[0].Score <= 0 {
+		t.Fatalf("unexpected hits: %+v", hits)
+	}
+
+	if _, err := c.Search(ctx, SearchQuery{}); !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("got %v; want ErrBadRequest", err)
+	}
+}
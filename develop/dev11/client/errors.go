@@ -0,0 +1,71 @@
+package client
+
+import (
+	"dev11/response"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Виды ошибок сервера, проверяются через errors.Is
+var (
+	ErrBadRequest       = errors.New("bad request")
+	ErrUnauthorized     = errors.New("unauthorized")
+	ErrForbidden        = errors.New("forbidden")
+	ErrQuotaExceeded    = errors.New("event quota exceeded")
+	ErrNotFound         = errors.New("not found")
+	ErrMethodNotAllowed = errors.New("method not allowed")
+	ErrTooLarge         = errors.New("request body too large")
+	ErrRateLimited      = errors.New("rate limited")
+	ErrServer           = errors.New("server error")
+	ErrUnavailable      = errors.New("service unavailable")
+)
+
+// Ошибка, которую вернул сервер в конверте {"error": "...", "code": "..."}
+type APIError struct {
+	StatusCode int
+	Message    string
+	Code       string        // Машиночитаемый код, пустой, если сервер его не прислал
+	RetryAfter time.Duration // Для 429 и 503, если сервер прислал Retry-After
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Позволяет писать errors.Is(err, client.ErrBadRequest)
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusBadRequest:
+		return ErrBadRequest
+	case e.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusForbidden:
+		// 403 бывает не только из-за квоты, поэтому смотрим на код ошибки
+		if e.Code == response.CodeQuotaExceeded {
+			return ErrQuotaExceeded
+		}
+		return ErrForbidden
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusMethodNotAllowed:
+		return ErrMethodNotAllowed
+	case e.StatusCode == http.StatusRequestEntityTooLarge:
+		return ErrTooLarge
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode == http.StatusBadGateway,
+		e.StatusCode == http.StatusServiceUnavailable,
+		e.StatusCode == http.StatusGatewayTimeout:
+		return ErrUnavailable
+	case e.StatusCode >= 500:
+		return ErrServer
+	}
+	return nil
+}
+
+// Можно ли повторить запрос, который завершился такой ошибкой
+func (e *APIError) temporary() bool {
+	return errors.Is(e, ErrRateLimited) || errors.Is(e, ErrUnavailable)
+}
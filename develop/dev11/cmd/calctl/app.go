@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"dev11/client"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Общее состояние команд
+type app struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	client *client.Client
+	output string // table или json
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+// Разбирает общие флаги, возвращает оставшиеся аргументы
+func parseGlobal(args []string, stdin io.Reader, stdout io.Writer) (*app, []string, error) {
+	fs := flag.NewFlagSet("calctl", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	server := fs.String("s", "http://127.0.0.1:8080", "адрес сервера")
+	output := fs.String("o", "table", "формат вывода: table или json")
+	actor := fs.String("actor", "calctl", "от чьего имени выполняются изменения, если сервер доверяет X-Actor")
+	token := fs.String("token", os.Getenv("CALCTL_TOKEN"), "токен администратора для dump и restore")
+	tenant := fs.String("tenant", "", "арендатор, по умолчанию определяется сервером")
+	timeout := fs.Duration("timeout", 30*time.Second, "таймаут выполнения команды")
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, fmt.Errorf("%w\n%s", err, usage)
+	}
+	if *output != "table" && *output != "json" {
+		return nil, nil, fmt.Errorf("unknown output format %q", *output)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	return &app{
+		ctx:    ctx,
+		cancel: cancel,
+		client: client.New(nil, *server).WithActor(*actor).WithToken(*token).WithTenant(*tenant),
+		output: *output,
+		stdin:  stdin,
+		stdout: stdout,
+	}, fs.Args(), nil
+}
+
+// Открывает файл для чтения, "" - stdin
+func (a *app) open(name string) (io.ReadCloser, error) {
+	if name == "" {
+		return io.NopCloser(a.stdin), nil
+	}
+	return os.Open(name)
+}
+
+// Создает файл для записи, "" - stdout
+func (a *app) create(name string) (io.WriteCloser, error) {
+	if name == "" {
+		return nopWriteCloser{a.stdout}, nil
+	}
+	return os.Create(name)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// Разбирает дату в формате 2006-01-02
+func parseDate(s string) (time.Time, error) {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return t, fmt.Errorf("bad date %q, want 2006-01-02", s)
+	}
+	return t, nil
+}
+
+// Флаг с датой
+type dateFlag struct {
+	t   time.Time
+	set bool
+}
+
+func (d *dateFlag) String() string {
+	if !d.set {
+		return ""
+	}
+	return d.t.Format("2006-01-02")
+}
+
+func (d *dateFlag) Set(s string) error {
+	t, err := parseDate(s)
+	if err != nil {
+		return err
+	}
+	d.t, d.set = t, true
+	return nil
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"dev11/client"
+	"dev11/endpoints"
+	"dev11/ics"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type command func(a *app, args []string) error
+
+var commands = map[string]command{
+	"day":     eventsFor((*client.Client).EventsForDay),
+	"week":    eventsFor((*client.Client).EventsForWeek),
+	"month":   eventsFor((*client.Client).EventsForMonth),
+	"create":  createCmd,
+	"update":  updateCmd,
+	"delete":  deleteCmd,
+	"export":  exportCmd,
+	"import":  importCmd,
+	"dump":    dumpCmd,
+	"restore": restoreCmd,
+	"search":  searchCmd,
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// Флаги -title и -description для create и update
+func textFlags(fs *flag.FlagSet) func() []client.EventOption {
+	title := fs.String("title", "", "заголовок события")
+	description := fs.String("description", "", "описание события")
+	return func() []client.EventOption {
+		return []client.EventOption{client.Title(*title), client.Description(*description)}
+	}
+}
+
+// Проверяет, что все флаги names заданы
+func requireFlags(fs *flag.FlagSet, names ...string) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, name := range names {
+		if !set[name] {
+			return fmt.Errorf("%s: -%s is required", fs.Name(), name)
+		}
+	}
+	return nil
+}
+
+func eventsFor(fn func(*client.Client, context.Context, time.Time) ([]client.Event, error)) command {
+	return func(a *app, args []string) error {
+		if len(args) != 1 {
+			return errors.New("want exactly one date argument")
+		}
+		date, err := parseDate(args[0])
+		if err != nil {
+			return err
+		}
+		list, err := fn(a.client, a.ctx, date)
+		if err != nil {
+			return err
+		}
+		return a.printEvents(list)
+	}
+}
+
+func createCmd(a *app, args []string) error {
+	fs := newFlagSet("create")
+	user := fs.Int("user", 0, "id пользователя")
+	var date dateFlag
+	fs.Var(&date, "date", "дата события")
+	text := textFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "user", "date"); err != nil {
+		return err
+	}
+
+	ev, err := a.client.CreateEvent(a.ctx, *user, date.t, text()...)
+	if err != nil {
+		return err
+	}
+	return a.printEvents([]client.Event{ev})
+}
+
+func updateCmd(a *app, args []string) error {
+	fs := newFlagSet("update")
+	id := fs.Int("id", 0, "id события")
+	user := fs.Int("user", 0, "id пользователя")
+	var date dateFlag
+	fs.Var(&date, "date", "дата события")
+	text := textFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "id", "user", "date"); err != nil {
+		return err
+	}
+
+	ev, err := a.client.UpdateEvent(a.ctx, *id, *user, date.t, text()...)
+	if err != nil {
+		return err
+	}
+	return a.printEvents([]client.Event{ev})
+}
+
+func deleteCmd(a *app, args []string) error {
+	fs := newFlagSet("delete")
+	id := fs.Int("id", 0, "id события")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "id"); err != nil {
+		return err
+	}
+
+	if err := a.client.DeleteEvent(a.ctx, *id); err != nil {
+		return err
+	}
+	return a.printMessage("deleted")
+}
+
+// Ищет события по словам из аргументов, самые релевантные - первые
+func searchCmd(a *app, args []string) error {
+	fs := newFlagSet("search")
+	user := fs.Int("user", -1, "id пользователя, по умолчанию все")
+	var from, to dateFlag
+	fs.Var(&from, "from", "начало периода")
+	fs.Var(&to, "to", "конец периода")
+	limit := fs.Int("limit", 0, "сколько событий вывести, по умолчанию решает сервер")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("search: no query")
+	}
+
+	q := client.SearchQuery{
+		Text:  strings.Join(fs.Args(), " "),
+		From:  from.t,
+		To:    to.t,
+		Limit: *limit,
+	}
+	if *user >= 0 {
+		q.UserId = user
+	}
+	hits, err := a.client.Search(a.ctx, q)
+	if err != nil {
+		return err
+	}
+	list := make([]client.Event, 0, len(hits))
+	for _, h := range hits {
+		list = append(list, h.Event)
+	}
+	return a.printEvents(list)
+}
+
+// Флаги -from, -to и -f для команд выгрузки
+func rangeFlags(name string, args []string) (from, to dateFlag, file string, err error) {
+	fs := newFlagSet(name)
+	fs.Var(&from, "from", "начало периода")
+	fs.Var(&to, "to", "конец периода")
+	f := fs.String("f", "", "файл, по умолчанию stdout")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	err = requireFlags(fs, "from", "to")
+	return from, to, *f, err
+}
+
+// Свойство .ics, в котором хранится id пользователя
+const icsUserProp = "X-DEV11-USER-ID"
+
+func exportCmd(a *app, args []string) error {
+	from, to, file, err := rangeFlags("export", args)
+	if err != nil {
+		return err
+	}
+	list, err := a.client.EventsBetween(a.ctx, from.t, to.t)
+	if err != nil {
+		return err
+	}
+
+	events := make([]ics.Event, 0, len(list))
+	for _, e := range list {
+		// Праздники не хранятся на сервере, их нельзя загрузить обратно
+		if e.Overlay != nil {
+			continue
+		}
+		date, err := parseDate(e.Date)
+		if err != nil {
+			return err
+		}
+		events = append(events, ics.Event{
+			UID:         fmt.Sprintf("%d@dev11", e.Id),
+			Summary:     e.Title,
+			Description: e.Description,
+			Date:        date,
+			Extra:       map[string]string{icsUserProp: strconv.Itoa(int(e.UserID))},
+		})
+	}
+
+	w, err := a.create(file)
+	if err != nil {
+		return err
+	}
+	if err := ics.Write(w, "-//dev11//calctl//RU", events); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func importCmd(a *app, args []string) error {
+	fs := newFlagSet("import")
+	file := fs.String("f", "", "файл .ics, по умолчанию stdin")
+	user := fs.Int("user", -1, "id пользователя для событий без "+icsUserProp)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	r, err := a.open(*file)
+	if err != nil {
+		return err
+	}
+	events, err := ics.Parse(r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+
+	ops := make([]endpoints.JsonBatchOp, 0, len(events))
+	for _, e := range events {
+		userId := *user
+		if s, ok := e.Extra[icsUserProp]; ok {
+			if userId, err = strconv.Atoi(s); err != nil {
+				return fmt.Errorf("event %s: bad %s", e.UID, icsUserProp)
+			}
+		}
+		if userId < 0 {
+			return fmt.Errorf("event %s: no %s, use -user", e.UID, icsUserProp)
+		}
+		op := createOp(userId, e.Date.Format("2006-01-02"))
+		if e.Summary != "" {
+			op.Title = &e.Summary
+		}
+		if e.Description != "" {
+			op.Description = &e.Description
+		}
+		ops = append(ops, op)
+	}
+	return a.applyCreates(ops)
+}
+
+// Выгружает снимок всех событий сервера, включая корзину
+func dumpCmd(a *app, args []string) error {
+	fs := newFlagSet("dump")
+	file := fs.String("f", "", "файл снимка, по умолчанию stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Пишем в буфер, чтобы не оставлять на диске неполный файл
+	var buf bytes.Buffer
+	if err := a.client.Snapshot(a.ctx, &buf); err != nil {
+		return err
+	}
+	w, err := a.create(*file)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Заменяет все события сервера снимком. Id событий сохраняются
+func restoreCmd(a *app, args []string) error {
+	fs := newFlagSet("restore")
+	file := fs.String("f", "", "файл снимка, по умолчанию stdin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	r, err := a.open(*file)
+	if err != nil {
+		return err
+	}
+	msg, err := a.client.RestoreSnapshot(a.ctx, r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+	return a.printMessage(msg)
+}
+
+func createOp(userId int, date string) endpoints.JsonBatchOp {
+	return endpoints.JsonBatchOp{Op: "create", UserId: &userId, Date: &date}
+}
+
+// Атомарно создает события и выводит их
+func (a *app) applyCreates(ops []endpoints.JsonBatchOp) error {
+	if len(ops) == 0 {
+		return a.printMessage("nothing to import")
+	}
+	items, err := a.client.Batch(a.ctx, endpoints.JsonBatchRequest{Ops: ops})
+	if err != nil {
+		return err
+	}
+
+	// Результат операции пришел как произвольный json, приводим его к событию
+	list := make([]client.Event, 0, len(items))
+	for _, item := range items {
+		b, err := json.Marshal(item.Result)
+		if err != nil {
+			return err
+		}
+		var ev client.Event
+		if err := json.Unmarshal(b, &ev); err != nil {
+			return err
+		}
+		list = append(list, ev)
+	}
+	return a.printEvents(list)
+}
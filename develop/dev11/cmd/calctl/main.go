@@ -0,0 +1,65 @@
+// calctl - утилита для администрирования сервера календаря.
+//
+//	calctl [-s http://127.0.0.1:8080] [-o table|json] [-actor name] [-tenant T] [-token T] <command> [args]
+//
+// Команды:
+//
+//	day|week|month <date>            события за день, 7 или 30 дней до date
+//	create -user N -date D [-title T] [-description T]
+//	                                 создать событие
+//	update -id N -user N -date D [-title T] [-description T]
+//	                                 изменить событие
+//	delete -id N                     удалить событие
+//	export -from D -to D [-f file]   выгрузить события в .ics
+//	import [-f file] [-user N]       загрузить события из .ics
+//	dump [-f file]                   выгрузить снимок всех событий
+//	restore [-f file]                заменить все события снимком
+//	search [-user N] [-from D] [-to D] [-limit N] words...
+//	                                 найти события по заголовку и описанию
+//
+// Если -f не задан, то используются stdin и stdout. Для dump и restore
+// нужен токен администратора: -token или переменная окружения CALCTL_TOKEN.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "calctl:", err)
+		os.Exit(1)
+	}
+}
+
+const usage = `usage: calctl [-s url] [-o table|json] [-actor name] [-tenant T] [-token T] <command> [args]
+commands:
+  day|week|month <date>
+  create -user N -date D [-title T] [-description T]
+  update -id N -user N -date D [-title T] [-description T]
+  delete -id N
+  export -from D -to D [-f file.ics]
+  import [-f file.ics] [-user N]
+  dump [-f file.snap.gz]
+  restore [-f file.snap.gz]
+  search [-user N] [-from D] [-to D] [-limit N] words...`
+
+// Точка входа, отделена от main для тестов
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	app, rest, err := parseGlobal(args, stdin, stdout)
+	if err != nil {
+		return err
+	}
+	defer app.cancel()
+	if len(rest) == 0 {
+		return fmt.Errorf("no command\n%s", usage)
+	}
+
+	cmd, ok := commands[rest[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%s", rest[0], usage)
+	}
+	return cmd(app, rest[1:])
+}
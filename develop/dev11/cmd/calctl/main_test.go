@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"dev11/endpoints"
+	"dev11/holidays"
+	"dev11/logic"
+	"dev11/models"
+	"dev11/search"
+	"dev11/server"
+	"dev11/structs"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startServer(t *testing.T) string {
+	t.Helper()
+	return serveAPI(t, logic.NewEventAPI(models.NewEventModelMemory()).WithSearch(search.NewIndex()))
+}
+
+// Сервер, который накладывает праздники региона ru
+func startHolidayServer(t *testing.T) string {
+	t.Helper()
+	cal := holidays.NewCalendar([]structs.Holiday{
+		{Date: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), Name: "Новый год", Region: "ru"},
+		{Date: time.Date(2019, 3, 8, 0, 0, 0, 0, time.UTC), Name: "8 марта", Region: "ru"},
+	})
+	return serveAPI(t, logic.NewEventAPI(models.NewEventModelMemory()).WithHolidays(cal, "ru"))
+}
+
+func serveAPI(t *testing.T, api *logic.EventAPI) string {
+	t.Helper()
+	mb := server.NewMuxBuilder()
+	e := endpoints.NewEventHTTP(api)
+	mb.AddEventHTTP(e)
+	mb.AddAdmin(e, "secret")
+	srv := httptest.NewServer(mb.Build())
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// Запускает calctl с аргументами args и возвращает stdout
+func runCalctl(t *testing.T, url, stdin string, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	err := run(append([]string{"-s", url}, args...), strings.NewReader(stdin), &out)
+	if err != nil {
+		t.Fatalf("calctl %v: %s", args, err)
+	}
+	return out.String()
+}
+
+func TestCalctlCRUD(t *testing.T) {
+	url := startServer(t)
+
+	out := runCalctl(t, url, "", "create", "-user", "3", "-date", "2019-09-09")
+	want := "ID  USER  DATE        DELETED\n0   3     2019-09-09  -\n"
+	if out != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", out, want)
+	}
+
+	out = runCalctl(t, url, "", "-o", "json", "update", "-id", "0", "-user", "4", "-date", "2019-09-10")
+	if out != `[{"id":0,"user_id":4,"date":"2019-09-10"}]`+"\n" {
+		t.Fatalf("unexpected output: %s", out)
+	}
+
+	out = runCalctl(t, url, "", "-o", "json", "week", "2019-09-12")
+	if out != `[{"id":0,"user_id":4,"date":"2019-09-10"}]`+"\n" {
+		t.Fatalf("unexpected output: %s", out)
+	}
+
+	if out := runCalctl(t, url, "", "delete", "-id", "0"); out != "deleted\n" {
+		t.Fatalf("unexpected output: %s", out)
+	}
+	if out := runCalctl(t, url, "", "-o", "json", "day", "2019-09-10"); out != "[]\n" {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestCalctlErrors(t *testing.T) {
+	url := startServer(t)
+	testCases := [][]string{
+		{},
+		{"nope"},
+		{"create", "-user", "3"},
+		{"create", "-user", "3", "-date", "09.09.2019"},
+		{"day"},
+		{"delete", "-id", "100"},
+		{"-o", "xml", "day", "2019-09-09"},
+	}
+	for _, args := range testCases {
+		var out bytes.Buffer
+		if err := run(append([]string{"-s", url}, args...), strings.NewReader(""), &out); err == nil {
+			t.Errorf("calctl %v: err should be not nil", args)
+		}
+	}
+}
+
+func TestCalctlExportImport(t *testing.T) {
+	src := startServer(t)
+	runCalctl(t, src, "", "create", "-user", "1", "-date", "2019-01-01", "-title", "Новый год")
+	runCalctl(t, src, "", "create", "-user", "2", "-date", "2019-03-01")
+
+	file := filepath.Join(t.TempDir(), "cal.ics")
+	runCalctl(t, src, "", "export", "-from", "2019-01-01", "-to", "2019-03-31", "-f", file)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "DTSTART;VALUE=DATE:20190301") {
+		t.Fatalf("unexpected ics:\n%s", data)
+	}
+
+	// Загружаем на другой сервер
+	dst := startServer(t)
+	runCalctl(t, dst, "", "import", "-f", file)
+	out := runCalctl(t, dst, "", "-o", "json", "day", "2019-01-01")
+	if !strings.Contains(out, `"user_id":1,"date":"2019-01-01","title":"Новый год"`) {
+		t.Fatalf("unexpected output: %s", out)
+	}
+	out = runCalctl(t, dst, "", "-o", "json", "day", "2019-03-01")
+	if !strings.Contains(out, `"user_id":2,"date":"2019-03-01"`) {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+// Праздники приходят вместе с событиями, но не попадают в .ics
+func TestCalctlExportImportHolidays(t *testing.T) {
+	src := startHolidayServer(t)
+	runCalctl(t, src, "", "create", "-user", "1", "-date", "2019-01-01", "-title", "Встреча")
+
+	file := filepath.Join(t.TempDir(), "cal.ics")
+	runCalctl(t, src, "", "export", "-from", "2019-01-01", "-to", "2019-03-31", "-f", file)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "UID:-1@dev11") || strings.Count(string(data), "BEGIN:VEVENT") != 1 {
+		t.Fatalf("unexpected ics:\n%s", data)
+	}
+
+	// Файл загружается на сервер с теми же праздниками
+	dst := startHolidayServer(t)
+	runCalctl(t, dst, "", "import", "-f", file)
+	out := runCalctl(t, dst, "", "-o", "json", "day", "2019-01-01")
+	want := `[{"id":-1,"user_id":-1,"date":"2019-01-01","title":"Новый год","overlay":{"kind":"holiday","region":"ru"}},` +
+		`{"id":0,"user_id":1,"date":"2019-01-01","title":"Встреча"}]` + "\n"
+	if out != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestCalctlDumpRestore(t *testing.T) {
+	src := startServer(t)
+	runCalctl(t, src, "", "create", "-user", "1", "-date", "2019-01-01")
+	runCalctl(t, src, "", "create", "-user", "2", "-date", "2019-01-01")
+	runCalctl(t, src, "", "delete", "-id", "0")
+
+	// Без токена
+	var out bytes.Buffer
+	if err := run([]string{"-s", src, "dump"}, nil, &out); err == nil {
+		t.Fatal("err should not be nil")
+	}
+
+	dump := runCalctl(t, src, "", "-token", "secret", "dump")
+
+	dst := startServer(t)
+	if got := runCalctl(t, dst, dump, "-token", "secret", "restore"); got != "restored 2 events\n" {
+		t.Fatalf("unexpected output: %s", got)
+	}
+	// Id сохраняются, удаленное событие остается в корзине
+	got := runCalctl(t, dst, "", "-o", "json", "day", "2019-01-01")
+	if got != `[{"id":1,"user_id":2,"date":"2019-01-01"}]`+"\n" {
+		t.Fatalf("unexpected output: %s", got)
+	}
+	got = runCalctl(t, dst, "", "-o", "json", "create", "-user", "3", "-date", "2019-01-02")
+	if got != `[{"id":2,"user_id":3,"date":"2019-01-02"}]`+"\n" {
+		t.Fatalf("unexpected output: %s", got)
+	}
+}
+
+func TestCalctlSearch(t *testing.T) {
+	url := startServer(t)
+	runCalctl(t, url, "", "create", "-user", "1", "-date", "2019-01-01", "-title", "Встреча", "-description", "С заказчиком")
+	runCalctl(t, url, "", "create", "-user", "2", "-date", "2019-01-02", "-title", "Обед")
+
+	out := runCalctl(t, url, "", "-o", "json", "search", "-user", "1", "заказ")
+	want := `[{"id":0,"user_id":1,"date":"2019-01-01","title":"Встреча","description":"С заказчиком"}]` + "\n"
+	if out != want {
+		t.Fatalf("got %s; want %s", out, want)
+	}
+	if out := runCalctl(t, url, "", "-o", "json", "search", "ужин"); out != "[]\n" {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
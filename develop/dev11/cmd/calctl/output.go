@@ -0,0 +1,38 @@
+package main
+
+import (
+	"dev11/client"
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+)
+
+// Выводит список событий в выбранном формате
+func (a *app) printEvents(list []client.Event) error {
+	if a.output == "json" {
+		if list == nil {
+			list = []client.Event{}
+		}
+		return json.NewEncoder(a.stdout).Encode(list)
+	}
+
+	tw := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUSER\tDATE\tDELETED")
+	for _, e := range list {
+		deleted := "-"
+		if e.DeletedAt != "" {
+			deleted = e.DeletedAt
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\n", e.Id, e.UserID, e.Date, deleted)
+	}
+	return tw.Flush()
+}
+
+// Выводит сообщение в выбранном формате
+func (a *app) printMessage(msg string) error {
+	if a.output == "json" {
+		return json.NewEncoder(a.stdout).Encode(map[string]string{"result": msg})
+	}
+	_, err := fmt.Fprintln(a.stdout, msg)
+	return err
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"dev11/client"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Слова для заголовков создаваемых событий и поисковых запросов
+var words = []string{"встреча", "обед", "звонок", "ревью", "планирование", "релиз", "отпуск", "собеседование"}
+
+// Id событий, созданных генератором. Событие выдается одному воркеру
+// за раз, чтобы update и delete одного события не мешали друг другу
+type idPool struct {
+	lock sync.Mutex
+	ids  []int
+}
+
+func (p *idPool) put(id int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.ids = append(p.ids, id)
+}
+
+// Забирает случайный id из пула
+func (p *idPool) take(rnd *rand.Rand) (int, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(p.ids) == 0 {
+		return 0, false
+	}
+	i := rnd.Intn(len(p.ids))
+	id := p.ids[i]
+	back := len(p.ids) - 1
+	p.ids[i] = p.ids[back]
+	p.ids = p.ids[:back]
+	return id, true
+}
+
+// Генератор нагрузки
+type loader struct {
+	client  *client.Client
+	mix     mix
+	users   int
+	timeout time.Duration // Таймаут одного запроса
+	stats   *stats
+	ids     idPool
+	base    time.Time // Первый день, на который создаются события
+	days    int       // Сколько дней занимают события
+}
+
+// Случайная дата из диапазона событий
+func (l *loader) date(rnd *rand.Rand) time.Time {
+	return l.base.AddDate(0, 0, rnd.Intn(l.days))
+}
+
+// Посылает запросы, пока не закончится stop или не будет исчерпан remaining.
+// ctx ограничивает сами запросы, tokens задает темп, nil - без ограничений
+func (l *loader) worker(ctx, stop context.Context, rnd *rand.Rand, tokens <-chan struct{}, remaining *int64) {
+	for {
+		if tokens != nil {
+			select {
+			case <-stop.Done():
+				return
+			case <-tokens:
+			}
+		}
+		if stop.Err() != nil {
+			return
+		}
+		if remaining != nil && atomic.AddInt64(remaining, -1) < 0 {
+			return
+		}
+		l.request(ctx, l.mix.pick(rnd), rnd)
+	}
+}
+
+// Выполняет один запрос вида op и записывает результат
+func (l *loader) request(ctx context.Context, op string, rnd *rand.Rand) {
+	// Изменять нечего, пока ничего не создано
+	id, ok := 0, false
+	if op == "update" || op == "delete" {
+		if id, ok = l.ids.take(rnd); !ok {
+			op = "create"
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+	userId := rnd.Intn(l.users)
+	title := client.Title(words[rnd.Intn(len(words))])
+	start := time.Now()
+	var err error
+	switch op {
+	case "create":
+		var ev client.Event
+		if ev, err = l.client.CreateEvent(ctx, userId, l.date(rnd), title); err == nil {
+			l.ids.put(int(ev.Id))
+		}
+	case "update":
+		_, err = l.client.UpdateEvent(ctx, id, userId, l.date(rnd), title)
+		l.ids.put(id)
+	case "delete":
+		err = l.client.DeleteEvent(ctx, id)
+	case "day":
+		_, err = l.client.EventsForDay(ctx, l.date(rnd))
+	case "week":
+		_, err = l.client.EventsForWeek(ctx, l.date(rnd))
+	case "month":
+		_, err = l.client.EventsForMonth(ctx, l.date(rnd))
+	case "search":
+		_, err = l.client.Search(ctx, client.SearchQuery{Text: words[rnd.Intn(len(words))], Limit: 10})
+	}
+	l.stats.record(op, time.Since(start), err)
+}
+
+// Запускает concurrency воркеров и ждет их завершения. Если requests > 0,
+// то посылается ровно столько запросов, rate > 0 ограничивает их частоту
+func (l *loader) run(ctx, stop context.Context, concurrency, requests int, rate float64, seed int64) time.Duration {
+	var tokens chan struct{}
+	if rate > 0 {
+		tokens = make(chan struct{})
+		go func() {
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop.Done():
+					return
+				case <-ticker.C:
+					// Если все воркеры заняты, то такт пропускается
+					select {
+					case tokens <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}()
+	}
+	var remaining *int64
+	if requests > 0 {
+		n := int64(requests)
+		remaining = &n
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(rnd *rand.Rand) {
+			defer wg.Done()
+			l.worker(ctx, stop, rnd, tokens, remaining)
+		}(rand.New(rand.NewSource(seed + int64(i))))
+	}
+	wg.Wait()
+	return time.Since(start)
+}
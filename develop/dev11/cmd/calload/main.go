@@ -0,0 +1,98 @@
+// calload - генератор нагрузки для сервера календаря.
+//
+//	calload [-s http://127.0.0.1:8080] [-c 16] [-d 10s] [-n N] [-rate R] [-mix create=15,day=30,...] [-o table|json]
+//
+// Несколько воркеров посылают запросы create/update/delete, events_for_*
+// и search в заданной пропорции. После прогона выводится пропускная
+// способность, перцентили задержки и ошибки по видам запросов.
+// Прогон завершается по -d, после -n запросов или по Ctrl-C.
+package main
+
+import (
+	"context"
+	"dev11/client"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// Самый большой темп, такт которого еще не равен нулю
+const maxRate = float64(time.Second)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "calload:", err)
+		os.Exit(1)
+	}
+}
+
+// Точка входа, отделена от main для тестов
+func run(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("calload", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	server := fs.String("s", "http://127.0.0.1:8080", "адрес сервера")
+	concurrency := fs.Int("c", 16, "количество одновременных клиентов")
+	duration := fs.Duration("d", 10*time.Second, "длительность прогона")
+	requests := fs.Int("n", 0, "сколько запросов послать, 0 - до окончания -d")
+	rate := fs.Float64("rate", 0, "сколько запросов в секунду посылать всего, 0 - без ограничений")
+	mixFlag := fs.String("mix", defaultMix, "доли запросов: "+fmt.Sprint(operations))
+	users := fs.Int("users", 100, "количество пользователей, для которых создаются события")
+	days := fs.Int("days", 365, "на сколько дней с 2019-01-01 распределены события")
+	timeout := fs.Duration("timeout", 5*time.Second, "таймаут одного запроса")
+	tenant := fs.String("tenant", "", "арендатор, по умолчанию определяется сервером")
+	seed := fs.Int64("seed", 0, "seed генератора, 0 - случайный")
+	output := fs.String("o", "table", "формат отчета: table или json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	if *output != "table" && *output != "json" {
+		return fmt.Errorf("unknown output format %q", *output)
+	}
+	if *concurrency < 1 || *users < 1 || *days < 1 {
+		return errors.New("-c, -users and -days should be positive")
+	}
+	// Такт темпа - time.Second / rate, он должен быть не меньше наносекунды.
+	// Условие записано так, чтобы отсечь и NaN
+	if !(*rate >= 0 && *rate <= maxRate) {
+		return fmt.Errorf("-rate should be between 0 and %g", maxRate)
+	}
+	m, err := parseMix(*mixFlag)
+	if err != nil {
+		return err
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	// По умолчанию http.Transport держит 2 соединения на хост,
+	// остальные воркеры открывали бы новые на каждый запрос
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = *concurrency
+	hc := &http.Client{Transport: transport}
+	defer transport.CloseIdleConnections()
+
+	l := &loader{
+		// Повторы спрятали бы ошибки и исказили задержки
+		client:  client.New(hc, *server).WithActor("calload").WithTenant(*tenant).WithRetries(0, 0),
+		mix:     m,
+		users:   *users,
+		timeout: *timeout,
+		stats:   newStats(),
+		base:    time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		days:    *days,
+	}
+	stopCtx, cancel := context.WithTimeout(ctx, *duration)
+	defer cancel()
+	elapsed := l.run(ctx, stopCtx, *concurrency, *requests, *rate, *seed)
+	return l.stats.report(elapsed).write(stdout, *output)
+}
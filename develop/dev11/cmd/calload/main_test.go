@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"dev11/endpoints"
+	"dev11/logic"
+	"dev11/middleware"
+	"dev11/models"
+	"dev11/search"
+	"dev11/server"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startServer(t *testing.T, limiter *middleware.RateLimiter) string {
+	t.Helper()
+	mb := server.NewMuxBuilder()
+	if limiter != nil {
+		mb.SetRateLimiter(limiter, nil)
+	}
+	mb.AddEventHTTP(endpoints.NewEventHTTP(logic.NewEventAPI(models.NewEventModelMemory()).WithSearch(search.NewIndex())))
+	srv := httptest.NewServer(mb.Build())
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// Запускает calload и разбирает json отчет
+func runCalload(t *testing.T, args ...string) Report {
+	t.Helper()
+	var out bytes.Buffer
+	if err := run(context.Background(), append(args, "-o", "json"), &out); err != nil {
+		t.Fatalf("calload %v: %s", args, err)
+	}
+	var r Report
+	if err := json.Unmarshal(out.Bytes(), &r); err != nil {
+		t.Fatalf("bad report %q: %s", out.String(), err)
+	}
+	return r
+}
+
+func TestParseMix(t *testing.T) {
+	m, err := parseMix("create=1, day=3")
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	counts := map[string]int{}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 4000; i++ {
+		counts[m.pick(rnd)]++
+	}
+	if len(counts) != 2 || counts["day"] < 2*counts["create"] {
+		t.Fatalf("unexpected distribution %v", counts)
+	}
+
+	for _, bad := range []string{"", "create", "drop=1", "day=-1", "day=x", "day=0"} {
+		if _, err := parseMix(bad); err == nil {
+			t.Errorf("%q: err should not be nil", bad)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var d []time.Duration
+	for i := 1; i <= 100; i++ {
+		d = append(d, time.Duration(i))
+	}
+	cases := map[float64]time.Duration{0: 1, 50: 50, 90: 90, 99: 99, 100: 100}
+	for p, want := range cases {
+		if got := percentile(d, p); got != want {
+			t.Errorf("p%v: got %d; want %d", p, got, want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("got %d; want 0", got)
+	}
+}
+
+func TestCalloadRequests(t *testing.T) {
+	url := startServer(t, nil)
+	r := runCalload(t, "-s", url, "-n", "300", "-c", "8", "-seed", "1")
+	if r.Total.Requests != 300 || r.Total.Errors != 0 {
+		t.Fatalf("unexpected total %+v", r.Total)
+	}
+	var sum int
+	for _, s := range r.Ops {
+		sum += s.Requests
+		if s.P50 > s.P99 || s.P99 > s.Max {
+			t.Errorf("%s: bad percentiles %+v", s.Op, s)
+		}
+	}
+	if sum != 300 || len(r.Ops) < 2 {
+		t.Fatalf("unexpected ops %+v", r.Ops)
+	}
+}
+
+func TestCalloadErrors(t *testing.T) {
+	// Сервер пропускает один запрос, остальные получают 429
+	url := startServer(t, middleware.NewRateLimiter(0.001, 1))
+	r := runCalload(t, "-s", url, "-n", "20", "-c", "2", "-mix", "day=1")
+	if r.Total.Errors != 19 || r.Total.ErrorKinds["429"] != 19 {
+		t.Fatalf("unexpected total %+v", r.Total)
+	}
+
+	var out bytes.Buffer
+	if err := run(context.Background(), []string{"-s", url, "-n", "1", "-mix", "day=1"}, &out); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if !strings.Contains(out.String(), "429:1") {
+		t.Fatalf("unexpected table:\n%s", out.String())
+	}
+}
+
+func TestCalloadBadRate(t *testing.T) {
+	for _, rate := range []string{"-1", "2e9", "NaN"} {
+		err := run(context.Background(), []string{"-s", "http://127.0.0.1:1", "-rate", rate}, io.Discard)
+		if err == nil || !strings.Contains(err.Error(), "-rate") {
+			t.Errorf("%s: got %v; want -rate error", rate, err)
+		}
+	}
+}
+
+func TestCalloadDuration(t *testing.T) {
+	url := startServer(t, nil)
+	start := time.Now()
+	r := runCalload(t, "-s", url, "-d", "200ms", "-c", "2", "-rate", "50")
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("run should stop after -d")
+	}
+	// Темп ограничен, поэтому запросов не больше, чем тактов
+	if r.Total.Requests == 0 || r.Total.Requests > 15 {
+		t.Fatalf("unexpected requests %d", r.Total.Requests)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Виды запросов, которые посылает генератор
+var operations = []string{"create", "update", "delete", "day", "week", "month", "search"}
+
+// Смесь запросов по умолчанию: в основном чтение
+const defaultMix = "create=15,update=10,delete=5,day=30,week=20,month=15,search=5"
+
+// Доли запросов каждого вида
+type mix struct {
+	ops     []string
+	weights []int // Накопленные веса, weights[len-1] - сумма
+}
+
+// Разбирает смесь вида "create=15,day=30". Вес задает долю запросов вида
+func parseMix(s string) (mix, error) {
+	known := make(map[string]bool, len(operations))
+	for _, op := range operations {
+		known[op] = true
+	}
+	weights := make(map[string]int)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || !known[name] {
+			return mix{}, fmt.Errorf("bad mix item %q, known operations: %s", part, strings.Join(operations, ", "))
+		}
+		w, err := strconv.Atoi(value)
+		if err != nil || w < 0 {
+			return mix{}, fmt.Errorf("bad weight in %q", part)
+		}
+		weights[name] += w
+	}
+
+	var m mix
+	// Порядок фиксирован, чтобы при одинаковом seed последовательность совпадала
+	names := make([]string, 0, len(weights))
+	for name := range weights {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	total := 0
+	for _, name := range names {
+		if weights[name] == 0 {
+			continue
+		}
+		total += weights[name]
+		m.ops = append(m.ops, name)
+		m.weights = append(m.weights, total)
+	}
+	if total == 0 {
+		return mix{}, fmt.Errorf("mix %q has no operations", s)
+	}
+	return m, nil
+}
+
+// Выбирает вид следующего запроса
+func (m mix) pick(rnd *rand.Rand) string {
+	n := rnd.Intn(m.weights[len(m.weights)-1])
+	i := sort.SearchInts(m.weights, n+1)
+	return m.ops[i]
+}
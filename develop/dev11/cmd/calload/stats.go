@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"dev11/client"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Результаты запросов одного вида
+type opStats struct {
+	latencies []time.Duration // Задержки всех запросов, включая ошибочные
+	errors    map[string]int  // Количество ошибок по классам
+}
+
+// Собирает результаты запросов со всех воркеров
+type stats struct {
+	lock sync.Mutex
+	ops  map[string]*opStats
+}
+
+func newStats() *stats {
+	return &stats{ops: make(map[string]*opStats)}
+}
+
+func (s *stats) record(op string, d time.Duration, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	st, ok := s.ops[op]
+	if !ok {
+		st = &opStats{errors: make(map[string]int)}
+		s.ops[op] = st
+	}
+	st.latencies = append(st.latencies, d)
+	if err != nil {
+		st.errors[errorClass(err)]++
+	}
+}
+
+// Класс ошибки для отчета: HTTP статус, timeout или network
+func errorClass(err error) string {
+	var apiErr *client.APIError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &apiErr):
+		return strconv.Itoa(apiErr.StatusCode)
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &netErr):
+		return "network"
+	}
+	return "other"
+}
+
+// Сводка по запросам одного вида или по всем запросам
+type Summary struct {
+	Op         string         `json:"op"`
+	Requests   int            `json:"requests"`
+	Errors     int            `json:"errors"`
+	Throughput float64        `json:"rps"`
+	Mean       time.Duration  `json:"mean_ns"`
+	P50        time.Duration  `json:"p50_ns"`
+	P90        time.Duration  `json:"p90_ns"`
+	P99        time.Duration  `json:"p99_ns"`
+	Max        time.Duration  `json:"max_ns"`
+	ErrorKinds map[string]int `json:"error_kinds,omitempty"`
+}
+
+// Отчет о прогоне
+type Report struct {
+	Elapsed time.Duration `json:"elapsed_ns"`
+	Total   Summary       `json:"total"`
+	Ops     []Summary     `json:"ops"`
+}
+
+// Перцентиль p (0..100) отсортированных задержек
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted))*p/100+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+func summarize(op string, latencies []time.Duration, errs map[string]int, elapsed time.Duration) Summary {
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	s := Summary{Op: op, Requests: len(sorted)}
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+	if len(sorted) > 0 {
+		s.Mean = sum / time.Duration(len(sorted))
+		s.Max = sorted[len(sorted)-1]
+	}
+	if elapsed > 0 {
+		s.Throughput = float64(len(sorted)) / elapsed.Seconds()
+	}
+	s.P50 = percentile(sorted, 50)
+	s.P90 = percentile(sorted, 90)
+	s.P99 = percentile(sorted, 99)
+	for kind, n := range errs {
+		if s.ErrorKinds == nil {
+			s.ErrorKinds = make(map[string]int)
+		}
+		s.ErrorKinds[kind] += n
+		s.Errors += n
+	}
+	return s
+}
+
+// Строит отчет по накопленным результатам
+func (s *stats) report(elapsed time.Duration) Report {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	r := Report{Elapsed: elapsed}
+	var all []time.Duration
+	allErrs := make(map[string]int)
+	for _, op := range operations {
+		st, ok := s.ops[op]
+		if !ok {
+			continue
+		}
+		r.Ops = append(r.Ops, summarize(op, st.latencies, st.errors, elapsed))
+		all = append(all, st.latencies...)
+		for kind, n := range st.errors {
+			allErrs[kind] += n
+		}
+	}
+	r.Total = summarize("total", all, allErrs, elapsed)
+	return r
+}
+
+// Выводит отчет таблицей или json
+func (r Report) write(w io.Writer, format string) error {
+	if format == "json" {
+		return json.NewEncoder(w).Encode(r)
+	}
+
+	fmt.Fprintf(w, "elapsed %s, %d requests, %.1f req/s, %d errors\n\n",
+		r.Elapsed.Round(time.Millisecond), r.Total.Requests, r.Total.Throughput, r.Total.Errors)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "OP\tREQS\tRPS\tMEAN\tP50\tP90\tP99\tMAX\tERRORS")
+	for _, s := range append(r.Ops, r.Total) {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Op, s.Requests, s.Throughput,
+			round(s.Mean), round(s.P50), round(s.P90), round(s.P99), round(s.Max), errorKinds(s.ErrorKinds))
+	}
+	return tw.Flush()
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
+
+// Ошибки по классам в виде "500:3 timeout:1"
+func errorKinds(kinds map[string]int) string {
+	if len(kinds) == 0 {
+		return "-"
+	}
+	names := make([]string, 0, len(kinds))
+	for k := range kinds {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var res string
+	for i, k := range names {
+		if i > 0 {
+			res += " "
+		}
+		res += fmt.Sprintf("%s:%d", k, kinds[k])
+	}
+	return res
+}
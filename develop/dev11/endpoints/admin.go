@@ -0,0 +1,72 @@
+package endpoints
+
+import (
+	"bytes"
+	"dev11/response"
+	"dev11/snapshot"
+	"dev11/structs"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Отдает снимок всех событий в виде файла
+func (e *EventHTTP) SnapshotHandle(w http.ResponseWriter, r *http.Request) {
+	// Бизнес логика
+	api, ok := e.apiFor(w, r)
+	if !ok {
+		return
+	}
+	s, err := api.Snapshot()
+	if err != nil {
+		e.errorResponse(w, response.CodeInternal, err.Error())
+		return
+	}
+
+	// Пишем в буфер, чтобы при ошибке успеть ответить json документом
+	var buf bytes.Buffer
+	if err := snapshot.Write(&buf, s); err != nil {
+		e.errorResponse(w, response.CodeInternal, err.Error())
+		return
+	}
+	name := fmt.Sprintf("events-%s.snap.gz", s.TakenAt.Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// Заменяет все события содержимым снимка из тела запроса
+func (e *EventHTTP) RestoreSnapshotHandle(w http.ResponseWriter, r *http.Request) {
+	// Считываем тело запроса
+	b, ok := e.readBody(w, r)
+	if !ok {
+		return
+	}
+	s, err := snapshot.Read(bytes.NewReader(b))
+	if err != nil {
+		e.errorResponse(w, response.CodeInvalidArgument, err.Error())
+		return
+	}
+
+	// Бизнес логика
+	api, ok := e.apiFor(w, r)
+	if !ok {
+		return
+	}
+	if err := api.LoadSnapshot(s); err != nil {
+		// Ошибки загрузки - это ошибки содержимого снимка, кроме квоты
+		code := response.CodeInvalidArgument
+		if errors.Is(err, structs.ErrQuotaExceeded) {
+			code = response.CodeQuotaExceeded
+		}
+		e.errorResponse(w, code, err.Error())
+		return
+	}
+
+	// Формируем ответ
+	res := JsonResultString{
+		Result: fmt.Sprintf("restored %d events", len(s.Events)),
+	}
+	e.jsonResponse(w, res, http.StatusOK)
+}
@@ -0,0 +1,147 @@
+package endpoints
+
+import (
+	"dev11/response"
+	"dev11/structs"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Тело запроса /batch:
+// {"best_effort":false,"ops":[{"op":"create","user_id":3,"date":"2019-09-09"},{"op":"delete","id":0}]}
+// По умолчанию пакет выполняется атомарно
+type JsonBatchRequest struct {
+	BestEffort bool          `json:"best_effort"`
+	Ops        []JsonBatchOp `json:"ops"`
+}
+
+// Одна операция пакета, поля соответствуют параметрам
+// /create_event, /update_event и /delete_event
+type JsonBatchOp struct {
+	Op          string  `json:"op"`
+	Id          *int    `json:"id,omitempty"`
+	UserId      *int    `json:"user_id,omitempty"`
+	Date        *string `json:"date,omitempty"`
+	Title       *string `json:"title,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+// Переводит операцию в url.Values, чтобы валидировать ее
+// теми же функциями, что и обычные запросы
+func (op JsonBatchOp) values() url.Values {
+	v := url.Values{}
+	if op.Id != nil {
+		v.Set("id", strconv.Itoa(*op.Id))
+	}
+	if op.UserId != nil {
+		v.Set("user_id", strconv.Itoa(*op.UserId))
+	}
+	if op.Date != nil {
+		v.Set("date", *op.Date)
+	}
+	if op.Title != nil {
+		v.Set("title", *op.Title)
+	}
+	if op.Description != nil {
+		v.Set("description", *op.Description)
+	}
+	return v
+}
+
+// Получает операцию пакета из JsonBatchOp, используется всеми транспортами
+func BatchOpFromJson(op JsonBatchOp) (structs.BatchOp, bool) {
+	v := op.values()
+	switch op.Op {
+	case "create":
+		if e, ok := EventNoIdFromValues(v); ok {
+			return structs.MakeBatchCreate(e), true
+		}
+	case "update":
+		if e, ok := EventFromValues(v); ok {
+			return structs.MakeBatchUpdate(e), true
+		}
+	case "delete":
+		if e, ok := IdFromUrlValues(structs.EventNoId{}, v); ok {
+			return structs.MakeBatchDelete(e.GetId()), true
+		}
+	}
+	return structs.BatchOp{}, false
+}
+
+// Результат одной операции пакета: либо result, либо error
+type JsonBatchItem struct {
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+func makeJsonBatchItems(ops []structs.BatchOp, res []structs.BatchResult) []JsonBatchItem {
+	items := make([]JsonBatchItem, len(res))
+	for i, r := range res {
+		switch {
+		case r.Err != nil:
+			items[i].Error = r.Err.Error()
+		case ops[i].Kind == structs.BatchDelete:
+			items[i].Result = "deleted"
+		default:
+			items[i].Result = makeJsonEvent(r.Event)
+		}
+	}
+	return items
+}
+
+// Структура, содержащая результаты пакета
+type JsonResultBatch struct {
+	Result []JsonBatchItem `json:"result"`
+}
+
+// Ошибка атомарного пакета вместе с результатами операций
+type JsonErrorBatch struct {
+	Error string          `json:"error"`
+	Code  string          `json:"code"`
+	Ops   []JsonBatchItem `json:"ops"`
+}
+
+func (e *EventHTTP) BatchHandle(w http.ResponseWriter, r *http.Request) {
+	// Считываем тело запроса
+	b, ok := e.readBody(w, r)
+	if !ok {
+		return
+	}
+	var req JsonBatchRequest
+	if err := json.Unmarshal(b, &req); err != nil {
+		e.errorResponse(w, response.CodeInvalidArgument, "can't parse")
+		return
+	}
+	ops := make([]structs.BatchOp, len(req.Ops))
+	for i, jop := range req.Ops {
+		op, ok := BatchOpFromJson(jop)
+		if !ok {
+			e.errorResponse(w, response.CodeInvalidArgument, fmt.Sprintf("can't parse operation %d", i))
+			return
+		}
+		ops[i] = op
+	}
+
+	// Бизнес логика
+	api, ok := e.apiFor(w, r)
+	if !ok {
+		return
+	}
+	res, err := api.Batch(ops, !req.BestEffort)
+	if err != nil {
+		e.jsonResponse(w, JsonErrorBatch{
+			Error: err.Error(),
+			Code:  response.Code(err),
+			Ops:   makeJsonBatchItems(ops, res),
+		}, businessStatus(err))
+		return
+	}
+
+	// Формируем ответ
+	e.jsonResponse(w, JsonResultBatch{
+		Result: makeJsonBatchItems(ops, res),
+	}, http.StatusOK)
+}
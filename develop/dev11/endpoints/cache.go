@@ -0,0 +1,60 @@
+package endpoints
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Слабый ETag ответа: тело может быть сжато по дороге к клиенту
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// Есть ли tag среди значений заголовка If-None-Match
+func etagMatches(header, tag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Отвечает 304, если у клиента уже есть ответ body, иначе отдает body.
+// modifiedAt - момент последнего изменения данных, нулевое значение - неизвестен
+func (e *EventHTTP) conditionalResponse(w http.ResponseWriter, r *http.Request, body []byte, modifiedAt time.Time) {
+	tag := etag(body)
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Cache-Control", "no-cache")
+	// Last-Modified хранит время с точностью до секунды. Пока идет секунда
+	// последнего изменения, в нее же может попасть следующее изменение
+	// с тем же Last-Modified, поэтому в это время работает только ETag
+	var lastModified time.Time
+	if !modifiedAt.IsZero() {
+		lastModified = modifiedAt.UTC().Truncate(time.Second)
+		if e.now().UTC().Truncate(time.Second).After(lastModified) {
+			w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+		} else {
+			lastModified = time.Time{}
+		}
+	}
+
+	// If-None-Match точнее, поэтому If-Modified-Since смотрим только без него
+	notModified := false
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		notModified = etagMatches(inm, tag)
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(ims)
+		notModified = err == nil && !lastModified.After(t)
+	}
+	if notModified {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	e.jsonResponseBytes(w, body, http.StatusOK)
+}
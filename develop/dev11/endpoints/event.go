@@ -2,6 +2,7 @@ package endpoints
 
 import (
 	"dev11/logic"
+	"dev11/response"
 	"dev11/structs"
 	"encoding/json"
 	"io"
@@ -10,70 +11,117 @@ import (
 )
 
 type EventHTTP struct {
-	api *logic.EventAPI
+	tenants *logic.Tenants
+	now     func() time.Time // Подменяется в тестах
 }
 
 func NewEventHTTP(api *logic.EventAPI) *EventHTTP {
+	return NewTenantEventHTTP(logic.SingleTenant(api))
+}
+
+// Обработчики, которые выбирают api по арендатору из контекста запроса
+func NewTenantEventHTTP(tenants *logic.Tenants) *EventHTTP {
 	return &EventHTTP{
-		api: api,
+		tenants: tenants,
+		now:     time.Now,
+	}
+}
+
+// Возвращает api арендатора запроса, операции которого
+// выполняются от имени автора запроса. Если арендатора нельзя
+// создать, то отвечает ошибкой
+func (e *EventHTTP) apiFor(w http.ResponseWriter, r *http.Request) (*logic.EventAPI, bool) {
+	api, err := e.tenants.ForContext(r.Context())
+	if err != nil {
+		e.businessError(w, err)
+		return nil, false
 	}
+	return api.WithActor(ActorFromRequest(r)), true
+}
+
+// HTTP статус для ошибки бизнес-логики, по той же таблице, что и в RPC
+func businessStatus(err error) int {
+	return response.Status(response.Code(err))
+}
+
+// Отвечает ошибкой msg с кодом code
+func (e *EventHTTP) errorResponse(w http.ResponseWriter, code, msg string) {
+	e.jsonResponse(w, JsonError{Error: msg, Code: code}, response.Status(code))
+}
+
+// Отвечает ошибкой бизнес-логики
+func (e *EventHTTP) businessError(w http.ResponseWriter, err error) {
+	e.errorResponse(w, response.Code(err), err.Error())
+}
+
+// Считывает тело запроса. Если тело слишком большое, то отвечает 413,
+// при остальных ошибках 400
+func (e *EventHTTP) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		code, msg := response.BodyError(err)
+		e.errorResponse(w, code, msg)
+		return nil, false
+	}
+	return b, true
 }
 
 // Формирует и отсылает json документ в w
 func (e *EventHTTP) jsonResponse(w http.ResponseWriter, r interface{}, statusCode int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	_ = json.NewEncoder(w).Encode(r)
+	response.JSON(w, r, statusCode)
 }
 
 func (e *EventHTTP) jsonResponseBytes(w http.ResponseWriter, b []byte, statusCode int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	_, _ = w.Write(b)
+	response.Bytes(w, b, statusCode)
 }
 
-// Структура для json сообщения об ошибки
-type jsonError struct {
+// Структура для json сообщения об ошибки, Code - машиночитаемый код
+// из пакета response, одинаковый для HTTP+JSON и RPC
+type JsonError struct {
 	Error string `json:"error"`
+	Code  string `json:"code"`
 }
 
 // Структура, содержащая один event
-type jsonResultEvent struct {
-	Result jsonEvent `json:"result"`
+type JsonResultEvent struct {
+	Result JsonEvent `json:"result"`
 }
 
 // Структура, содержащая строковый ответ
-type jsonResultString struct {
+type JsonResultString struct {
 	Result string `json:"result"`
 }
 
 // Структура, содержащая list of events
-type jsonResultListOfEvents struct {
-	Result []jsonEvent `json:"result"`
+type JsonResultListOfEvents struct {
+	Result []JsonEvent `json:"result"`
 }
 
 func (e *EventHTTP) CreateHandle(w http.ResponseWriter, r *http.Request) {
 	// Считываем тело запроса
-	b, err := io.ReadAll(r.Body)
-	if err != nil {
-		e.jsonResponse(w, jsonError{err.Error()}, http.StatusBadRequest)
+	b, ok := e.readBody(w, r)
+	if !ok {
 		return
 	}
 	newe, ok := eventNoIdFromUrlQuery(string(b))
 	if !ok {
-		e.jsonResponse(w, jsonError{"can't parse"}, http.StatusBadRequest)
+		e.errorResponse(w, response.CodeInvalidArgument, "can't parse")
 		return
 	}
 
 	// Бизнес логика
-	event, err := e.api.Create(newe)
+	api, ok := e.apiFor(w, r)
+	if !ok {
+		return
+	}
+	event, err := api.Create(newe)
 	if err != nil {
-		e.jsonResponse(w, jsonError{err.Error()}, http.StatusInternalServerError)
+		e.businessError(w, err)
 		return
 	}
 
 	// Формируем ответ
-	res := jsonResultEvent{
+	res := JsonResultEvent{
 		Result: makeJsonEvent(event),
 	}
 	e.jsonResponse(w, res, http.StatusOK)
@@ -81,26 +129,29 @@ func (e *EventHTTP) CreateHandle(w http.ResponseWriter, r *http.Request) {
 
 func (e *EventHTTP) UpdateHandle(w http.ResponseWriter, r *http.Request) {
 	// Считываем тело запроса
-	b, err := io.ReadAll(r.Body)
-	if err != nil {
-		e.jsonResponse(w, jsonError{err.Error()}, http.StatusBadRequest)
+	b, ok := e.readBody(w, r)
+	if !ok {
 		return
 	}
 	event, ok := eventFromUrlQuery(string(b))
 	if !ok {
-		e.jsonResponse(w, jsonError{"can't parse"}, http.StatusBadRequest)
+		e.errorResponse(w, response.CodeInvalidArgument, "can't parse")
 		return
 	}
 
 	// Бизнес логика
-	event, err = e.api.Update(event)
+	api, ok := e.apiFor(w, r)
+	if !ok {
+		return
+	}
+	event, err := api.Update(event)
 	if err != nil {
-		e.jsonResponse(w, jsonError{err.Error()}, http.StatusInternalServerError)
+		e.businessError(w, err)
 		return
 	}
 
 	// Формируем ответ
-	res := jsonResultEvent{
+	res := JsonResultEvent{
 		Result: makeJsonEvent(event),
 	}
 	e.jsonResponse(w, res, http.StatusOK)
@@ -108,46 +159,50 @@ func (e *EventHTTP) UpdateHandle(w http.ResponseWriter, r *http.Request) {
 
 func (e *EventHTTP) DeleteHandle(w http.ResponseWriter, r *http.Request) {
 	// Считываем тело запроса
-	b, err := io.ReadAll(r.Body)
-	if err != nil {
-		e.jsonResponse(w, jsonError{err.Error()}, http.StatusBadRequest)
+	b, ok := e.readBody(w, r)
+	if !ok {
 		return
 	}
 	event, ok := idFromUrlQuery(structs.EventNoId{}, string(b))
 	if !ok {
-		e.jsonResponse(w, jsonError{"can't parse"}, http.StatusBadRequest)
+		e.errorResponse(w, response.CodeInvalidArgument, "can't parse")
 		return
 	}
 
 	// Бизнес логика
-	err = e.api.Delete(event.GetId())
+	api, ok := e.apiFor(w, r)
+	if !ok {
+		return
+	}
+	err := api.Delete(event.GetId())
 	if err != nil {
-		e.jsonResponse(w, jsonError{err.Error()}, http.StatusInternalServerError)
+		e.businessError(w, err)
 		return
 	}
 
 	// Формируем ответ
-	res := jsonResultString{
+	res := JsonResultString{
 		Result: "deleted",
 	}
 	e.jsonResponse(w, res, http.StatusOK)
 }
 
-type forfunc func(time.Time) ([]structs.Event, error)
-
-func (e *EventHTTP) forFuncHandle(fn forfunc, w http.ResponseWriter, r *http.Request) {
-	// Получаем дату
-	v := r.URL.Query()
-	to_date, ok := freeDateFromUrlValues("to_date", v)
+func (e *EventHTTP) TrashHandle(w http.ResponseWriter, r *http.Request) {
+	// Получаем пользователя
+	eni, ok := userIdFromUrlValues(structs.EventNoId{}, r.URL.Query())
 	if !ok {
-		e.jsonResponse(w, jsonError{"can't parse"}, http.StatusBadRequest)
+		e.errorResponse(w, response.CodeInvalidArgument, "can't parse")
 		return
 	}
 
 	// Бизнес логика
-	list, err := fn(to_date)
+	api, ok := e.apiFor(w, r)
+	if !ok {
+		return
+	}
+	list, err := api.Trash(eni.GetUserId())
 	if err != nil {
-		e.jsonResponse(w, jsonError{err.Error()}, http.StatusInternalServerError)
+		e.businessError(w, err)
 		return
 	}
 
@@ -156,19 +211,105 @@ func (e *EventHTTP) forFuncHandle(fn forfunc, w http.ResponseWriter, r *http.Req
 		e.jsonResponseBytes(w, []byte(`{"result":[]}`), http.StatusOK)
 		return
 	}
-	res := jsonResultListOfEvents{
+	res := JsonResultListOfEvents{
 		Result: makeSliceJsonEvent(list),
 	}
 	e.jsonResponse(w, res, http.StatusOK)
 }
+
+func (e *EventHTTP) RestoreHandle(w http.ResponseWriter, r *http.Request) {
+	// Считываем тело запроса
+	b, ok := e.readBody(w, r)
+	if !ok {
+		return
+	}
+	event, ok := idFromUrlQuery(structs.EventNoId{}, string(b))
+	if !ok {
+		e.errorResponse(w, response.CodeInvalidArgument, "can't parse")
+		return
+	}
+
+	// Бизнес логика
+	api, ok := e.apiFor(w, r)
+	if !ok {
+		return
+	}
+	event, err := api.Restore(event.GetId())
+	if err != nil {
+		e.businessError(w, err)
+		return
+	}
+
+	// Формируем ответ
+	res := JsonResultEvent{
+		Result: makeJsonEvent(event),
+	}
+	e.jsonResponse(w, res, http.StatusOK)
+}
+
+type forfunc func(api *logic.EventAPI, date time.Time) ([]structs.Event, error)
+
+// Период, за который forfunc возвращает события
+type periodfunc func(date time.Time) (time.Time, time.Time)
+
+func (e *EventHTTP) forFuncHandle(fn forfunc, period periodfunc, w http.ResponseWriter, r *http.Request) {
+	// Получаем дату и регион праздников
+	v := r.URL.Query()
+	to_date, ok := FreeDateFromUrlValues("to_date", v)
+	if !ok {
+		e.errorResponse(w, response.CodeInvalidArgument, "can't parse")
+		return
+	}
+	region, ok := RegionFromValues(v)
+	if !ok {
+		e.errorResponse(w, response.CodeInvalidArgument, "can't parse")
+		return
+	}
+
+	// Бизнес логика. Момент изменения берем до выборки, чтобы
+	// старые данные не ушли с новым Last-Modified
+	api, ok := e.apiFor(w, r)
+	if !ok {
+		return
+	}
+	modifiedAt := api.ModifiedAt()
+	from, to := period(to_date)
+	holidays, err := api.Holidays(region, from, to)
+	if err != nil {
+		e.businessError(w, err)
+		return
+	}
+	list, err := fn(api, to_date)
+	if err != nil {
+		e.businessError(w, err)
+		return
+	}
+
+	// Формируем ответ, праздники идут перед событиями
+	if len(list) == 0 && len(holidays) == 0 {
+		e.conditionalResponse(w, r, []byte(`{"result":[]}`), modifiedAt)
+		return
+	}
+	res := JsonResultListOfEvents{}
+	for _, h := range holidays {
+		res.Result = append(res.Result, makeJsonHolidayEvent(h))
+	}
+	res.Result = append(res.Result, makeSliceJsonEvent(list)...)
+	b, err := json.Marshal(res)
+	if err != nil {
+		e.businessError(w, err)
+		return
+	}
+	e.conditionalResponse(w, r, append(b, '\n'), modifiedAt)
+}
 func (e *EventHTTP) ForDayHandle(w http.ResponseWriter, r *http.Request) {
-	e.forFuncHandle(e.api.ForDay, w, r)
+	e.forFuncHandle((*logic.EventAPI).ForDay, logic.DayPeriod, w, r)
 }
 
 func (e *EventHTTP) ForWeekHandle(w http.ResponseWriter, r *http.Request) {
-	e.forFuncHandle(e.api.ForWeek, w, r)
+	e.forFuncHandle((*logic.EventAPI).ForWeek, logic.WeekPeriod, w, r)
 }
 
 func (e *EventHTTP) ForMonthHandle(w http.ResponseWriter, r *http.Request) {
-	e.forFuncHandle(e.api.ForMonth, w, r)
+	e.forFuncHandle((*logic.EventAPI).ForMonth, logic.MonthPeriod, w, r)
 }
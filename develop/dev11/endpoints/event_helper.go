@@ -30,7 +30,8 @@ func userIdFromUrlValues(e structs.EventNoId, v url.Values) (structs.EventNoId,
 	return e, false
 }
 
-func freeDateFromUrlValues(key string, v url.Values) (time.Time, bool) {
+// Парсит дату с ключом key из url.Values
+func FreeDateFromUrlValues(key string, v url.Values) (time.Time, bool) {
 	var res time.Time
 	if date, ok := v[key]; ok {
 		if len(date) != 1 {
@@ -45,6 +46,12 @@ func freeDateFromUrlValues(key string, v url.Values) (time.Time, bool) {
 	return res, false
 }
 
+// Парсит user_id из url.Values, используется всеми транспортами
+func UserIdFromValues(v url.Values) (structs.UserID, bool) {
+	eni, ok := userIdFromUrlValues(structs.EventNoId{}, v)
+	return eni.GetUserId(), ok
+}
+
 // Парсит date в event из url.Values
 func dateFromUrlValues(e structs.EventNoId, v url.Values) (structs.EventNoId, bool) {
 	if date, ok := v["date"]; ok {
@@ -64,7 +71,24 @@ func dateFromUrlValues(e structs.EventNoId, v url.Values) (structs.EventNoId, bo
 	return e, true
 }
 
-func eventNoIdFromValues(values url.Values) (structs.EventNoId, bool) {
+// Парсит необязательные title и description в event из url.Values
+func textFromUrlValues(e structs.EventNoId, v url.Values) (structs.EventNoId, bool) {
+	if title, ok := v["title"]; ok {
+		if len(title) != 1 || !e.SetTitle(title[0]) {
+			return e, false
+		}
+	}
+	if description, ok := v["description"]; ok {
+		if len(description) != 1 || !e.SetDescription(description[0]) {
+			return e, false
+		}
+	}
+	return e, true
+}
+
+// Парсит eventNoId из url.Values. Используется всеми транспортами,
+// чтобы параметры валидировались одинаково
+func EventNoIdFromValues(values url.Values) (structs.EventNoId, bool) {
 	var res structs.EventNoId
 	// user_id
 	res, ok := userIdFromUrlValues(res, values)
@@ -76,23 +100,28 @@ func eventNoIdFromValues(values url.Values) (structs.EventNoId, bool) {
 	if !ok {
 		return res, false
 	}
+	// title, description
+	res, ok = textFromUrlValues(res, values)
+	if !ok {
+		return res, false
+	}
 	return res, true
 }
 
 // Получает eventNoId из urlquery
-// Все поля должны быть заполнены:
-// user_id=3&date=2019-09-09
+// Все поля, кроме title и description, должны быть заполнены:
+// user_id=3&date=2019-09-09&title=...
 func eventNoIdFromUrlQuery(q string) (structs.EventNoId, bool) {
 	var res structs.EventNoId
 	values, err := url.ParseQuery(q)
 	if err != nil {
 		return res, false
 	}
-	return eventNoIdFromValues(values)
+	return EventNoIdFromValues(values)
 }
 
 // Получает id из values
-func idFromUrlValues(e structs.EventNoId, v url.Values) (structs.Event, bool) {
+func IdFromUrlValues(e structs.EventNoId, v url.Values) (structs.Event, bool) {
 	var res structs.Event
 	if num, ok := parseIntFromValues("id", v); ok {
 		if res, ok := e.MakeEventWithId(structs.EventID(num)); ok {
@@ -122,20 +151,25 @@ func idFromUrlQuery(e structs.EventNoId, q string) (structs.Event, bool) {
 // Все поля должны быть заполнены:
 // id=0&user_id=3&date=2019-09-09
 func eventFromUrlQuery(q string) (structs.Event, bool) {
-	var res structs.Event
 	values, err := url.ParseQuery(q)
 	if err != nil {
-		return res, false
+		return structs.Event{}, false
 	}
+	return EventFromValues(values)
+}
+
+// Получает event из url.Values, все поля должны быть заполнены
+func EventFromValues(values url.Values) (structs.Event, bool) {
+	var res structs.Event
 
 	// EventNoId
-	eni, ok := eventNoIdFromValues(values)
+	eni, ok := EventNoIdFromValues(values)
 	if !ok {
 		return res, false
 	}
 
 	// id
-	res, ok = idFromUrlValues(eni, values)
+	res, ok = IdFromUrlValues(eni, values)
 	if !ok {
 		return res, false
 	}
@@ -144,34 +178,44 @@ func eventFromUrlQuery(q string) (structs.Event, bool) {
 }
 
 // json struct for Event
-type jsonEvent struct {
+type JsonEvent struct {
 	Id structs.EventID `json:"id"`
-	jsonEventNoId
+	JsonEventNoId
+	DeletedAt string       `json:"deleted_at,omitempty"`
+	Overlay   *JsonOverlay `json:"overlay,omitempty"` // Только у наложенных событий
 }
 
-func makeSliceJsonEvent(l []structs.Event) []jsonEvent {
-	var res []jsonEvent
+func makeSliceJsonEvent(l []structs.Event) []JsonEvent {
+	var res []JsonEvent
 	for _, e := range l {
 		res = append(res, makeJsonEvent(e))
 	}
 	return res
 }
 
-func makeJsonEvent(e structs.Event) jsonEvent {
-	return jsonEvent{
+func makeJsonEvent(e structs.Event) JsonEvent {
+	res := JsonEvent{
 		Id:            e.GetId(),
-		jsonEventNoId: makeJsonEventNoId(e.EventNoId),
+		JsonEventNoId: makeJsonEventNoId(e.EventNoId),
 	}
+	if e.IsDeleted() {
+		res.DeletedAt = e.GetDeletedAt().Format(time.RFC3339)
+	}
+	return res
 }
 
-type jsonEventNoId struct {
-	UserID structs.UserID `json:"user_id"`
-	Date   string         `json:"date"`
+type JsonEventNoId struct {
+	UserID      structs.UserID `json:"user_id"`
+	Date        string         `json:"date"`
+	Title       string         `json:"title,omitempty"`
+	Description string         `json:"description,omitempty"`
 }
 
-func makeJsonEventNoId(e structs.EventNoId) jsonEventNoId {
-	return jsonEventNoId{
-		UserID: e.GetUserId(),
-		Date:   e.GetDate().Format("2006-01-02"),
+func makeJsonEventNoId(e structs.EventNoId) JsonEventNoId {
+	return JsonEventNoId{
+		UserID:      e.GetUserId(),
+		Date:        e.GetDate().Format("2006-01-02"),
+		Title:       e.GetTitle(),
+		Description: e.GetDescription(),
 	}
 }
@@ -2,11 +2,14 @@ package endpoints
 
 import (
 	"bytes"
+	"dev11/holidays"
 	"dev11/logic"
 	"dev11/models"
+	"dev11/search"
 	"dev11/structs"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -52,7 +55,7 @@ func TestCreate(t *testing.T) {
 func TestUpdate(t *testing.T) {
 	e := buildEventHTTP()
 	// Добавим событие
-	_, _ = e.api.Create(structs.MakeEventNoId(
+	_, _ = e.tenants.Get(logic.DefaultTenant).Create(structs.MakeEventNoId(
 		1,
 		time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
 	))
@@ -68,7 +71,7 @@ func TestUpdate(t *testing.T) {
 func TestDelete(t *testing.T) {
 	e := buildEventHTTP()
 	// Добавим событие
-	_, _ = e.api.Create(structs.MakeEventNoId(
+	_, _ = e.tenants.Get(logic.DefaultTenant).Create(structs.MakeEventNoId(
 		1,
 		time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
 	))
@@ -84,7 +87,7 @@ func TestDelete(t *testing.T) {
 func TestForDay(t *testing.T) {
 	e := buildEventHTTP()
 	// Добавим события
-	_, _ = e.api.Create(structs.MakeEventNoId(
+	_, _ = e.tenants.Get(logic.DefaultTenant).Create(structs.MakeEventNoId(
 		1,
 		time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
 	))
@@ -100,11 +103,11 @@ func TestForDay(t *testing.T) {
 func TestForWeek(t *testing.T) {
 	e := buildEventHTTP()
 	// Добавим события
-	_, _ = e.api.Create(structs.MakeEventNoId(
+	_, _ = e.tenants.Get(logic.DefaultTenant).Create(structs.MakeEventNoId(
 		1,
 		time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
 	))
-	_, _ = e.api.Create(structs.MakeEventNoId(
+	_, _ = e.tenants.Get(logic.DefaultTenant).Create(structs.MakeEventNoId(
 		1,
 		time.Date(2019, 1, 7, 0, 0, 0, 0, time.UTC),
 	))
@@ -120,11 +123,11 @@ func TestForWeek(t *testing.T) {
 func TestForMonth(t *testing.T) {
 	e := buildEventHTTP()
 	// Добавим события
-	_, _ = e.api.Create(structs.MakeEventNoId(
+	_, _ = e.tenants.Get(logic.DefaultTenant).Create(structs.MakeEventNoId(
 		1,
 		time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
 	))
-	_, _ = e.api.Create(structs.MakeEventNoId(
+	_, _ = e.tenants.Get(logic.DefaultTenant).Create(structs.MakeEventNoId(
 		1,
 		time.Date(2019, 1, 30, 0, 0, 0, 0, time.UTC),
 	))
@@ -136,3 +139,297 @@ func TestForMonth(t *testing.T) {
 
 	checkStatusBody(t, "GET", url, "", e.ForMonthHandle, wantStatusCode, wantBody+"\n")
 }
+
+func TestBatch(t *testing.T) {
+	e := buildEventHTTP()
+	_, _ = e.tenants.Get(logic.DefaultTenant).Create(structs.MakeEventNoId(
+		1,
+		time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+	))
+	body := `{"ops":[` +
+		`{"op":"create","user_id":3,"date":"2019-09-09"},` +
+		`{"op":"update","id":0,"user_id":2,"date":"2020-01-01"},` +
+		`{"op":"delete","id":1}]}`
+
+	wantStatusCode := http.StatusOK
+	wantBody := `{"result":[` +
+		`{"result":{"id":1,"user_id":3,"date":"2019-09-09"}},` +
+		`{"result":{"id":0,"user_id":2,"date":"2020-01-01"}},` +
+		`{"result":"deleted"}]}`
+
+	checkStatusBody(t, "POST", "", body, e.BatchHandle, wantStatusCode, wantBody+"\n")
+}
+
+func TestBatchAtomicRollback(t *testing.T) {
+	e := buildEventHTTP()
+	body := `{"ops":[{"op":"create","user_id":3,"date":"2019-09-09"},{"op":"delete","id":5}]}`
+
+	wantStatusCode := http.StatusInternalServerError
+	wantBody := `{"error":"operation 1: no such element id","code":"internal","ops":[{"error":"rolled back"},{"error":"no such element id"}]}`
+
+	checkStatusBody(t, "POST", "", body, e.BatchHandle, wantStatusCode, wantBody+"\n")
+}
+
+func TestBatchBestEffort(t *testing.T) {
+	e := buildEventHTTP()
+	body := `{"best_effort":true,"ops":[{"op":"delete","id":5},{"op":"create","user_id":3,"date":"2019-09-09"}]}`
+
+	wantStatusCode := http.StatusOK
+	wantBody := `{"result":[{"error":"no such element id"},{"result":{"id":0,"user_id":3,"date":"2019-09-09"}}]}`
+
+	checkStatusBody(t, "POST", "", body, e.BatchHandle, wantStatusCode, wantBody+"\n")
+}
+
+func TestBatchBadOp(t *testing.T) {
+	e := buildEventHTTP()
+	body := `{"ops":[{"op":"create","user_id":-3,"date":"2019-09-09"}]}`
+
+	wantStatusCode := http.StatusBadRequest
+	wantBody := `{"error":"can't parse operation 0","code":"invalid_argument"}`
+
+	checkStatusBody(t, "POST", "", body, e.BatchHandle, wantStatusCode, wantBody+"\n")
+}
+
+func TestTrashRestore(t *testing.T) {
+	e := buildEventHTTP()
+	// Добавим и удалим событие
+	ev, _ := e.tenants.Get(logic.DefaultTenant).Create(structs.MakeEventNoId(
+		1,
+		time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+	))
+	_ = e.tenants.Get(logic.DefaultTenant).Delete(ev.GetId())
+
+	// Корзина другого пользователя пуста
+	checkStatusBody(t, "GET", "?user_id=2", "", e.TrashHandle, http.StatusOK, `{"result":[]}`)
+
+	// Корзина
+	req, _ := http.NewRequest("GET", "?user_id=1", nil)
+	rr := httptest.NewRecorder()
+	e.TrashHandle(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"deleted_at":`) {
+		t.Fatalf("unexpected response: %d %s", rr.Code, rr.Body)
+	}
+
+	// Восстановление
+	wantBody := `{"result":{"id":0,"user_id":1,"date":"2019-01-01"}}`
+	checkStatusBody(t, "POST", "", "id=0", e.RestoreHandle, http.StatusOK, wantBody+"\n")
+	checkStatusBody(t, "POST", "", "id=0", e.RestoreHandle, http.StatusInternalServerError, `{"error":"no such deleted element id","code":"internal"}`+"\n")
+}
+
+func TestHistoryRevert(t *testing.T) {
+	e := NewEventHTTP(logic.NewEventAPI(models.NewEventModelMemory()).WithRevisions(models.NewRevisionModelMemory()))
+	_, _ = e.tenants.Get(logic.DefaultTenant).WithActor("alice").Create(structs.MakeEventNoId(
+		1,
+		time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+	))
+
+	// Изменяем событие через http
+	req, _ := http.NewRequest("POST", "", strings.NewReader("id=0&user_id=2&date=2019-01-01"))
+	req = req.WithContext(logic.WithUser(req.Context(), "bob"))
+	e.UpdateHandle(httptest.NewRecorder(), req)
+
+	// История
+	req, _ = http.NewRequest("GET", "/events/0/history", nil)
+	req.SetPathValue("id", "0")
+	rr := httptest.NewRecorder()
+	e.HistoryHandle(rr, req)
+	body := rr.Body.String()
+	if rr.Code != http.StatusOK ||
+		!strings.Contains(body, `"actor":"alice","at":`) ||
+		!strings.Contains(body, `"actor":"bob"`) ||
+		!strings.Contains(body, `"diff":[{"field":"user_id","old":"1","new":"2"}]`) {
+		t.Fatalf("unexpected response: %d %s", rr.Code, body)
+	}
+
+	// Откат к первой ревизии
+	req, _ = http.NewRequest("POST", "/events/0/revert", strings.NewReader("revision=0"))
+	req.SetPathValue("id", "0")
+	rr = httptest.NewRecorder()
+	e.RevertHandle(rr, req)
+	wantBody := `{"result":{"id":0,"user_id":1,"date":"2019-01-01"}}` + "\n"
+	if rr.Code != http.StatusOK || rr.Body.String() != wantBody {
+		t.Fatalf("unexpected response: %d %s", rr.Code, rr.Body)
+	}
+
+	// Неверный id
+	req, _ = http.NewRequest("GET", "/events/x/history", nil)
+	req.SetPathValue("id", "x")
+	rr = httptest.NewRecorder()
+	e.HistoryHandle(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("got %d; want %d", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCreateBodyTooLarge(t *testing.T) {
+	e := buildEventHTTP()
+	body := `user_id=3&date=2019-09-09`
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 4)
+		e.CreateHandle(w, r)
+	})
+	wantBody := `{"error":"request body too large","code":"too_large"}`
+
+	checkStatusBody(t, "POST", "", body, handler, http.StatusRequestEntityTooLarge, wantBody+"\n")
+}
+
+func TestTenantsIsolation(t *testing.T) {
+	e := NewTenantEventHTTP(logic.NewTenants(func(string) *logic.EventAPI {
+		m := models.NewEventModelMemory()
+		m.SetQuota(1)
+		return logic.NewEventAPI(m)
+	}))
+	create := func(tenant string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/create_event", strings.NewReader("user_id=3&date=2019-09-09"))
+		req = req.WithContext(logic.WithTenant(req.Context(), tenant))
+		rr := httptest.NewRecorder()
+		e.CreateHandle(rr, req)
+		return rr
+	}
+
+	// У каждого арендатора свои id
+	for _, tenant := range []string{"a", "b"} {
+		rr := create(tenant)
+		wantBody := `{"result":{"id":0,"user_id":3,"date":"2019-09-09"}}` + "\n"
+		if rr.Code != http.StatusOK || rr.Body.String() != wantBody {
+			t.Fatalf("tenant %s: unexpected response: %d %s", tenant, rr.Code, rr.Body)
+		}
+	}
+
+	// Ограничение считается для каждого арендатора отдельно
+	rr := create("a")
+	if rr.Code != http.StatusForbidden || rr.Body.String() != `{"error":"event quota exceeded","code":"quota_exceeded"}`+"\n" {
+		t.Fatalf("unexpected response: %d %s", rr.Code, rr.Body)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	e := NewEventHTTP(logic.NewEventAPI(models.NewEventModelMemory()).WithSearch(search.NewIndex()))
+	checkStatusBody(t, "POST", "", "user_id=3&date=2019-09-09&title=Встреча&description=С+заказчиком", e.CreateHandle,
+		http.StatusOK, `{"result":{"id":0,"user_id":3,"date":"2019-09-09","title":"Встреча","description":"С заказчиком"}}`+"\n")
+	checkStatusBody(t, "POST", "", "user_id=3&date=2019-09-10&title=Обед", e.CreateHandle,
+		http.StatusOK, `{"result":{"id":1,"user_id":3,"date":"2019-09-10","title":"Обед"}}`+"\n")
+
+	req, _ := http.NewRequest("GET", "/search?q=заказ&user_id=3&from=2019-09-01&to=2019-09-30", nil)
+	rr := httptest.NewRecorder()
+	e.SearchHandle(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `{"event":{"id":0,`) || strings.Contains(rr.Body.String(), `"id":1`) {
+		t.Fatalf("unexpected response: %d %s", rr.Code, rr.Body)
+	}
+
+	for _, q := range []string{"", "q=", "q=a&user_id=x", "q=a&from=2019", "q=a&limit=0", "q=a&limit=100000"} {
+		req, _ := http.NewRequest("GET", "/search?"+q, nil)
+		rr := httptest.NewRecorder()
+		e.SearchHandle(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: got %d; want %d", q, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestForDayConditional(t *testing.T) {
+	e := buildEventHTTP()
+	_, _ = e.tenants.Get(logic.DefaultTenant).Create(structs.MakeEventNoId(
+		1,
+		time.Date(2019, 10, 10, 0, 0, 0, 0, time.UTC),
+	))
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/events_for_day?to_date=2019-10-10", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rr := httptest.NewRecorder()
+		e.ForDayHandle(rr, req)
+		return rr
+	}
+
+	// Пока идет секунда изменения, Last-Modified не отдается
+	modifiedAt := e.tenants.Get(logic.DefaultTenant).ModifiedAt()
+	e.now = func() time.Time { return modifiedAt }
+	rr := get("", "")
+	if rr.Code != http.StatusOK || rr.Header().Get("Last-Modified") != "" {
+		t.Fatalf("got %d, Last-Modified %q", rr.Code, rr.Header().Get("Last-Modified"))
+	}
+	sameSecond := modifiedAt.UTC().Truncate(time.Second).Format(http.TimeFormat)
+	if rr := get("If-Modified-Since", sameSecond); rr.Code != http.StatusOK {
+		t.Fatalf("got %d; want 200", rr.Code)
+	}
+
+	e.now = func() time.Time { return modifiedAt.Add(time.Second) }
+	rr = get("", "")
+	tag, lastModified := rr.Header().Get("ETag"), rr.Header().Get("Last-Modified")
+	if rr.Code != http.StatusOK || tag == "" || lastModified != sameSecond {
+		t.Fatalf("got %d, ETag %q, Last-Modified %q", rr.Code, tag, lastModified)
+	}
+	if rr := get("If-None-Match", tag); rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Fatalf("got %d %s; want 304", rr.Code, rr.Body)
+	}
+	if rr := get("If-Modified-Since", lastModified); rr.Code != http.StatusNotModified {
+		t.Fatalf("got %d; want 304", rr.Code)
+	}
+
+	// После изменения календаря ответ приходит заново
+	_, _ = e.tenants.Get(logic.DefaultTenant).Create(structs.MakeEventNoId(
+		2,
+		time.Date(2019, 10, 10, 0, 0, 0, 0, time.UTC),
+	))
+	if rr := get("If-None-Match", tag); rr.Code != http.StatusOK {
+		t.Fatalf("got %d; want 200", rr.Code)
+	}
+	if rr := get("If-Modified-Since", "Mon, 01 Jan 2001 00:00:00 GMT"); rr.Code != http.StatusOK {
+		t.Fatalf("got %d; want 200", rr.Code)
+	}
+}
+
+func buildHolidayEventHTTP() *EventHTTP {
+	cal := holidays.NewCalendar([]structs.Holiday{
+		{Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Name: "Новый год", Region: "ru"},
+		{Date: time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC), Name: "Рабочая суббота", Region: "ru", Working: true},
+		{Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Name: "New Year", Region: "us"},
+	})
+	return NewEventHTTP(logic.NewEventAPI(models.NewEventModelMemory()).WithHolidays(cal, "ru"))
+}
+
+func TestForDayHolidays(t *testing.T) {
+	e := buildHolidayEventHTTP()
+	_, _ = e.tenants.Get(logic.DefaultTenant).Create(structs.MakeEventNoId(
+		1,
+		time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	))
+
+	// Праздник региона по умолчанию идет перед событиями
+	wantBody := `{"result":[{"id":-1,"user_id":-1,"date":"2024-01-01","title":"Новый год","overlay":{"kind":"holiday","region":"ru"}},` +
+		`{"id":0,"user_id":1,"date":"2024-01-01"}]}` + "\n"
+	checkStatusBody(t, "GET", "/events_for_day?to_date=2024-01-01", "", e.ForDayHandle, http.StatusOK, wantBody)
+
+	wantBody = `{"result":[{"id":-1,"user_id":-1,"date":"2024-01-01","title":"New Year","overlay":{"kind":"holiday","region":"us"}},` +
+		`{"id":0,"user_id":1,"date":"2024-01-01"}]}` + "\n"
+	checkStatusBody(t, "GET", "/events_for_week?to_date=2024-01-02&region=us", "", e.ForWeekHandle, http.StatusOK, wantBody)
+
+	wantBody = `{"result":[{"id":-1,"user_id":-1,"date":"2024-01-06","title":"Рабочая суббота","overlay":{"kind":"holiday","region":"ru","working":true}}]}` + "\n"
+	checkStatusBody(t, "GET", "/events_for_day?to_date=2024-01-06", "", e.ForDayHandle, http.StatusOK, wantBody)
+
+	checkStatusBody(t, "GET", "/events_for_day?to_date=2024-01-01&region=de", "", e.ForDayHandle,
+		http.StatusBadRequest, `{"error":"unknown holiday region","code":"invalid_argument"}`+"\n")
+	checkStatusBody(t, "GET", "/events_for_day?to_date=2024-01-01&region=r%20u", "", e.ForDayHandle,
+		http.StatusBadRequest, `{"error":"can't parse","code":"invalid_argument"}`+"\n")
+}
+
+func TestWorkingDays(t *testing.T) {
+	e := buildHolidayEventHTTP()
+
+	wantBody := `{"result":{"count":5,"days":["2024-01-02","2024-01-03","2024-01-04","2024-01-05","2024-01-06"],` +
+		`"holidays":[{"date":"2024-01-01","name":"Новый год","region":"ru"},{"date":"2024-01-06","name":"Рабочая суббота","region":"ru","working":true}]}}` + "\n"
+	checkStatusBody(t, "GET", "/working_days?from=2024-01-01&to=2024-01-07", "", e.WorkingDaysHandle, http.StatusOK, wantBody)
+
+	wantBody = `{"result":{"count":0,"days":[],"holidays":[{"date":"2024-01-01","name":"New Year","region":"us"}]}}` + "\n"
+	checkStatusBody(t, "GET", "/working_days?from=2024-01-01&to=2024-01-01&region=us", "", e.WorkingDaysHandle, http.StatusOK, wantBody)
+
+	checkStatusBody(t, "GET", "/working_days?from=2024-01-07&to=2024-01-01", "", e.WorkingDaysHandle,
+		http.StatusBadRequest, `{"error":"can't parse","code":"invalid_argument"}`+"\n")
+	checkStatusBody(t, "GET", "/working_days?from=2024-01-01&to=2024-01-07&region=de", "", e.WorkingDaysHandle,
+		http.StatusBadRequest, `{"error":"unknown holiday region","code":"invalid_argument"}`+"\n")
+	checkStatusBody(t, "GET", "/working_days?from=2024-01-01", "", e.WorkingDaysHandle,
+		http.StatusBadRequest, `{"error":"can't parse","code":"invalid_argument"}`+"\n")
+}
@@ -0,0 +1,151 @@
+package endpoints
+
+import (
+	"dev11/logic"
+	"dev11/response"
+	"dev11/structs"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Определяет, от чьего имени выполняется запрос, для всех транспортов.
+// Автор - аутентифицированный пользователь из контекста (middleware.User),
+// заголовкам клиента не доверяем. Если пользователя нет, то адрес клиента
+func ActorFromRequest(r *http.Request) string {
+	if user, ok := logic.UserFromContext(r.Context()); ok {
+		return user
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// Получает id события из пути /events/{id}/...
+func idFromPath(r *http.Request) (structs.EventID, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 0 {
+		return 0, false
+	}
+	return structs.EventID(id), true
+}
+
+// Получает id ревизии из values, используется всеми транспортами
+func RevisionIdFromValues(v url.Values) (structs.RevisionID, bool) {
+	if num, ok := parseIntFromValues("revision", v); ok && num >= 0 {
+		return structs.RevisionID(num), true
+	}
+	return 0, false
+}
+
+// json struct for FieldChange
+type jsonFieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// json struct for Revision
+type jsonRevision struct {
+	Id     structs.RevisionID `json:"id"`
+	Actor  string             `json:"actor"`
+	At     string             `json:"at"`
+	Action string             `json:"action"`
+	Event  JsonEvent          `json:"event"`
+	Diff   []jsonFieldChange  `json:"diff"`
+}
+
+func makeJsonRevision(r structs.Revision) jsonRevision {
+	diff := []jsonFieldChange{}
+	for _, c := range r.GetDiff() {
+		diff = append(diff, jsonFieldChange{
+			Field: c.Field,
+			Old:   c.Old,
+			New:   c.New,
+		})
+	}
+	return jsonRevision{
+		Id:     r.GetId(),
+		Actor:  r.GetActor(),
+		At:     r.GetAt().Format(time.RFC3339Nano),
+		Action: string(r.GetAction()),
+		Event:  makeJsonEvent(r.GetEvent()),
+		Diff:   diff,
+	}
+}
+
+// Структура, содержащая историю события
+type jsonResultHistory struct {
+	Result []jsonRevision `json:"result"`
+}
+
+func (e *EventHTTP) HistoryHandle(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromPath(r)
+	if !ok {
+		e.errorResponse(w, response.CodeInvalidArgument, "can't parse")
+		return
+	}
+
+	// Бизнес логика
+	api, ok := e.apiFor(w, r)
+	if !ok {
+		return
+	}
+	revs, err := api.History(id)
+	if err != nil {
+		e.businessError(w, err)
+		return
+	}
+
+	// Формируем ответ
+	res := jsonResultHistory{
+		Result: []jsonRevision{},
+	}
+	for _, rev := range revs {
+		res.Result = append(res.Result, makeJsonRevision(rev))
+	}
+	e.jsonResponse(w, res, http.StatusOK)
+}
+
+func (e *EventHTTP) RevertHandle(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromPath(r)
+	if !ok {
+		e.errorResponse(w, response.CodeInvalidArgument, "can't parse")
+		return
+	}
+	// Считываем тело запроса
+	b, ok := e.readBody(w, r)
+	if !ok {
+		return
+	}
+	v, err := url.ParseQuery(string(b))
+	if err != nil {
+		e.errorResponse(w, response.CodeInvalidArgument, "can't parse")
+		return
+	}
+	revId, ok := RevisionIdFromValues(v)
+	if !ok {
+		e.errorResponse(w, response.CodeInvalidArgument, "can't parse")
+		return
+	}
+
+	// Бизнес логика
+	api, ok := e.apiFor(w, r)
+	if !ok {
+		return
+	}
+	event, err := api.Revert(id, revId)
+	if err != nil {
+		e.businessError(w, err)
+		return
+	}
+
+	// Формируем ответ
+	res := JsonResultEvent{
+		Result: makeJsonEvent(event),
+	}
+	e.jsonResponse(w, res, http.StatusOK)
+}
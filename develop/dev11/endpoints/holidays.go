@@ -0,0 +1,125 @@
+package endpoints
+
+import (
+	"dev11/response"
+	"dev11/structs"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// Признак события, которое показывается поверх событий календаря
+// и не может быть изменено. У таких событий id и user_id равны -1
+type JsonOverlay struct {
+	Kind    string `json:"kind"` // Пока только holiday
+	Region  string `json:"region"`
+	Working bool   `json:"working,omitempty"` // Перенесенный рабочий день
+}
+
+// Праздник или перенесенный рабочий день
+type JsonHoliday struct {
+	Date    string `json:"date"`
+	Name    string `json:"name"`
+	Region  string `json:"region"`
+	Working bool   `json:"working,omitempty"`
+}
+
+func makeJsonHoliday(h structs.Holiday) JsonHoliday {
+	return JsonHoliday{
+		Date:    h.Date.Format("2006-01-02"),
+		Name:    h.Name,
+		Region:  h.Region,
+		Working: h.Working,
+	}
+}
+
+// Праздник в виде события-наложения
+func makeJsonHolidayEvent(h structs.Holiday) JsonEvent {
+	return JsonEvent{
+		Id: -1,
+		JsonEventNoId: JsonEventNoId{
+			UserID: -1,
+			Date:   h.Date.Format("2006-01-02"),
+			Title:  h.Name,
+		},
+		Overlay: &JsonOverlay{Kind: "holiday", Region: h.Region, Working: h.Working},
+	}
+}
+
+// Рабочие дни периода
+type JsonWorkingDays struct {
+	Count    int           `json:"count"`
+	Days     []string      `json:"days"`
+	Holidays []JsonHoliday `json:"holidays"`
+}
+
+// Структура, содержащая рабочие дни
+type JsonResultWorkingDays struct {
+	Result JsonWorkingDays `json:"result"`
+}
+
+var regionRe = regexp.MustCompile(`^[A-Za-z0-9-]{1,32}$`)
+
+// Парсит необязательный регион, пустая строка - регион по умолчанию
+func RegionFromValues(v url.Values) (string, bool) {
+	region, ok := v["region"]
+	if !ok {
+		return "", true
+	}
+	if len(region) != 1 || !regionRe.MatchString(region[0]) {
+		return "", false
+	}
+	return region[0], true
+}
+
+// Парсит параметры /working_days из url.Values:
+// from=2024-01-01&to=2024-01-31&region=ru
+// region необязателен
+func WorkingDaysQueryFromValues(v url.Values) (region string, from, to time.Time, ok bool) {
+	if from, ok = FreeDateFromUrlValues("from", v); !ok {
+		return
+	}
+	if to, ok = FreeDateFromUrlValues("to", v); !ok {
+		return
+	}
+	if to.Before(from) {
+		return region, from, to, false
+	}
+	region, ok = RegionFromValues(v)
+	return
+}
+
+func (e *EventHTTP) WorkingDaysHandle(w http.ResponseWriter, r *http.Request) {
+	// Получаем параметры
+	region, from, to, ok := WorkingDaysQueryFromValues(r.URL.Query())
+	if !ok {
+		e.errorResponse(w, response.CodeInvalidArgument, "can't parse")
+		return
+	}
+
+	// Бизнес логика
+	api, ok := e.apiFor(w, r)
+	if !ok {
+		return
+	}
+	wd, err := api.WorkingDays(region, from, to)
+	if err != nil {
+		e.businessError(w, err)
+		return
+	}
+
+	// Формируем ответ
+	res := JsonWorkingDays{
+		Count:    len(wd.Days),
+		Days:     make([]string, 0, len(wd.Days)),
+		Holidays: make([]JsonHoliday, 0, len(wd.Holidays)),
+	}
+	for _, d := range wd.Days {
+		res.Days = append(res.Days, d.Format("2006-01-02"))
+	}
+	for _, h := range wd.Holidays {
+		res.Holidays = append(res.Holidays, makeJsonHoliday(h))
+	}
+	e.jsonResponse(w, JsonResultWorkingDays{Result: res}, http.StatusOK)
+}
@@ -0,0 +1,355 @@
+package endpoints
+
+import (
+	"dev11/openapi"
+	"dev11/response"
+	"dev11/structs"
+)
+
+// Схемы и параметры, из которых собирается описание API.
+// При добавлении нового обработчика его нужно описать в Docs
+var (
+	minZero = 0
+	minOne  = 1
+
+	idSchema     = openapi.Schema{Type: "integer", Minimum: &minZero, Description: "id события"}
+	userIdSchema = openapi.Schema{Type: "integer", Minimum: &minZero, Description: "id пользователя"}
+	dateSchema   = openapi.Schema{Type: "string", Format: "date", Description: "дата в формате 2006-01-02"}
+
+	maxTitleLen       = structs.MaxTitleLen
+	maxDescriptionLen = structs.MaxDescriptionLen
+	titleSchema       = openapi.Schema{Type: "string", MaxLength: &maxTitleLen, Description: "заголовок"}
+	descriptionSchema = openapi.Schema{Type: "string", MaxLength: &maxDescriptionLen, Description: "описание"}
+
+	eventNoIdForm = openapi.Schema{
+		Type: "object",
+		Properties: map[string]openapi.Schema{
+			"user_id":     userIdSchema,
+			"date":        dateSchema,
+			"title":       titleSchema,
+			"description": descriptionSchema,
+		},
+		Required: []string{"user_id", "date"},
+	}
+	eventForm = openapi.Schema{
+		Type: "object",
+		Properties: map[string]openapi.Schema{
+			"id":          idSchema,
+			"user_id":     userIdSchema,
+			"date":        dateSchema,
+			"title":       titleSchema,
+			"description": descriptionSchema,
+		},
+		Required: []string{"id", "user_id", "date"},
+	}
+	idForm = openapi.Schema{
+		Type:       "object",
+		Properties: map[string]openapi.Schema{"id": idSchema},
+		Required:   []string{"id"},
+	}
+
+	toDateParam = openapi.Parameter{
+		Name:        "to_date",
+		In:          "query",
+		Description: "последний день периода в формате 2006-01-02",
+		Required:    true,
+		Schema:      dateSchema,
+	}
+	userIdParam = openapi.Parameter{
+		Name:     "user_id",
+		In:       "query",
+		Required: true,
+		Schema:   userIdSchema,
+	}
+	maxSearchLimit = MaxSearchLimit
+	searchParams   = []openapi.Parameter{
+		{Name: "q", In: "query", Required: true, Description: "слова для поиска, последнее может быть началом слова", Schema: openapi.Schema{Type: "string"}},
+		{Name: "user_id", In: "query", Description: "искать только события пользователя", Schema: userIdSchema},
+		{Name: "from", In: "query", Description: "первый день периода", Schema: dateSchema},
+		{Name: "to", In: "query", Description: "последний день периода", Schema: dateSchema},
+		{Name: "limit", In: "query", Description: "сколько событий вернуть", Schema: openapi.Schema{Type: "integer", Minimum: &minOne, Maximum: &maxSearchLimit}},
+	}
+	regionParam = openapi.Parameter{
+		Name:        "region",
+		In:          "query",
+		Description: "регион производственного календаря, по умолчанию регион сервера",
+		Schema:      openapi.Schema{Type: "string", Pattern: "^[A-Za-z0-9-]{1,32}$"},
+	}
+	idPathParam = openapi.Parameter{
+		Name:     "id",
+		In:       "path",
+		Required: true,
+		Schema:   idSchema,
+	}
+)
+
+// Стандартные ответы обработчиков
+func responses(ok openapi.Schema, codes ...string) map[string]openapi.Response {
+	res := map[string]openapi.Response{
+		"200": openapi.JSONResponse("Успешное выполнение", ok),
+		"400": openapi.JSONResponse("Ошибка входных данных", openapi.Ref("Error")),
+		"405": openapi.JSONResponse("Метод не поддерживается", openapi.Ref("Error")),
+		"500": openapi.JSONResponse("Ошибка бизнес-логики", openapi.Ref("Error")),
+	}
+	for _, code := range codes {
+		switch code {
+		case "401":
+			res[code] = openapi.JSONResponse("Неверный токен администратора", openapi.Ref("Error"))
+		case "403":
+			res[code] = openapi.JSONResponse("Превышено ограничение на количество событий", openapi.Ref("Error"))
+		case "413":
+			res[code] = openapi.JSONResponse("Слишком большое тело запроса", openapi.Ref("Error"))
+		case "429":
+			res[code] = openapi.JSONResponse("Слишком много запросов", openapi.Ref("Error"))
+		}
+	}
+	return res
+}
+
+// Описание всех обработчиков EventHTTP, ключ - pattern маршрута
+func Docs() openapi.Paths {
+	p := openapi.Paths{}
+	p.Add("/create_event", "POST", openapi.Operation{
+		Summary:     "Создать событие",
+		RequestBody: openapi.FormBody(eventNoIdForm),
+		Responses:   responses(openapi.Ref("ResultEvent"), "403", "413", "429"),
+	})
+	p.Add("/update_event", "POST", openapi.Operation{
+		Summary:     "Изменить событие",
+		RequestBody: openapi.FormBody(eventForm),
+		Responses:   responses(openapi.Ref("ResultEvent"), "413", "429"),
+	})
+	p.Add("/delete_event", "POST", openapi.Operation{
+		Summary:     "Переместить событие в корзину",
+		RequestBody: openapi.FormBody(idForm),
+		Responses:   responses(openapi.Ref("ResultString"), "413", "429"),
+	})
+	p.Add("/batch", "POST", openapi.Operation{
+		Summary:     "Выполнить пакет операций create/update/delete",
+		RequestBody: openapi.JSONBody(openapi.Ref("BatchRequest")),
+		Responses: func() map[string]openapi.Response {
+			r := responses(openapi.Ref("ResultBatch"), "403", "413", "429")
+			r["500"] = openapi.JSONResponse("Атомарный пакет откачен", openapi.Ref("ErrorBatch"))
+			return r
+		}(),
+	})
+	p.Add("/restore_event", "POST", openapi.Operation{
+		Summary:     "Восстановить событие из корзины",
+		RequestBody: openapi.FormBody(idForm),
+		Responses:   responses(openapi.Ref("ResultEvent"), "403", "413", "429"),
+	})
+	p.Add("/trash", "GET", openapi.Operation{
+		Summary:    "Содержимое корзины пользователя",
+		Parameters: []openapi.Parameter{userIdParam},
+		Responses:  responses(openapi.Ref("ResultEvents"), "429"),
+	})
+	p.Add("/events/{id}/history", "GET", openapi.Operation{
+		Summary:    "История изменений события",
+		Parameters: []openapi.Parameter{idPathParam},
+		Responses:  responses(openapi.Ref("ResultHistory"), "429"),
+	})
+	p.Add("/events/{id}/revert", "POST", openapi.Operation{
+		Summary:    "Вернуть событие к состоянию ревизии",
+		Parameters: []openapi.Parameter{idPathParam},
+		RequestBody: openapi.FormBody(openapi.Schema{
+			Type: "object",
+			Properties: map[string]openapi.Schema{
+				"revision": {Type: "integer", Minimum: &minZero, Description: "id ревизии"},
+			},
+			Required: []string{"revision"},
+		}),
+		Responses: responses(openapi.Ref("ResultEvent"), "403", "413", "429"),
+	})
+	p.Add("/search", "GET", openapi.Operation{
+		Summary:    "Полнотекстовый поиск по заголовкам и описаниям событий",
+		Parameters: searchParams,
+		Responses:  responses(openapi.Ref("ResultSearch"), "429"),
+	})
+	p.Add("/working_days", "GET", openapi.Operation{
+		Summary: "Рабочие дни периода по производственному календарю региона",
+		Parameters: []openapi.Parameter{
+			{Name: "from", In: "query", Required: true, Description: "первый день периода", Schema: dateSchema},
+			{Name: "to", In: "query", Required: true, Description: "последний день периода", Schema: dateSchema},
+			regionParam,
+		},
+		Responses: responses(openapi.Ref("ResultWorkingDays"), "429"),
+	})
+	p.Add("/events_for_day", "GET", openapi.Operation{
+		Summary:    "События за день to_date",
+		Parameters: []openapi.Parameter{toDateParam, regionParam},
+		Responses:  responses(openapi.Ref("ResultEvents"), "429"),
+	})
+	p.Add("/events_for_week", "GET", openapi.Operation{
+		Summary:    "События за 7 дней до to_date включительно",
+		Parameters: []openapi.Parameter{toDateParam, regionParam},
+		Responses:  responses(openapi.Ref("ResultEvents"), "429"),
+	})
+	p.Add("/events_for_month", "GET", openapi.Operation{
+		Summary:    "События за 30 дней до to_date включительно",
+		Parameters: []openapi.Parameter{toDateParam, regionParam},
+		Responses:  responses(openapi.Ref("ResultEvents"), "429"),
+	})
+	return p
+}
+
+// Описание административных обработчиков EventHTTP
+func AdminDocs() openapi.Paths {
+	gzipFile := map[string]openapi.MediaType{
+		"application/gzip": {Schema: openapi.Schema{Type: "string", Format: "binary", Description: "снимок событий"}},
+	}
+	p := openapi.Paths{}
+	p.Add("/admin/snapshot", "GET", openapi.Operation{
+		Summary: "Выгрузить снимок всех событий",
+		Responses: func() map[string]openapi.Response {
+			r := responses(openapi.Schema{}, "401", "429")
+			r["200"] = openapi.Response{Description: "Файл снимка", Content: gzipFile}
+			return r
+		}(),
+	})
+	p.Add("/admin/restore", "POST", openapi.Operation{
+		Summary:     "Заменить все события содержимым снимка",
+		RequestBody: &openapi.RequestBody{Required: true, Content: gzipFile},
+		Responses:   responses(openapi.Ref("ResultString"), "401", "403", "413", "429"),
+	})
+	return p
+}
+
+// Схемы json документов, которые возвращают обработчики
+func Components() openapi.Components {
+	result := func(s openapi.Schema) openapi.Schema {
+		return openapi.Schema{
+			Type:       "object",
+			Properties: map[string]openapi.Schema{"result": s},
+			Required:   []string{"result"},
+		}
+	}
+	str := openapi.Schema{Type: "string"}
+	eventRef := openapi.Ref("Event")
+	revisionRef := openapi.Ref("Revision")
+	holidayRef := openapi.Ref("Holiday")
+	workingSchema := openapi.Schema{Type: "boolean", Description: "перенесенный рабочий день"}
+	errorCode := openapi.Schema{Type: "string", Description: "машиночитаемый код ошибки", Enum: []string{
+		response.CodeInvalidArgument, response.CodeUnauthenticated, response.CodeNotFound,
+		response.CodeMethodNotAllowed, response.CodePermissionDenied, response.CodeQuotaExceeded,
+		response.CodeTooLarge, response.CodeRateLimited, response.CodeInternal,
+	}}
+	batchItem := openapi.Schema{
+		Type: "object",
+		Properties: map[string]openapi.Schema{
+			"result": {OneOf: []openapi.Schema{eventRef, str}},
+			"error":  str,
+		},
+	}
+
+	return openapi.Components{
+		Schemas: map[string]openapi.Schema{
+			"Event": {
+				Type: "object",
+				Properties: map[string]openapi.Schema{
+					"id":          {Type: "integer", Description: "id события, -1 у наложенных событий"},
+					"user_id":     {Type: "integer", Description: "id пользователя, -1 у наложенных событий"},
+					"date":        dateSchema,
+					"title":       titleSchema,
+					"description": descriptionSchema,
+					"deleted_at":  {Type: "string", Format: "date-time", Description: "момент удаления, только для событий в корзине"},
+					"overlay": {
+						Type:        "object",
+						Description: "только у наложенных событий, которые нельзя изменить",
+						Properties: map[string]openapi.Schema{
+							"kind":    {Type: "string", Enum: []string{"holiday"}},
+							"region":  str,
+							"working": workingSchema,
+						},
+						Required: []string{"kind", "region"},
+					},
+				},
+				Required: []string{"id", "user_id", "date"},
+			},
+			"Holiday": {
+				Type: "object",
+				Properties: map[string]openapi.Schema{
+					"date":    dateSchema,
+					"name":    str,
+					"region":  str,
+					"working": workingSchema,
+				},
+				Required: []string{"date", "name", "region"},
+			},
+			"Revision": {
+				Type: "object",
+				Properties: map[string]openapi.Schema{
+					"id":     {Type: "integer"},
+					"actor":  str,
+					"at":     {Type: "string", Format: "date-time"},
+					"action": {Type: "string", Enum: []string{"create", "update", "delete", "restore", "revert"}},
+					"event":  eventRef,
+					"diff": {Type: "array", Items: &openapi.Schema{
+						Type: "object",
+						Properties: map[string]openapi.Schema{
+							"field": str,
+							"old":   str,
+							"new":   str,
+						},
+					}},
+				},
+			},
+			"BatchRequest": {
+				Type: "object",
+				Properties: map[string]openapi.Schema{
+					"best_effort": {Type: "boolean", Description: "выполнять операции независимо, по умолчанию пакет атомарный"},
+					"ops": {Type: "array", Items: &openapi.Schema{
+						Type: "object",
+						Properties: map[string]openapi.Schema{
+							"op":          {Type: "string", Enum: []string{"create", "update", "delete"}},
+							"id":          idSchema,
+							"user_id":     userIdSchema,
+							"date":        dateSchema,
+							"title":       titleSchema,
+							"description": descriptionSchema,
+						},
+						Required: []string{"op"},
+					}},
+				},
+				Required: []string{"ops"},
+			},
+			"Error": {
+				Type: "object",
+				Properties: map[string]openapi.Schema{
+					"error": str,
+					"code":  errorCode,
+				},
+				Required: []string{"error", "code"},
+			},
+			"ErrorBatch": {
+				Type: "object",
+				Properties: map[string]openapi.Schema{
+					"error": str,
+					"code":  errorCode,
+					"ops":   {Type: "array", Items: &batchItem},
+				},
+			},
+			"ResultEvent":   result(openapi.Ref("Event")),
+			"ResultEvents":  result(openapi.Schema{Type: "array", Items: &eventRef}),
+			"ResultString":  result(str),
+			"ResultBatch":   result(openapi.Schema{Type: "array", Items: &batchItem}),
+			"ResultHistory": result(openapi.Schema{Type: "array", Items: &revisionRef}),
+			"ResultWorkingDays": result(openapi.Schema{
+				Type: "object",
+				Properties: map[string]openapi.Schema{
+					"count":    {Type: "integer", Description: "количество рабочих дней"},
+					"days":     {Type: "array", Items: &dateSchema},
+					"holidays": {Type: "array", Items: &holidayRef, Description: "праздники и перенесенные рабочие дни периода"},
+				},
+				Required: []string{"count", "days", "holidays"},
+			}),
+			"ResultSearch": result(openapi.Schema{Type: "array", Items: &openapi.Schema{
+				Type: "object",
+				Properties: map[string]openapi.Schema{
+					"event": eventRef,
+					"score": {Type: "number", Description: "релевантность, чем больше, тем выше в списке"},
+				},
+				Required: []string{"event", "score"},
+			}}),
+		},
+	}
+}
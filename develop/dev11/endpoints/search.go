@@ -0,0 +1,91 @@
+package endpoints
+
+import (
+	"dev11/response"
+	"dev11/structs"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Ограничения на количество найденных событий в ответе
+const (
+	DefaultSearchLimit = 50
+	MaxSearchLimit     = 500
+)
+
+// Найденное событие
+type JsonSearchHit struct {
+	Event JsonEvent `json:"event"`
+	Score float64   `json:"score"`
+}
+
+// Структура, содержащая результаты поиска
+type JsonResultSearch struct {
+	Result []JsonSearchHit `json:"result"`
+}
+
+// Парсит параметры поиска из url.Values:
+// q=встреча&user_id=3&from=2019-09-01&to=2019-09-30&limit=10
+// Обязателен только q
+func SearchQueryFromValues(v url.Values) (structs.SearchQuery, bool) {
+	res := structs.SearchQuery{UserId: -1, Limit: DefaultSearchLimit}
+	q, ok := v["q"]
+	if !ok || len(q) != 1 || strings.TrimSpace(q[0]) == "" {
+		return res, false
+	}
+	res.Text = q[0]
+
+	if _, ok := v["user_id"]; ok {
+		eni, ok := userIdFromUrlValues(structs.EventNoId{}, v)
+		if !ok {
+			return res, false
+		}
+		res.UserId = eni.GetUserId()
+	}
+	if _, ok := v["from"]; ok {
+		if res.From, ok = FreeDateFromUrlValues("from", v); !ok {
+			return res, false
+		}
+	}
+	if _, ok := v["to"]; ok {
+		if res.To, ok = FreeDateFromUrlValues("to", v); !ok {
+			return res, false
+		}
+	}
+	if _, ok := v["limit"]; ok {
+		limit, ok := parseIntFromValues("limit", v)
+		if !ok || limit < 1 || limit > MaxSearchLimit {
+			return res, false
+		}
+		res.Limit = limit
+	}
+	return res, true
+}
+
+func (e *EventHTTP) SearchHandle(w http.ResponseWriter, r *http.Request) {
+	// Получаем параметры
+	q, ok := SearchQueryFromValues(r.URL.Query())
+	if !ok {
+		e.errorResponse(w, response.CodeInvalidArgument, "can't parse")
+		return
+	}
+
+	// Бизнес логика
+	api, ok := e.apiFor(w, r)
+	if !ok {
+		return
+	}
+	hits, err := api.Search(q)
+	if err != nil {
+		e.businessError(w, err)
+		return
+	}
+
+	// Формируем ответ
+	res := JsonResultSearch{Result: make([]JsonSearchHit, 0, len(hits))}
+	for _, h := range hits {
+		res.Result = append(res.Result, JsonSearchHit{Event: makeJsonEvent(h.Event), Score: h.Score})
+	}
+	e.jsonResponse(w, res, http.StatusOK)
+}
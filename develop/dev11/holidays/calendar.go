@@ -0,0 +1,72 @@
+// Пакет holidays хранит производственные календари регионов:
+// праздники и перенесенные рабочие дни. Календари загружаются
+// из файлов .ics или .json и дальше не меняются.
+package holidays
+
+import (
+	"dev11/structs"
+	"sort"
+	"time"
+)
+
+// Производственные календари нескольких регионов
+type Calendar struct {
+	regions map[string][]structs.Holiday // Дни региона по возрастанию даты
+}
+
+// Собирает календарь из списка дней. Если на одну дату региона
+// приходится несколько записей, то остается последняя
+func NewCalendar(list []structs.Holiday) *Calendar {
+	byDate := make(map[string]map[time.Time]structs.Holiday)
+	for _, h := range list {
+		if byDate[h.Region] == nil {
+			byDate[h.Region] = make(map[time.Time]structs.Holiday)
+		}
+		h.Date = day(h.Date)
+		byDate[h.Region][h.Date] = h
+	}
+
+	c := &Calendar{regions: make(map[string][]structs.Holiday, len(byDate))}
+	for region, days := range byDate {
+		res := make([]structs.Holiday, 0, len(days))
+		for _, h := range days {
+			res = append(res, h)
+		}
+		sort.Slice(res, func(i, j int) bool { return res[i].Date.Before(res[j].Date) })
+		c.regions[region] = res
+	}
+	return c
+}
+
+// Отбрасывает время, оставляя дату в UTC
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Есть ли календарь для региона
+func (c *Calendar) HasRegion(region string) bool {
+	_, ok := c.regions[region]
+	return ok
+}
+
+// Список регионов по алфавиту
+func (c *Calendar) Regions() []string {
+	res := make([]string, 0, len(c.regions))
+	for r := range c.regions {
+		res = append(res, r)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Особые дни региона из диапазона [from, to]
+func (c *Calendar) Between(region string, from, to time.Time) []structs.Holiday {
+	days := c.regions[region]
+	from, to = day(from), day(to)
+	i := sort.Search(len(days), func(i int) bool { return !days[i].Date.Before(from) })
+	j := sort.Search(len(days), func(i int) bool { return days[i].Date.After(to) })
+	if i >= j {
+		return nil
+	}
+	return append([]structs.Holiday(nil), days[i:j]...)
+}
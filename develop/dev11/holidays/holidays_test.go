@@ -0,0 +1,138 @@
+package holidays
+
+import (
+	"dev11/structs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+const testICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:1\r\n" +
+	"SUMMARY:Новый год\r\n" +
+	"DTSTART;VALUE=DATE:20240101\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:2\r\n" +
+	"SUMMARY:Рабочая суббота\r\n" +
+	"DTSTART;VALUE=DATE:20240427\r\n" +
+	"X-DEV11-WORKING:TRUE\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:3\r\n" +
+	"SUMMARY:День Республики\r\n" +
+	"DTSTART;VALUE=DATE:20240427\r\n" +
+	"X-DEV11-REGION:ru-tat\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICS(t *testing.T) {
+	got, err := ParseICS(strings.NewReader(testICS), "ru")
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	want := []structs.Holiday{
+		{Date: date(2024, 1, 1), Name: "Новый год", Region: "ru"},
+		{Date: date(2024, 4, 27), Name: "Рабочая суббота", Region: "ru", Working: true},
+		{Date: date(2024, 4, 27), Name: "День Республики", Region: "ru-tat"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v; want %+v", got, want)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	got, err := ParseJSON(strings.NewReader(`[
+		{"date": "2024-01-01", "name": "Новый год"},
+		{"date": "2024-04-27", "name": "Рабочая суббота", "working": true},
+		{"date": "2024-07-04", "name": "Independence Day", "region": "us"}
+	]`), "ru")
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	want := []structs.Holiday{
+		{Date: date(2024, 1, 1), Name: "Новый год", Region: "ru"},
+		{Date: date(2024, 4, 27), Name: "Рабочая суббота", Region: "ru", Working: true},
+		{Date: date(2024, 7, 4), Name: "Independence Day", Region: "us"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v; want %+v", got, want)
+	}
+
+	for _, bad := range []string{`{}`, `[{"date": "01.01.2024"}]`, `[`} {
+		if _, err := ParseJSON(strings.NewReader(bad), "ru"); err == nil {
+			t.Errorf("%s: err should not be nil", bad)
+		}
+	}
+}
+
+func TestCalendarBetween(t *testing.T) {
+	c := NewCalendar([]structs.Holiday{
+		{Date: date(2024, 1, 7), Name: "Рождество", Region: "ru"},
+		{Date: date(2024, 1, 1), Name: "Новый год", Region: "ru"},
+		{Date: time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC), Name: "Каникулы", Region: "ru"},
+		{Date: date(2024, 1, 2), Name: "Новогодние каникулы", Region: "ru"},
+		{Date: date(2024, 1, 1), Name: "New Year", Region: "us"},
+	})
+	if !reflect.DeepEqual(c.Regions(), []string{"ru", "us"}) || c.HasRegion("de") {
+		t.Fatalf("unexpected regions %v", c.Regions())
+	}
+
+	cases := []struct {
+		region   string
+		from, to time.Time
+		want     []string
+	}{
+		{"ru", date(2024, 1, 1), date(2024, 1, 7), []string{"Новый год", "Новогодние каникулы", "Рождество"}},
+		{"ru", date(2024, 1, 2), date(2024, 1, 6), []string{"Новогодние каникулы"}},
+		{"ru", date(2024, 1, 8), date(2024, 12, 31), nil},
+		{"us", date(2023, 12, 31), date(2024, 1, 1), []string{"New Year"}},
+		{"de", date(2024, 1, 1), date(2024, 1, 1), nil},
+	}
+	for _, tc := range cases {
+		var got []string
+		for _, h := range c.Between(tc.region, tc.from, tc.to) {
+			got = append(got, h.Name)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s %v-%v: got %v; want %v", tc.region, tc.from, tc.to, got, tc.want)
+		}
+	}
+}
+
+func TestLoadFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	ru := write("ru.ics", testICS)
+	us := write("us.json", `[{"date": "2024-07-04", "name": "Independence Day"}]`)
+
+	c, err := LoadFiles(ru, us)
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if !reflect.DeepEqual(c.Regions(), []string{"ru", "ru-tat", "us"}) {
+		t.Fatalf("unexpected regions %v", c.Regions())
+	}
+
+	if _, err := LoadFiles(write("ru.txt", "")); err == nil {
+		t.Fatal("err should not be nil")
+	}
+	if _, err := LoadFiles(filepath.Join(dir, "nope.ics")); err == nil {
+		t.Fatal("err should not be nil")
+	}
+}
@@ -0,0 +1,104 @@
+package holidays
+
+import (
+	"dev11/ics"
+	"dev11/structs"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Свойства VEVENT, которыми можно уточнить день в .ics
+const (
+	icsRegion  = "X-DEV11-REGION"  // Регион, если он отличается от региона файла
+	icsWorking = "X-DEV11-WORKING" // TRUE - перенесенный рабочий день
+)
+
+// Запись в .json файле календаря
+type jsonHoliday struct {
+	Date    string `json:"date"`
+	Name    string `json:"name"`
+	Region  string `json:"region,omitempty"`
+	Working bool   `json:"working,omitempty"`
+}
+
+// Читает дни из .ics. Регион берется из X-DEV11-REGION, иначе region
+func ParseICS(r io.Reader, region string) ([]structs.Holiday, error) {
+	events, err := ics.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]structs.Holiday, 0, len(events))
+	for _, ev := range events {
+		h := structs.Holiday{
+			Date:    ev.Date,
+			Name:    ev.Summary,
+			Region:  region,
+			Working: strings.EqualFold(ev.Extra[icsWorking], "TRUE"),
+		}
+		if r := ev.Extra[icsRegion]; r != "" {
+			h.Region = r
+		}
+		res = append(res, h)
+	}
+	return res, nil
+}
+
+// Читает дни из json массива вида
+// [{"date": "2024-01-01", "name": "Новый год"}, {"date": "2024-04-27", "working": true}].
+// Регион записи берется из поля region, иначе region
+func ParseJSON(r io.Reader, region string) ([]structs.Holiday, error) {
+	var list []jsonHoliday
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		return nil, err
+	}
+	res := make([]structs.Holiday, 0, len(list))
+	for i, jh := range list {
+		date, err := time.Parse("2006-01-02", jh.Date)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		h := structs.Holiday{Date: date, Name: jh.Name, Region: region, Working: jh.Working}
+		if jh.Region != "" {
+			h.Region = jh.Region
+		}
+		res = append(res, h)
+	}
+	return res, nil
+}
+
+// Загружает календарь из файлов .ics и .json. Регион по умолчанию
+// для дней файла - его имя без расширения: ru.ics -> ru
+func LoadFiles(paths ...string) (*Calendar, error) {
+	var all []structs.Holiday
+	for _, path := range paths {
+		list, err := loadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		all = append(all, list...)
+	}
+	return NewCalendar(all), nil
+}
+
+func loadFile(path string) ([]structs.Holiday, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ext := strings.ToLower(filepath.Ext(path))
+	region := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	switch ext {
+	case ".ics":
+		return ParseICS(f, region)
+	case ".json":
+		return ParseJSON(f, region)
+	}
+	return nil, fmt.Errorf("unknown holiday file format %q", ext)
+}
@@ -0,0 +1,224 @@
+// Пакет ics читает и пишет события в формате iCalendar (RFC 5545).
+// Поддерживаются только однодневные события VEVENT, так как события
+// календаря не имеют времени.
+package ics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Событие VEVENT
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Date        time.Time         // Дата из DTSTART, время отбрасывается
+	Extra       map[string]string // Нестандартные свойства X-..., ключ в верхнем регистре
+}
+
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405"
+)
+
+// Записывает события в w в виде VCALENDAR
+func Write(w io.Writer, prodId string, events []Event) error {
+	bw := bufio.NewWriter(w)
+	writeLine(bw, "BEGIN:VCALENDAR")
+	writeLine(bw, "VERSION:2.0")
+	writeLine(bw, "PRODID:"+escape(prodId))
+	stamp := time.Now().UTC().Format(dateTimeLayout) + "Z"
+	for _, e := range events {
+		writeLine(bw, "BEGIN:VEVENT")
+		writeLine(bw, "UID:"+escape(e.UID))
+		writeLine(bw, "DTSTAMP:"+stamp)
+		writeLine(bw, "DTSTART;VALUE=DATE:"+e.Date.Format(dateLayout))
+		if e.Summary != "" {
+			writeLine(bw, "SUMMARY:"+escape(e.Summary))
+		}
+		if e.Description != "" {
+			writeLine(bw, "DESCRIPTION:"+escape(e.Description))
+		}
+		// Сортируем, чтобы вывод был детерминированным
+		keys := make([]string, 0, len(e.Extra))
+		for k := range e.Extra {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			writeLine(bw, k+":"+escape(e.Extra[k]))
+		}
+		writeLine(bw, "END:VEVENT")
+	}
+	writeLine(bw, "END:VCALENDAR")
+	return bw.Flush()
+}
+
+// Пишет строку, перенося ее по 75 байт, как требует RFC 5545
+func writeLine(w *bufio.Writer, line string) {
+	// Строки продолжения начинаются с пробела, он тоже учитывается
+	limit := 75
+	for len(line) > limit {
+		// Не разрываем многобайтовые символы
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Разбивает поток на логические строки, склеивая перенесенные
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scn := bufio.NewScanner(r)
+	for scn.Scan() {
+		line := strings.TrimRight(scn.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scn.Err()
+}
+
+// Разбирает строку NAME;PARAM=V:VALUE
+func splitProperty(line string) (name string, params map[string]string, value string, ok bool) {
+	colon := -1
+	inQuotes := false
+	for i := 0; i < len(line); i++ {
+		if line[i] == '"' {
+			inQuotes = !inQuotes
+		} else if line[i] == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return "", nil, "", false
+	}
+	parts := strings.Split(line[:colon], ";")
+	params = make(map[string]string)
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, line[colon+1:], true
+}
+
+// Разбирает дату DTSTART. Поддерживаются DATE и DATE-TIME (UTC или локальное время)
+func parseDate(value string, params map[string]string) (time.Time, error) {
+	loc := time.UTC
+	if tzid, ok := params["TZID"]; ok {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	if params["VALUE"] == "DATE" || len(value) == len(dateLayout) {
+		t, err := time.ParseInLocation(dateLayout, value, time.UTC)
+		return t, err
+	}
+	t, err := time.ParseInLocation(dateTimeLayout, strings.TrimSuffix(value, "Z"), loc)
+	if err != nil {
+		return t, err
+	}
+	// Берем дату в той зоне, в которой задано событие
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// Читает все события VEVENT из r
+func Parse(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Event
+	var cur *Event
+	hasDate := false
+	for n, line := range lines {
+		name, params, value, ok := splitProperty(line)
+		if !ok {
+			return nil, fmt.Errorf("ics: line %d: no ':'", n+1)
+		}
+
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			if cur != nil {
+				return nil, fmt.Errorf("ics: line %d: nested VEVENT", n+1)
+			}
+			cur = &Event{}
+			hasDate = false
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if cur == nil {
+				return nil, fmt.Errorf("ics: line %d: END without BEGIN", n+1)
+			}
+			if !hasDate {
+				return nil, fmt.Errorf("ics: line %d: VEVENT without DTSTART", n+1)
+			}
+			res = append(res, *cur)
+			cur = nil
+		case cur == nil:
+			// Свойства календаря и других компонентов пропускаем
+		case name == "UID":
+			cur.UID = unescape(value)
+		case name == "SUMMARY":
+			cur.Summary = unescape(value)
+		case name == "DESCRIPTION":
+			cur.Description = unescape(value)
+		case name == "DTSTART":
+			if cur.Date, err = parseDate(value, params); err != nil {
+				return nil, fmt.Errorf("ics: line %d: %w", n+1, err)
+			}
+			hasDate = true
+		case strings.HasPrefix(name, "X-"):
+			if cur.Extra == nil {
+				cur.Extra = make(map[string]string)
+			}
+			cur.Extra[name] = unescape(value)
+		}
+	}
+	if cur != nil {
+		return nil, errors.New("ics: unterminated VEVENT")
+	}
+	return res, nil
+}
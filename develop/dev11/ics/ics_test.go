@@ -0,0 +1,83 @@
+package ics
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteParse(t *testing.T) {
+	events := []Event{
+		{
+			UID:     "1@dev11",
+			Summary: "Встреча; важная, очень",
+			Date:    time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC),
+			Extra:   map[string]string{"X-DEV11-USER-ID": "3"},
+		},
+		{
+			UID:         "2@dev11",
+			Description: strings.Repeat("длинное описание ", 10) + "\nвторая строка",
+			Date:        time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "-//dev11//calendar//RU", events); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if len(line) > 75 {
+			t.Fatalf("line is not folded: %q", line)
+		}
+	}
+
+	got, err := Parse(&buf)
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if !reflect.DeepEqual(got, events) {
+		t.Fatalf("\ngot:  %v\nwant: %v", got, events)
+	}
+}
+
+func TestParseDateTime(t *testing.T) {
+	data := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:a\r\n" +
+		"DTSTART;TZID=\"Europe/Moscow\":20190909T233000\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:b\r\n" +
+		"DTSTART:20190910T010000Z\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	got, err := Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	want := []time.Time{
+		time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC),
+		time.Date(2019, 9, 10, 0, 0, 0, 0, time.UTC),
+	}
+	if len(got) != 2 || got[0].Date != want[0] || got[1].Date != want[1] {
+		t.Fatalf("got %v; want dates %v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []string{
+		"BEGIN:VEVENT\nUID:a\nEND:VEVENT\n",
+		"BEGIN:VEVENT\nDTSTART:2019\nEND:VEVENT\n",
+		"BEGIN:VEVENT\nDTSTART:20190909\n",
+		"END:VEVENT\n",
+		"garbage\n",
+	}
+	for _, tc := range testCases {
+		if _, err := Parse(strings.NewReader(tc)); err == nil {
+			t.Errorf("%q: err should be not nil", tc)
+		}
+	}
+}
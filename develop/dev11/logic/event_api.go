@@ -2,6 +2,7 @@ package logic
 
 import (
 	"dev11/structs"
+	"sync"
 	"time"
 )
 
@@ -12,42 +13,186 @@ type IEventsModel interface {
 	SelectBetweenDates(start, end time.Time) ([]structs.Event, error)
 	Update(e structs.Event) (structs.Event, error)
 	Delete(id structs.EventID) error
+	Batch(ops []structs.BatchOp, atomic bool) ([]structs.BatchResult, error)
+	SelectDeleted(userId structs.UserID) ([]structs.Event, error)
+	Restore(id structs.EventID) (structs.Event, error)
+	Revert(e structs.Event) (structs.Event, error)
+	Purge(before time.Time) (int, error)
+	Dump() (structs.Snapshot, error)
+	Load(s structs.Snapshot) error
+	ModifiedAt() time.Time
 }
 
 type EventAPI struct {
 	m IEventsModel
+	// Общий для всех копий api. Изменения событий выполняются под ним,
+	// чтобы состояние до изменения, записанная ревизия и индекс совпадали
+	lock  *sync.Mutex
+	revs  IRevisionsModel  // История изменений, nil - история не ведется
+	idx   ISearchIndex     // Полнотекстовый индекс, nil - поиск отключен
+	actor string           // Кто выполняет операции, попадает в историю
+	hol   IHolidayCalendar // Производственные календари, nil - праздники не показываются
+	// Регион, календарь которого используется, если регион не указан
+	region string
 }
 
 func NewEventAPI(m IEventsModel) *EventAPI {
 	return &EventAPI{
-		m: m,
+		m:    m,
+		lock: &sync.Mutex{},
 	}
 }
 
 func (api *EventAPI) Create(newe structs.EventNoId) (structs.Event, error) {
-	return api.m.Create(newe)
+	api.lock.Lock()
+	defer api.lock.Unlock()
+	ev, err := api.m.Create(newe)
+	if err != nil {
+		return ev, err
+	}
+	api.record(structs.ActionCreate, nil, ev)
+	api.index(ev)
+	return ev, nil
 }
 func (api *EventAPI) Update(e structs.Event) (structs.Event, error) {
-	return api.m.Update(e)
+	api.lock.Lock()
+	defer api.lock.Unlock()
+	before := api.before(e.GetId())
+	ev, err := api.m.Update(e)
+	if err != nil {
+		return ev, err
+	}
+	api.record(structs.ActionUpdate, before, ev)
+	api.index(ev)
+	return ev, nil
 }
 func (api *EventAPI) Delete(id structs.EventID) error {
-	return api.m.Delete(id)
+	api.lock.Lock()
+	defer api.lock.Unlock()
+	before := api.before(id)
+	if err := api.m.Delete(id); err != nil {
+		return err
+	}
+	api.recordDelete(before)
+	api.unindex(id)
+	return nil
+}
+
+// Выполняет пакет операций.
+// atomic == true - все или ничего, иначе каждая операция независимо
+func (api *EventAPI) Batch(ops []structs.BatchOp, atomic bool) ([]structs.BatchResult, error) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	// Запоминаем состояние изменяемых событий до пакета
+	befores := make([]*structs.Event, len(ops))
+	if api.revs != nil {
+		for i, op := range ops {
+			if op.Kind != structs.BatchCreate {
+				befores[i] = api.before(op.Event.GetId())
+			}
+		}
+	}
+
+	res, err := api.m.Batch(ops, atomic)
+	if err != nil {
+		return res, err
+	}
+	for i, r := range res {
+		if r.Err != nil {
+			continue
+		}
+		switch ops[i].Kind {
+		case structs.BatchCreate:
+			api.record(structs.ActionCreate, nil, r.Event)
+			api.index(r.Event)
+		case structs.BatchUpdate:
+			api.record(structs.ActionUpdate, befores[i], r.Event)
+			api.index(r.Event)
+		case structs.BatchDelete:
+			api.recordDelete(befores[i])
+			api.unindex(ops[i].Event.GetId())
+		}
+	}
+	return res, nil
+}
+
+// Возвращает содержимое корзины пользователя
+func (api *EventAPI) Trash(userId structs.UserID) ([]structs.Event, error) {
+	return api.m.SelectDeleted(userId)
+}
+
+// Достает событие из корзины
+func (api *EventAPI) Restore(id structs.EventID) (structs.Event, error) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+	before := api.before(id)
+	ev, err := api.m.Restore(id)
+	if err != nil {
+		return ev, err
+	}
+	api.record(structs.ActionRestore, before, ev)
+	api.index(ev)
+	return ev, nil
+}
+
+// Окончательно удаляет события, которые лежат в корзине дольше retention
+func (api *EventAPI) Purge(retention time.Duration) (int, error) {
+	return api.m.Purge(time.Now().UTC().Add(-retention))
+}
+
+// Возвращает согласованный снимок всех событий
+func (api *EventAPI) Snapshot() (structs.Snapshot, error) {
+	return api.m.Dump()
+}
+
+// Заменяет все события содержимым снимка. История изменений
+// очищается, так как ревизии описывают уже другие события
+func (api *EventAPI) LoadSnapshot(s structs.Snapshot) error {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+	if err := api.m.Load(s); err != nil {
+		return err
+	}
+	api.reindex()
+	if api.revs != nil {
+		return api.revs.Clear()
+	}
+	return nil
+}
+
+// Момент последнего изменения событий, нулевое значение - изменений не было
+func (api *EventAPI) ModifiedAt() time.Time {
+	return api.m.ModifiedAt()
+}
+
+// Периоды, за которые ForDay, ForWeek и ForMonth возвращают события
+func DayPeriod(date time.Time) (time.Time, time.Time) {
+	return date, date
+}
+
+func WeekPeriod(date time.Time) (time.Time, time.Time) {
+	const week = 7 * 24 * time.Hour
+	return date.Add(-week), date
+}
+
+// Возможно это немного не тот функционал, который требуется, но так проще
+func MonthPeriod(date time.Time) (time.Time, time.Time) {
+	const month = 30 * 24 * time.Hour
+	return date.Add(-month), date
 }
 
 // Возвращает список событий, которые имеют дату date
 func (api *EventAPI) ForDay(date time.Time) ([]structs.Event, error) {
-	return api.m.SelectBetweenDates(date, date)
+	return api.m.SelectBetweenDates(DayPeriod(date))
 }
 
 // Возвращает список событий из дипазаона [date-7days, date]
 func (api *EventAPI) ForWeek(date time.Time) ([]structs.Event, error) {
-	const week = 7 * 24 * time.Hour
-	return api.m.SelectBetweenDates(date.Add(-week), date)
+	return api.m.SelectBetweenDates(WeekPeriod(date))
 }
 
 // Возвращает список событий из дипазаона [date-30days, date]
-// Возможно это немного не тот функционал, который требуется, но так проще
 func (api *EventAPI) ForMonth(date time.Time) ([]structs.Event, error) {
-	const month = 30 * 24 * time.Hour
-	return api.m.SelectBetweenDates(date.Add(-month), date)
+	return api.m.SelectBetweenDates(MonthPeriod(date))
 }
@@ -2,7 +2,10 @@ package logic
 
 import (
 	"dev11/models"
+	"dev11/search"
 	"dev11/structs"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 )
@@ -188,3 +191,111 @@ func TestEventAPIForMonth(t *testing.T) {
 	}
 	checkEventsSlice(t, events, []structs.Event{ea, eb, ec})
 }
+
+func TestEventAPITrash(t *testing.T) {
+	api := eventAPIMemoryModel()
+
+	ea, _ := api.Create(structs.MakeEventNoId(1, time.Date(2019, 10, 10, 0, 0, 0, 0, time.UTC)))
+	if err := api.Delete(ea.GetId()); err != nil {
+		t.Fatal("err should be nil")
+	}
+	trash, err := api.Trash(1)
+	if err != nil || len(trash) != 1 {
+		t.Fatalf("got %v, %v; want 1 event", trash, err)
+	}
+
+	// Свежие события не удаляются
+	if n, _ := api.Purge(time.Hour); n != 0 {
+		t.Fatalf("got %d purged; want 0", n)
+	}
+	if _, err := api.Restore(ea.GetId()); err != nil {
+		t.Fatal("err should be nil")
+	}
+
+	// Нулевой срок хранения удаляет все
+	_ = api.Delete(ea.GetId())
+	time.Sleep(time.Millisecond)
+	if n, _ := api.Purge(0); n != 1 {
+		t.Fatalf("got %d purged; want 1", n)
+	}
+}
+
+// Тест рассчитан на запуск с -race: изменения идут через api с историей
+// и поиском, параллельно с ними идут выборки и поиск
+func TestEventAPIConcurrent(t *testing.T) {
+	const workers, rounds = 6, 100
+	api := eventAPIWithHistory().WithSearch(search.NewIndex())
+	day := time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers+1)
+	stop := make(chan struct{})
+	// Читатель, работающий все время изменений
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if _, err := api.ForMonth(day); err != nil {
+				errs <- err
+				return
+			}
+			if _, err := api.Search(structs.SearchQuery{Text: "встр", UserId: -1}); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			wapi := api.WithActor(fmt.Sprintf("worker%d", w))
+			for i := 0; i < rounds; i++ {
+				newe := structs.MakeEventNoId(structs.UserID(w), day)
+				newe.SetTitle("встреча")
+				e, err := wapi.Create(newe)
+				if err != nil {
+					errs <- err
+					return
+				}
+				e.SetDescription(fmt.Sprintf("раунд %d", i))
+				if _, err := wapi.Update(e); err != nil {
+					errs <- err
+					return
+				}
+				if i%2 == 0 {
+					if err := wapi.Delete(e.GetId()); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(stop)
+	<-readerDone
+	close(errs)
+	for err := range errs {
+		t.Fatal("err should be nil", err)
+	}
+
+	// Индекс и история согласованы с моделью
+	if got := searchIds(t, api, "встреча"); len(got) != workers*rounds/2 {
+		t.Fatalf("got %d search hits; want %d", len(got), workers*rounds/2)
+	}
+	for id := structs.EventID(0); id < workers*rounds; id++ {
+		revs, err := api.History(id)
+		if err != nil {
+			t.Fatal("err should be nil", err)
+		}
+		if len(revs) < 2 || revs[0].GetAction() != structs.ActionCreate || revs[1].GetAction() != structs.ActionUpdate {
+			t.Fatalf("event %d: unexpected history %v", id, revs)
+		}
+	}
+}
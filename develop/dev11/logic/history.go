@@ -0,0 +1,123 @@
+package logic
+
+import (
+	"context"
+	"dev11/structs"
+	"errors"
+	"log"
+	"time"
+)
+
+// Интерфейс для работы с историей изменений событий
+type IRevisionsModel interface {
+	Append(r structs.Revision) (structs.Revision, error)
+	SelectByEventId(id structs.EventID) ([]structs.Revision, error)
+	Clear() error
+}
+
+// Возвращает копию api, которая записывает историю изменений в revs
+func (api *EventAPI) WithRevisions(revs IRevisionsModel) *EventAPI {
+	res := *api
+	res.revs = revs
+	return &res
+}
+
+// Возвращает копию api, операции которой попадут в историю от имени actor
+func (api *EventAPI) WithActor(actor string) *EventAPI {
+	res := *api
+	res.actor = actor
+	return &res
+}
+
+type userKey struct{}
+
+// Возвращает копию ctx, запрос в которой выполняет аутентифицированный
+// пользователь user. Он записывается в историю как автор изменений
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// Аутентифицированный пользователь из ctx
+func UserFromContext(ctx context.Context) (string, bool) {
+	user, ok := ctx.Value(userKey{}).(string)
+	return user, ok && user != ""
+}
+
+// Возвращает состояние события перед изменением: текущее,
+// а если его нет (например, оно в корзине), то из последней ревизии.
+// Вызывающий должен держать api.lock
+func (api *EventAPI) before(id structs.EventID) *structs.Event {
+	if api.revs == nil {
+		return nil
+	}
+	if ev, err := api.m.SelectById(id); err == nil {
+		return &ev
+	}
+	if revs, err := api.revs.SelectByEventId(id); err == nil && len(revs) > 0 {
+		ev := revs[len(revs)-1].GetEvent()
+		return &ev
+	}
+	return nil
+}
+
+// Записывает ревизию, если ведется история
+func (api *EventAPI) record(action structs.RevisionAction, before *structs.Event, after structs.Event) {
+	if api.revs == nil {
+		return
+	}
+	rev := structs.MakeRevision(action, api.actor, time.Now().UTC(), before, after)
+	if _, err := api.revs.Append(rev); err != nil {
+		// Изменение уже применено, поэтому только сообщаем об ошибке
+		log.Printf("history: %s", err)
+	}
+}
+
+// Записывает ревизию удаления события before
+func (api *EventAPI) recordDelete(before *structs.Event) {
+	if before == nil {
+		return
+	}
+	after := *before
+	after.SetDeletedAt(time.Now().UTC())
+	api.record(structs.ActionDelete, before, after)
+}
+
+// Возвращает историю изменений события от старых ревизий к новым
+func (api *EventAPI) History(id structs.EventID) ([]structs.Revision, error) {
+	if api.revs == nil {
+		return nil, errors.New("history is disabled")
+	}
+	return api.revs.SelectByEventId(id)
+}
+
+// Возвращает событие к состоянию ревизии revId.
+// Если событие лежит в корзине, то оно восстанавливается
+func (api *EventAPI) Revert(id structs.EventID, revId structs.RevisionID) (structs.Event, error) {
+	revs, err := api.History(id)
+	if err != nil {
+		return structs.Event{}, err
+	}
+	var target *structs.Revision
+	for i := range revs {
+		if revs[i].GetId() == revId {
+			target = &revs[i]
+			break
+		}
+	}
+	if target == nil {
+		return structs.Event{}, errors.New("no such revision id")
+	}
+
+	api.lock.Lock()
+	defer api.lock.Unlock()
+	before := api.before(id)
+	// Восстановление из корзины и замена - одна операция модели,
+	// поэтому при ошибке событие остается в корзине
+	ev, err := api.m.Revert(target.GetEvent())
+	if err != nil {
+		return ev, err
+	}
+	api.record(structs.ActionRevert, before, ev)
+	api.index(ev)
+	return ev, nil
+}
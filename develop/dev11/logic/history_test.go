@@ -0,0 +1,190 @@
+package logic
+
+import (
+	"dev11/models"
+	"dev11/structs"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func eventAPIWithHistory() *EventAPI {
+	return NewEventAPI(models.NewEventModelMemory()).WithRevisions(models.NewRevisionModelMemory())
+}
+
+func checkHistoryActions(t *testing.T, revs []structs.Revision, want ...structs.RevisionAction) {
+	t.Helper()
+	if len(revs) != len(want) {
+		t.Fatalf("got %d revisions; want %d", len(revs), len(want))
+	}
+	for i := range revs {
+		if revs[i].GetAction() != want[i] {
+			t.Fatalf("revision %d: got %v; want %v", i, revs[i].GetAction(), want[i])
+		}
+	}
+}
+
+func TestEventAPIHistory(t *testing.T) {
+	api := eventAPIWithHistory()
+
+	ea, _ := api.WithActor("alice").Create(structs.MakeEventNoId(1, time.Date(2019, 10, 10, 0, 0, 0, 0, time.UTC)))
+	upd := ea
+	upd.SetUserId(2)
+	_, _ = api.WithActor("bob").Update(upd)
+	_ = api.WithActor("carol").Delete(ea.GetId())
+	_, _ = api.WithActor("dave").Restore(ea.GetId())
+
+	revs, err := api.History(ea.GetId())
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	checkHistoryActions(t, revs,
+		structs.ActionCreate, structs.ActionUpdate, structs.ActionDelete, structs.ActionRestore)
+
+	if revs[0].GetActor() != "alice" || revs[1].GetActor() != "bob" {
+		t.Fatalf("wrong actors: %v %v", revs[0].GetActor(), revs[1].GetActor())
+	}
+	diff := revs[1].GetDiff()
+	if len(diff) != 1 || diff[0] != (structs.FieldChange{Field: "user_id", Old: "1", New: "2"}) {
+		t.Fatalf("unexpected diff: %v", diff)
+	}
+	if ev := revs[2].GetEvent(); !ev.IsDeleted() {
+		t.Fatal("delete revision should hold deleted event")
+	}
+}
+
+func TestEventAPIBatchHistory(t *testing.T) {
+	api := eventAPIWithHistory()
+	ea, _ := api.Create(structs.MakeEventNoId(1, time.Date(2019, 10, 10, 0, 0, 0, 0, time.UTC)))
+
+	upd := ea
+	upd.SetUserId(2)
+	_, err := api.Batch([]structs.BatchOp{
+		structs.MakeBatchUpdate(upd),
+		structs.MakeBatchDelete(ea.GetId()),
+	}, true)
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	revs, _ := api.History(ea.GetId())
+	checkHistoryActions(t, revs, structs.ActionCreate, structs.ActionUpdate, structs.ActionDelete)
+
+	// Откаченный пакет не попадает в историю
+	_, err = api.Batch([]structs.BatchOp{
+		structs.MakeBatchCreate(structs.MakeEventNoId(1, ea.GetDate())),
+		structs.MakeBatchDelete(100),
+	}, true)
+	if err == nil {
+		t.Fatal("err should be not nil")
+	}
+	if revs, _ := api.History(ea.GetId() + 1); len(revs) != 0 {
+		t.Fatalf("unexpected history: %v", revs)
+	}
+}
+
+func TestEventAPIRevert(t *testing.T) {
+	api := eventAPIWithHistory()
+	ea, _ := api.Create(structs.MakeEventNoId(1, time.Date(2019, 10, 10, 0, 0, 0, 0, time.UTC)))
+	upd := ea
+	upd.SetUserId(2)
+	_, _ = api.Update(upd)
+	_ = api.Delete(ea.GetId())
+
+	revs, _ := api.History(ea.GetId())
+	reverted, err := api.Revert(ea.GetId(), revs[0].GetId())
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if reverted != ea {
+		t.Fatalf("got %v; want %v", reverted, ea)
+	}
+	if got, err := api.m.SelectById(ea.GetId()); err != nil || got != ea {
+		t.Fatalf("got %v, %v; want %v", got, err, ea)
+	}
+
+	revs, _ = api.History(ea.GetId())
+	checkHistoryActions(t, revs,
+		structs.ActionCreate, structs.ActionUpdate, structs.ActionDelete, structs.ActionRevert)
+
+	if _, err := api.Revert(ea.GetId(), 100); err == nil {
+		t.Fatal("err should be not nil")
+	}
+}
+
+func TestEventAPIRevertQuota(t *testing.T) {
+	m := models.NewEventModelMemory()
+	m.SetQuota(1)
+	api := NewEventAPI(m).WithRevisions(models.NewRevisionModelMemory())
+	date := time.Date(2019, 10, 10, 0, 0, 0, 0, time.UTC)
+	ea, _ := api.Create(structs.MakeEventNoId(1, date))
+	_ = api.Delete(ea.GetId())
+	_, _ = api.Create(structs.MakeEventNoId(1, date))
+
+	// Восстановить событие нельзя, поэтому откат не меняет ничего
+	revs, _ := api.History(ea.GetId())
+	if _, err := api.Revert(ea.GetId(), revs[0].GetId()); !errors.Is(err, structs.ErrQuotaExceeded) {
+		t.Fatalf("got %v; want ErrQuotaExceeded", err)
+	}
+	if trash, _ := api.Trash(1); len(trash) != 1 || trash[0].GetId() != ea.GetId() {
+		t.Fatalf("event should stay in trash, got %v", trash)
+	}
+	revs, _ = api.History(ea.GetId())
+	checkHistoryActions(t, revs, structs.ActionCreate, structs.ActionDelete)
+}
+
+func TestEventAPIHistoryConcurrent(t *testing.T) {
+	api := eventAPIWithHistory()
+	ea, _ := api.Create(structs.MakeEventNoId(0, time.Date(2019, 10, 10, 0, 0, 0, 0, time.UTC)))
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(userId structs.UserID) {
+			defer wg.Done()
+			upd := ea
+			upd.SetUserId(userId)
+			_, _ = api.Update(upd)
+		}(structs.UserID(i))
+	}
+	wg.Wait()
+
+	// Старое значение каждой ревизии - новое значение предыдущей
+	revs, _ := api.History(ea.GetId())
+	for i := 1; i < len(revs); i++ {
+		prev := revs[i-1].GetEvent()
+		diff := revs[i].GetDiff()
+		if len(diff) != 1 || diff[0].Old != strconv.Itoa(int(prev.GetUserId())) {
+			t.Fatalf("revision %d: diff %v does not follow %v", i, diff, prev)
+		}
+	}
+}
+
+func TestEventAPILoadSnapshotClearsHistory(t *testing.T) {
+	api := eventAPIWithHistory()
+	ea, _ := api.Create(structs.MakeEventNoId(1, time.Date(2019, 10, 10, 0, 0, 0, 0, time.UTC)))
+	snap, _ := api.Snapshot()
+	upd := ea
+	upd.SetUserId(2)
+	_, _ = api.Update(upd)
+	revs, _ := api.History(ea.GetId())
+
+	if err := api.LoadSnapshot(snap); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if got, _ := api.History(ea.GetId()); len(got) != 0 {
+		t.Fatalf("unexpected history: %v", got)
+	}
+	// Откат к ревизии до загрузки невозможен
+	if _, err := api.Revert(ea.GetId(), revs[1].GetId()); err == nil {
+		t.Fatal("err should be not nil")
+	}
+}
+
+func TestEventAPIHistoryDisabled(t *testing.T) {
+	api := eventAPIMemoryModel()
+	if _, err := api.History(0); err == nil {
+		t.Fatal("err should be not nil")
+	}
+}
@@ -0,0 +1,85 @@
+package logic
+
+import (
+	"dev11/structs"
+	"errors"
+	"time"
+)
+
+// Самый длинный период, для которого считаются рабочие дни
+const MaxWorkingDaysPeriod = 10 * 366
+
+// Интерфейс производственных календарей регионов
+type IHolidayCalendar interface {
+	HasRegion(region string) bool
+	Between(region string, from, to time.Time) []structs.Holiday
+}
+
+// Возвращает копию api, которая знает праздники регионов из cal.
+// defaultRegion используется, если регион не указан, пустая строка - нет такого
+func (api *EventAPI) WithHolidays(cal IHolidayCalendar, defaultRegion string) *EventAPI {
+	res := *api
+	res.hol = cal
+	res.region = defaultRegion
+	return &res
+}
+
+// Регион календаря для запроса region. Пустая строка - календарь не нужен
+func (api *EventAPI) holidayRegion(region string) (string, error) {
+	if region == "" {
+		region = api.region
+	}
+	if region == "" {
+		return "", nil
+	}
+	if api.hol == nil || !api.hol.HasRegion(region) {
+		return "", structs.ErrUnknownRegion
+	}
+	return region, nil
+}
+
+// Праздники и перенесенные рабочие дни региона в периоде [from, to].
+// Если регион не указан и нет региона по умолчанию, то возвращает nil
+func (api *EventAPI) Holidays(region string, from, to time.Time) ([]structs.Holiday, error) {
+	region, err := api.holidayRegion(region)
+	if err != nil || region == "" {
+		return nil, err
+	}
+	return api.hol.Between(region, from, to), nil
+}
+
+// Рабочие дни региона в периоде [from, to]. Выходные - суббота
+// и воскресенье, если календарь не говорит иначе
+func (api *EventAPI) WorkingDays(region string, from, to time.Time) (structs.WorkingDays, error) {
+	if to.Before(from) {
+		return structs.WorkingDays{}, errors.New("end before start")
+	}
+	if to.Sub(from) >= MaxWorkingDaysPeriod*24*time.Hour {
+		return structs.WorkingDays{}, errors.New("period is too long")
+	}
+	region, err := api.holidayRegion(region)
+	if err != nil {
+		return structs.WorkingDays{}, err
+	}
+	if region == "" {
+		return structs.WorkingDays{}, errors.New("region is not specified")
+	}
+
+	res := structs.WorkingDays{Holidays: api.hol.Between(region, from, to)}
+	// Ключ - дата строкой: time.Time с другой зоной не равен дате календаря
+	const dateKey = "2006-01-02"
+	special := make(map[string]bool, len(res.Holidays))
+	for _, h := range res.Holidays {
+		special[h.Date.Format(dateKey)] = h.Working
+	}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		working, ok := special[d.Format(dateKey)]
+		if !ok {
+			working = d.Weekday() != time.Saturday && d.Weekday() != time.Sunday
+		}
+		if working {
+			res.Days = append(res.Days, d)
+		}
+	}
+	return res, nil
+}
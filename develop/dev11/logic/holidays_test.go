@@ -0,0 +1,105 @@
+package logic
+
+import (
+	"dev11/holidays"
+	"dev11/structs"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func eventAPIWithHolidays(defaultRegion string) *EventAPI {
+	cal := holidays.NewCalendar([]structs.Holiday{
+		{Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Name: "Новый год", Region: "ru"},
+		{Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Name: "Каникулы", Region: "ru"},
+		{Date: time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC), Name: "Рабочая суббота", Region: "ru", Working: true},
+		{Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Name: "New Year", Region: "us"},
+	})
+	return eventAPIMemoryModel().WithHolidays(cal, defaultRegion)
+}
+
+func TestEventAPIWorkingDays(t *testing.T) {
+	api := eventAPIWithHolidays("ru")
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		region string
+		want   []int // Дни января
+	}{
+		{"", []int{3, 4, 5, 6}},
+		{"ru", []int{3, 4, 5, 6}},
+		{"us", []int{2, 3, 4, 5}},
+	}
+	for _, tc := range cases {
+		wd, err := api.WorkingDays(tc.region, from, to)
+		if err != nil {
+			t.Fatal("err should be nil", err)
+		}
+		var got []int
+		for _, d := range wd.Days {
+			got = append(got, d.Day())
+		}
+		if len(got) != len(tc.want) {
+			t.Fatalf("%q: got %v; want %v", tc.region, got, tc.want)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("%q: got %v; want %v", tc.region, got, tc.want)
+			}
+		}
+	}
+
+	// Та же неделя в другой зоне: праздники сравниваются по дате
+	for _, loc := range []*time.Location{time.FixedZone("MSK", 3*60*60), time.FixedZone("EST", -5*60*60)} {
+		wd, err := api.WorkingDays("ru", time.Date(2024, 1, 1, 0, 0, 0, 0, loc), time.Date(2024, 1, 7, 0, 0, 0, 0, loc))
+		if err != nil {
+			t.Fatal("err should be nil", err)
+		}
+		var got []int
+		for _, d := range wd.Days {
+			got = append(got, d.Day())
+		}
+		if !reflect.DeepEqual(got, []int{3, 4, 5, 6}) {
+			t.Fatalf("%s: got %v; want %v", loc, got, []int{3, 4, 5, 6})
+		}
+	}
+
+	if _, err := api.WorkingDays("de", from, to); !errors.Is(err, structs.ErrUnknownRegion) {
+		t.Fatalf("got %v; want %v", err, structs.ErrUnknownRegion)
+	}
+	if _, err := api.WorkingDays("ru", to, from); err == nil {
+		t.Fatal("err should not be nil")
+	}
+	if _, err := api.WorkingDays("ru", from, from.AddDate(20, 0, 0)); err == nil {
+		t.Fatal("err should not be nil")
+	}
+	if _, err := eventAPIWithHolidays("").WorkingDays("", from, to); err == nil {
+		t.Fatal("err should not be nil")
+	}
+	if _, err := eventAPIMemoryModel().WorkingDays("ru", from, to); !errors.Is(err, structs.ErrUnknownRegion) {
+		t.Fatalf("got %v; want %v", err, structs.ErrUnknownRegion)
+	}
+}
+
+func TestEventAPIHolidays(t *testing.T) {
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	from, to := WeekPeriod(day)
+	list, err := eventAPIWithHolidays("ru").Holidays("", from, to)
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if len(list) != 2 || list[0].Name != "Новый год" || list[1].Name != "Каникулы" {
+		t.Fatalf("unexpected holidays %+v", list)
+	}
+
+	// Без региона праздники не нужны
+	for _, api := range []*EventAPI{eventAPIWithHolidays(""), eventAPIMemoryModel()} {
+		list, err := api.Holidays("", day, day)
+		if err != nil || list != nil {
+			t.Fatalf("got %v, %v; want nil", list, err)
+		}
+	}
+}
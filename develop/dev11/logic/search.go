@@ -0,0 +1,62 @@
+package logic
+
+import (
+	"dev11/structs"
+	"errors"
+)
+
+// Интерфейс полнотекстового индекса событий
+type ISearchIndex interface {
+	Put(e structs.Event)
+	Remove(id structs.EventID)
+	Reset(events []structs.Event)
+	Search(q structs.SearchQuery) []structs.SearchHit
+}
+
+// Возвращает копию api, которая поддерживает индекс idx в актуальном
+// состоянии. Индекс сразу заполняется событиями из модели
+func (api *EventAPI) WithSearch(idx ISearchIndex) *EventAPI {
+	res := *api
+	res.idx = idx
+	res.reindex()
+	return &res
+}
+
+// Ищет события по заголовку и описанию
+func (api *EventAPI) Search(q structs.SearchQuery) ([]structs.SearchHit, error) {
+	if api.idx == nil {
+		return nil, errors.New("search is disabled")
+	}
+	return api.idx.Search(q), nil
+}
+
+func (api *EventAPI) index(e structs.Event) {
+	if api.idx != nil {
+		api.idx.Put(e)
+	}
+}
+
+func (api *EventAPI) unindex(id structs.EventID) {
+	if api.idx != nil {
+		api.idx.Remove(id)
+	}
+}
+
+// Заполняет индекс заново событиями вне корзины.
+// Вызывающий должен держать api.lock, если api уже используется
+func (api *EventAPI) reindex() {
+	if api.idx == nil {
+		return
+	}
+	s, err := api.m.Dump()
+	if err != nil {
+		return
+	}
+	alive := make([]structs.Event, 0, len(s.Events))
+	for _, e := range s.Events {
+		if !e.IsDeleted() {
+			alive = append(alive, e)
+		}
+	}
+	api.idx.Reset(alive)
+}
@@ -0,0 +1,105 @@
+package logic
+
+import (
+	"dev11/models"
+	"dev11/search"
+	"dev11/structs"
+	"sync"
+	"testing"
+	"time"
+)
+
+func searchIds(t *testing.T, api *EventAPI, text string) []structs.EventID {
+	t.Helper()
+	hits, err := api.Search(structs.SearchQuery{Text: text, UserId: -1})
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	res := []structs.EventID{}
+	for _, h := range hits {
+		res = append(res, h.Event.GetId())
+	}
+	return res
+}
+
+func TestEventAPISearchFollowsMutations(t *testing.T) {
+	date := time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)
+	m := models.NewEventModelMemory()
+
+	// События, созданные до включения поиска, тоже индексируются
+	eni := structs.MakeEventNoId(1, date)
+	eni.SetTitle("Планерка")
+	old, _ := m.Create(eni)
+	api := NewEventAPI(m).WithSearch(search.NewIndex())
+	if got := searchIds(t, api, "планерка"); len(got) != 1 || got[0] != old.GetId() {
+		t.Fatalf("got %v", got)
+	}
+
+	// Изменение
+	upd := old
+	upd.SetTitle("Ретро")
+	_, _ = api.Update(upd)
+	if got := searchIds(t, api, "планерка"); len(got) != 0 {
+		t.Fatalf("got %v", got)
+	}
+
+	// Удаление и восстановление
+	_ = api.Delete(old.GetId())
+	if got := searchIds(t, api, "ретро"); len(got) != 0 {
+		t.Fatalf("got %v", got)
+	}
+	_, _ = api.Restore(old.GetId())
+	if got := searchIds(t, api, "ретро"); len(got) != 1 {
+		t.Fatalf("got %v", got)
+	}
+
+	// Пакет
+	eni.SetTitle("Ретро спринта")
+	_, _ = api.Batch([]structs.BatchOp{
+		structs.MakeBatchCreate(eni),
+		structs.MakeBatchDelete(old.GetId()),
+	}, true)
+	if got := searchIds(t, api, "ретро"); len(got) != 1 || got[0] == old.GetId() {
+		t.Fatalf("got %v", got)
+	}
+
+	// Загрузка снимка заменяет индекс
+	_ = api.LoadSnapshot(structs.Snapshot{})
+	if got := searchIds(t, api, "ретро"); len(got) != 0 {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestEventAPISearchDisabled(t *testing.T) {
+	api := NewEventAPI(models.NewEventModelMemory())
+	if _, err := api.Search(structs.SearchQuery{Text: "a"}); err == nil {
+		t.Fatal("err should be not nil")
+	}
+}
+
+func TestEventAPISearchConcurrent(t *testing.T) {
+	date := time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)
+	api := NewEventAPI(models.NewEventModelMemory()).WithSearch(search.NewIndex())
+	eni := structs.MakeEventNoId(1, date)
+	eni.SetTitle("Планерка")
+
+	// Удаленное событие не должно остаться в индексе, даже если
+	// изменение выполнялось одновременно с удалением
+	for i := 0; i < 50; i++ {
+		ev, _ := api.Create(eni)
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, _ = api.Update(ev)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = api.Delete(ev.GetId())
+		}()
+		wg.Wait()
+	}
+	if got := searchIds(t, api, "планерка"); len(got) != 0 {
+		t.Fatalf("got %v", got)
+	}
+}
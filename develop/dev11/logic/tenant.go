@@ -0,0 +1,128 @@
+package logic
+
+import (
+	"context"
+	"dev11/structs"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Арендатор по умолчанию, если запрос не указал другого
+const DefaultTenant = "default"
+
+type tenantKey struct{}
+
+// Возвращает копию ctx, операции в которой выполняются для арендатора tenant
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Арендатор из ctx, если он не задан, то DefaultTenant
+func TenantFromContext(ctx context.Context) string {
+	if tenant, ok := ctx.Value(tenantKey{}).(string); ok && tenant != "" {
+		return tenant
+	}
+	return DefaultTenant
+}
+
+// Набор EventAPI, по одному на арендатора. У каждого арендатора
+// свое хранилище, поэтому id событий разных арендаторов не пересекаются
+type Tenants struct {
+	lock   sync.Mutex
+	apis   map[string]*EventAPI
+	newAPI func(tenant string) *EventAPI
+	max    int // Сколько арендаторов создает ForContext, 0 - без ограничений
+}
+
+// Создает набор, в котором api для нового арендатора создается функцией newAPI
+func NewTenants(newAPI func(tenant string) *EventAPI) *Tenants {
+	return &Tenants{
+		apis:   make(map[string]*EventAPI),
+		newAPI: newAPI,
+	}
+}
+
+// Набор, в котором все арендаторы работают с одним api
+func SingleTenant(api *EventAPI) *Tenants {
+	return NewTenants(func(string) *EventAPI {
+		return api
+	})
+}
+
+// Ограничивает количество арендаторов, которых создает ForContext, 0 - без ограничений.
+// Уже созданные арендаторы не удаляются
+func (t *Tenants) SetLimit(n int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.max = n
+}
+
+// Возвращает api арендатора tenant, создавая его при первом обращении.
+// Ограничение SetLimit не проверяется, поэтому имена из запросов
+// должны проходить через ForContext
+func (t *Tenants) Get(tenant string) *EventAPI {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.get(tenant)
+}
+
+// get без блокировки, вызывающий должен держать lock
+func (t *Tenants) get(tenant string) *EventAPI {
+	api, ok := t.apis[tenant]
+	if !ok {
+		api = t.newAPI(tenant)
+		t.apis[tenant] = api
+	}
+	return api
+}
+
+// Возвращает api арендатора из ctx. Хранилище нового арендатора создается
+// только здесь, то есть при первой операции с событиями, и только
+// если ограничение SetLimit еще не достигнуто
+func (t *Tenants) ForContext(ctx context.Context) (*EventAPI, error) {
+	tenant := TenantFromContext(ctx)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, ok := t.apis[tenant]; !ok && t.max > 0 && len(t.apis) >= t.max {
+		return nil, structs.ErrTooManyTenants
+	}
+	return t.get(tenant), nil
+}
+
+// Отсортированный список арендаторов, к которым уже обращались
+func (t *Tenants) List() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	res := make([]string, 0, len(t.apis))
+	for tenant := range t.apis {
+		res = append(res, tenant)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Раз в interval очищает корзины всех арендаторов от событий старше retention.
+// Работает, пока не будет отменен ctx
+func (t *Tenants) PurgeLoop(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, tenant := range t.List() {
+				n, err := t.Get(tenant).Purge(retention)
+				if err != nil {
+					log.Printf("purge %s: %s", tenant, err)
+				} else if n > 0 {
+					log.Printf("purge %s: %d events removed", tenant, n)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package logic
+
+import (
+	"context"
+	"dev11/models"
+	"dev11/structs"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTenantsIsolation(t *testing.T) {
+	tenants := NewTenants(func(string) *EventAPI {
+		return NewEventAPI(models.NewEventModelMemory())
+	})
+	date := time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)
+
+	a, err := tenants.ForContext(WithTenant(context.Background(), "a"))
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	b, err := tenants.ForContext(WithTenant(context.Background(), "b"))
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	ea, _ := a.Create(structs.MakeEventNoId(1, date))
+	eb, _ := b.Create(structs.MakeEventNoId(2, date))
+
+	// У каждого арендатора свои id
+	if ea.GetId() != 0 || eb.GetId() != 0 {
+		t.Fatalf("got ids %d, %d; want 0, 0", ea.GetId(), eb.GetId())
+	}
+	list, _ := a.ForDay(date)
+	if len(list) != 1 || list[0].GetUserId() != 1 {
+		t.Fatalf("tenant a sees %v", list)
+	}
+
+	// Тот же арендатор - тот же api
+	if tenants.Get("a") != a {
+		t.Fatal("api should be reused")
+	}
+	if got := TenantFromContext(context.Background()); got != DefaultTenant {
+		t.Fatalf("got %q; want %q", got, DefaultTenant)
+	}
+	if got := tenants.List(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestTenantsLimit(t *testing.T) {
+	tenants := NewTenants(func(string) *EventAPI {
+		return NewEventAPI(models.NewEventModelMemory())
+	})
+	tenants.SetLimit(2)
+
+	for _, tenant := range []string{"a", "b", "a"} {
+		if _, err := tenants.ForContext(WithTenant(context.Background(), tenant)); err != nil {
+			t.Fatalf("%s: err should be nil: %s", tenant, err)
+		}
+	}
+	if _, err := tenants.ForContext(WithTenant(context.Background(), "c")); !errors.Is(err, structs.ErrTooManyTenants) {
+		t.Fatalf("got %v; want ErrTooManyTenants", err)
+	}
+	if got := tenants.List(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("got %v", got)
+	}
+	// Get используется для имен, которые не пришли из запросов, и не ограничен
+	tenants.Get("c")
+	if got := tenants.List(); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Fatalf("got %v", got)
+	}
+}
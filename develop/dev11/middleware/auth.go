@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"dev11/response"
+	"net/http"
+	"strings"
+)
+
+// Обворачивает функцию next, пропуская только запросы
+// с заголовком Authorization: Bearer <token>
+func WithToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+			next.ServeHTTP(w, req)
+			return
+		}
+		response.Error(w, response.CodeUnauthenticated, "unauthorized")
+	})
+}
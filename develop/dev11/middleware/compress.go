@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Выбирает кодировку из заголовка Accept-Encoding: gzip, затем deflate.
+// Кодировки с q=0 запрещены. Пустая строка - без сжатия
+func negotiateEncoding(header string) string {
+	q := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				weight = f
+			}
+		}
+		q[strings.ToLower(strings.TrimSpace(name))] = weight
+	}
+	best, bestQ := "", 0.0
+	for _, enc := range []string{"gzip", "deflate"} {
+		w, ok := q[enc]
+		if !ok {
+			w, ok = q["*"]
+		}
+		if ok && w > bestQ {
+			best, bestQ = enc, w
+		}
+	}
+	return best
+}
+
+// Стоит ли сжимать ответ с таким типом содержимого
+func compressible(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/json") ||
+		strings.HasPrefix(contentType, "text/")
+}
+
+// Накапливает ответ, чтобы решить, сжимать ли его
+type bufferedWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (bw *bufferedWriter) WriteHeader(status int) {
+	if bw.status == 0 {
+		bw.status = status
+	}
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	return bw.buf.Write(b)
+}
+
+// Обворачивает функцию next, сжимая текстовые ответы длиннее minSize байт
+// кодировкой, которую принимает клиент
+func Compress(minSize int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		enc := negotiateEncoding(req.Header.Get("Accept-Encoding"))
+		if enc == "" || req.Method == http.MethodHead {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		bw := &bufferedWriter{ResponseWriter: w}
+		next.ServeHTTP(bw, req)
+		if bw.status == 0 {
+			bw.status = http.StatusOK
+		}
+
+		h := w.Header()
+		if bw.buf.Len() == 0 || bw.buf.Len() < minSize || h.Get("Content-Encoding") != "" || !compressible(h.Get("Content-Type")) {
+			w.WriteHeader(bw.status)
+			_, _ = w.Write(bw.buf.Bytes())
+			return
+		}
+
+		h.Set("Content-Encoding", enc)
+		h.Del("Content-Length")
+		w.WriteHeader(bw.status)
+		// В HTTP deflate - это поток zlib, а не "голый" deflate
+		var zw io.WriteCloser
+		if enc == "gzip" {
+			zw = gzip.NewWriter(w)
+		} else {
+			zw = zlib.NewWriter(w)
+		}
+		_, _ = zw.Write(bw.buf.Bytes())
+		_ = zw.Close()
+	})
+}
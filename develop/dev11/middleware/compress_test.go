@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := map[string]string{
+		"":                       "",
+		"gzip":                   "gzip",
+		"deflate, gzip":          "gzip",
+		"gzip;q=0.5, deflate":    "deflate",
+		"gzip;q=0, deflate;q=0":  "",
+		"br":                     "",
+		"*":                      "gzip",
+		"GZIP;q=0.8":             "gzip",
+		"identity, deflate;q=.5": "deflate",
+	}
+	for header, want := range cases {
+		if got := negotiateEncoding(header); got != want {
+			t.Errorf("%q: got %q; want %q", header, got, want)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	body := `{"result":"` + strings.Repeat("a", 2000) + `"}`
+	h := Compress(1024, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/small" {
+			_, _ = io.WriteString(w, `{"result":"a"}`)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, body)
+	}))
+
+	for _, enc := range []string{"gzip", "deflate"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", enc)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusCreated || rr.Header().Get("Content-Encoding") != enc {
+			t.Fatalf("%s: got %d %v", enc, rr.Code, rr.Header())
+		}
+		var zr io.Reader
+		var err error
+		if enc == "gzip" {
+			zr, err = gzip.NewReader(rr.Body)
+		} else {
+			zr, err = zlib.NewReader(rr.Body)
+		}
+		if err != nil {
+			t.Fatal("err should be nil", err)
+		}
+		got, _ := io.ReadAll(zr)
+		if string(got) != body {
+			t.Fatalf("%s: body mismatch", enc)
+		}
+	}
+
+	// Маленькие ответы и клиенты без сжатия получают ответ как есть
+	req := httptest.NewRequest("GET", "/small", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Header().Get("Content-Encoding") != "" || rr.Body.String() != `{"result":"a"}` {
+		t.Fatalf("small response should not be compressed: %v", rr.Header())
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Header().Get("Content-Encoding") != "" || rr.Body.String() != body {
+		t.Fatal("response should not be compressed")
+	}
+	if rr.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("got Vary %q", rr.Header().Get("Vary"))
+	}
+}
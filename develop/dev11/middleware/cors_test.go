@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	c := CORSConfig{
+		AllowedOrigins: []string{"https://app.example"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type", "X-Tenant"},
+		ExposedHeaders: []string{"ETag"},
+		MaxAge:         time.Hour,
+	}
+	calls := 0
+	h := CORS(c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+
+	cases := []struct {
+		name        string
+		method      string
+		origin      string
+		reqMethod   string
+		wantCode    int
+		wantOrigin  string
+		wantMethods string
+		wantCalls   int
+	}{
+		{"no origin", "GET", "", "", http.StatusOK, "", "", 1},
+		{"simple", "GET", "https://app.example", "", http.StatusOK, "https://app.example", "", 1},
+		{"preflight", "OPTIONS", "https://app.example", "POST", http.StatusNoContent, "https://app.example", "GET, POST", 0},
+		{"bad origin", "GET", "https://evil.example", "", http.StatusOK, "", "", 1},
+		{"bad origin preflight", "OPTIONS", "https://evil.example", "POST", http.StatusNoContent, "", "", 0},
+	}
+	for _, tc := range cases {
+		calls = 0
+		req := httptest.NewRequest(tc.method, "/", nil)
+		if tc.origin != "" {
+			req.Header.Set("Origin", tc.origin)
+		}
+		if tc.reqMethod != "" {
+			req.Header.Set("Access-Control-Request-Method", tc.reqMethod)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.wantCode || calls != tc.wantCalls ||
+			rr.Header().Get("Access-Control-Allow-Origin") != tc.wantOrigin ||
+			rr.Header().Get("Access-Control-Allow-Methods") != tc.wantMethods {
+			t.Errorf("%s: got %d, calls %d, headers %v", tc.name, rr.Code, calls, rr.Header())
+		}
+	}
+}
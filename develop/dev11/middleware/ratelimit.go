@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"dev11/response"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Ограничитель частоты запросов по алгоритму token bucket.
+// У каждого клиента своя корзина из burst токенов, которая пополняется
+// со скоростью rate токенов в секунду. Каждый запрос забирает один токен
+type RateLimiter struct {
+	lock    sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+	sweepAt int              // При таком количестве корзин удаляем неактивные
+	now     func() time.Time // Подменяется в тестах
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		sweepAt: 1024,
+		now:     time.Now,
+	}
+}
+
+// Пытается забрать токен клиента key.
+// Если токенов нет, то возвращает false и время до появления следующего
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= l.sweepAt {
+			l.sweep(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	// Пополняем корзину за прошедшее время
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// Удаляет корзины, которые успели наполниться - они ничем не отличаются от новых.
+// Если удалять нечего, то порог увеличивается, чтобы не перебирать map на каждый запрос
+func (l *RateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	if len(l.buckets) >= l.sweepAt/2 {
+		l.sweepAt *= 2
+	}
+}
+
+// Возвращает IP клиента, используется как ключ для RateLimit
+func ClientIP(req *http.Request) string {
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		return host
+	}
+	return req.RemoteAddr
+}
+
+// Возвращает ключ для RateLimit по пользователю из заголовка header,
+// запросы без заголовка ограничиваются по IP. Заголовок должен выставлять
+// прокси после аутентификации, иначе клиент может менять его сам
+func UserKey(header string) func(*http.Request) string {
+	return func(req *http.Request) string {
+		if user := req.Header.Get(header); user != "" {
+			// Префикс не дает пользователю совпасть с чьим-то IP
+			return "user:" + user
+		}
+		return ClientIP(req)
+	}
+}
+
+// Обворачивает функцию next, ограничивая частоту запросов каждого клиента.
+// Клиент определяется функцией key (например, ClientIP или UserKey)
+func RateLimit(l *RateLimiter, key func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ok, wait := l.Allow(key(req))
+		if ok {
+			next.ServeHTTP(w, req)
+			return
+		}
+		retry := int(math.Ceil(wait.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(retry))
+		response.Error(w, response.CodeRateLimited, "too many requests")
+	})
+}
+
+// Обворачивает функцию next, ограничивая размер тела запроса n байтами
+func MaxBytes(n int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.Body = http.MaxBytesReader(w, req.Body, n)
+		next.ServeHTTP(w, req)
+	})
+}
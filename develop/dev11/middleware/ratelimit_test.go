@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	now := time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)
+	l := NewRateLimiter(2, 3)
+	l.now = func() time.Time { return now }
+
+	// Сначала доступен весь burst
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	ok, wait := l.Allow("a")
+	if ok {
+		t.Fatal("request should be limited")
+	}
+	if wait != 500*time.Millisecond {
+		t.Fatalf("got wait %v; want %v", wait, 500*time.Millisecond)
+	}
+
+	// Другой клиент не затронут
+	if ok, _ := l.Allow("b"); !ok {
+		t.Fatal("other client should be allowed")
+	}
+
+	// Через полсекунды появляется токен
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Fatal("request should be allowed after refill")
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	now := time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)
+	l := NewRateLimiter(1, 1)
+	l.now = func() time.Time { return now }
+	l.sweepAt = 2
+
+	l.Allow("a")
+	l.Allow("b")
+	now = now.Add(time.Hour)
+	l.Allow("c")
+	if len(l.buckets) != 1 {
+		t.Fatalf("got %d buckets; want 1", len(l.buckets))
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	l := NewRateLimiter(1, 1)
+	h := RateLimit(l, ClientIP, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got %d; want %d", rr.Code, http.StatusOK)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("got %d; want %d", rr.Code, http.StatusTooManyRequests)
+	}
+	if rr.Header().Get("Retry-After") != "1" {
+		t.Fatalf("got Retry-After %q; want 1", rr.Header().Get("Retry-After"))
+	}
+}
+
+func TestUserKey(t *testing.T) {
+	key := UserKey("X-Actor")
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	if got := key(req); got != "10.0.0.1" {
+		t.Fatalf("got %q; want %q", got, "10.0.0.1")
+	}
+	req.Header.Set("X-Actor", "alice")
+	if got := key(req); got != "user:alice" {
+		t.Fatalf("got %q; want %q", got, "user:alice")
+	}
+
+	// Пользователи с одного IP ограничиваются независимо
+	l := NewRateLimiter(1, 1)
+	h := RateLimit(l, key, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, user := range []string{"alice", "bob"} {
+		req.Header.Set("X-Actor", user)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: got %d; want %d", user, rr.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	var readErr error
+	h := MaxBytes(4, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader("1234")))
+	if readErr != nil {
+		t.Fatal("err should be nil", readErr)
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader("12345")))
+	if readErr == nil {
+		t.Fatal("err should be not nil")
+	}
+}
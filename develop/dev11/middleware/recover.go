@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"dev11/response"
+	"log"
+	"net/http"
+	"runtime/debug"
+)
+
+// Запоминает, отправлены ли уже заголовки ответа
+type trackingWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (tw *trackingWriter) WriteHeader(status int) {
+	tw.wroteHeader = true
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+func (tw *trackingWriter) Write(b []byte) (int, error) {
+	tw.wroteHeader = true
+	return tw.ResponseWriter.Write(b)
+}
+
+// Обворачивает функцию next, превращая панику в ответ 500.
+// Если заголовки уже отправлены, то ответ исправить нельзя,
+// и соединение обрывается как обычно
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		tw := &trackingWriter{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler || tw.wroteHeader {
+				panic(v)
+			}
+			log.Printf("panic: %s %s: %v\n%s", req.Method, req.RequestURI, v, debug.Stack())
+			// Заголовки частично сформированного ответа больше не верны
+			h := w.Header()
+			for _, k := range []string{"Content-Encoding", "Content-Length", "ETag", "Last-Modified", "Cache-Control"} {
+				h.Del(k)
+			}
+			response.Error(w, response.CodeInternal, "internal server error")
+		}()
+		next.ServeHTTP(tw, req)
+	})
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithMethod(t *testing.T) {
+	h := WithMethod("POST", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "ok" {
+		t.Fatalf("got %d %q", rr.Code, rr.Body)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Allow") != "POST" ||
+		rr.Header().Get("Content-Type") != "application/json" ||
+		rr.Body.String() != `{"error":"method not allowed","code":"method_not_allowed"}`+"\n" {
+		t.Fatalf("got %d %v %q", rr.Code, rr.Header(), rr.Body)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	h := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"x"`)
+		panic("boom")
+	}))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusInternalServerError || rr.Header().Get("ETag") != "" ||
+		rr.Body.String() != `{"error":"internal server error","code":"internal"}`+"\n" {
+		t.Fatalf("got %d %v %q", rr.Code, rr.Header(), rr.Body)
+	}
+
+	// Если ответ уже начат, паника передается серверу
+	h = Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "partial")
+		panic("boom")
+	}))
+	defer func() {
+		if recover() == nil {
+			t.Fatal("panic should be propagated")
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"dev11/logic"
+	"dev11/response"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Определяет арендатора запроса: сначала по заголовку Header,
+// затем по поддомену Domain (team.calendar.example -> team).
+// Если ничего не найдено, то используется Default
+type TenantResolver struct {
+	Header  string
+	Domain  string
+	Default string // Пустая строка - запросы без арендатора отклоняются
+}
+
+// Возвращает арендатора запроса r
+func (tr TenantResolver) Resolve(r *http.Request) (string, error) {
+	tenant := ""
+	if tr.Header != "" {
+		tenant = r.Header.Get(tr.Header)
+	}
+	if tenant == "" && tr.Domain != "" {
+		host := strings.ToLower(r.Host)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if sub, ok := strings.CutSuffix(host, "."+tr.Domain); ok && !strings.Contains(sub, ".") {
+			tenant = sub
+		}
+	}
+	if tenant == "" {
+		tenant = tr.Default
+	}
+	if tenant == "" {
+		return "", errors.New("tenant is not specified")
+	}
+	if !validTenant(tenant) {
+		return "", errors.New("bad tenant name")
+	}
+	return tenant, nil
+}
+
+// Имя арендатора: от 1 до 63 символов a-z, 0-9 и '-'
+func validTenant(s string) bool {
+	if len(s) == 0 || len(s) > 63 {
+		return false
+	}
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// Обворачивает функцию next, добавляя арендатора запроса в его контекст.
+// Если арендатора определить нельзя, то отвечает 400
+func Tenant(tr TenantResolver, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		tenant, err := tr.Resolve(req)
+		if err != nil {
+			response.Error(w, response.CodeInvalidArgument, err.Error())
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(logic.WithTenant(req.Context(), tenant)))
+	})
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"dev11/logic"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTenantResolver(t *testing.T) {
+	tr := TenantResolver{Header: "X-Tenant", Domain: "cal.example"}
+	cases := []struct {
+		name   string
+		host   string
+		header string
+		want   string
+		ok     bool
+	}{
+		{"header", "localhost", "team-a", "team-a", true},
+		{"header wins", "b.cal.example", "a", "a", true},
+		{"subdomain", "b.cal.example:8080", "", "b", true},
+		{"upper case host", "B.Cal.Example", "", "b", true},
+		{"nested subdomain", "x.b.cal.example", "", "", false},
+		{"bare domain", "cal.example", "", "", false},
+		{"bad name", "localhost", "Team_A", "", false},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = c.host
+		if c.header != "" {
+			req.Header.Set("X-Tenant", c.header)
+		}
+		got, err := tr.Resolve(req)
+		if (err == nil) != c.ok || got != c.want {
+			t.Errorf("%s: got %q, %v; want %q", c.name, got, err, c.want)
+		}
+	}
+
+	// Арендатор по умолчанию
+	tr.Default = logic.DefaultTenant
+	got, err := tr.Resolve(httptest.NewRequest("GET", "/", nil))
+	if err != nil || got != logic.DefaultTenant {
+		t.Fatalf("got %q, %v; want %q", got, err, logic.DefaultTenant)
+	}
+}
+
+func TestTenantMiddleware(t *testing.T) {
+	h := Tenant(TenantResolver{Header: "X-Tenant"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, logic.TenantFromContext(r.Context()))
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Tenant", "a")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "a" {
+		t.Fatalf("got %d %q", rr.Code, rr.Body)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusBadRequest || rr.Body.String() != `{"error":"tenant is not specified","code":"invalid_argument"}`+"\n" {
+		t.Fatalf("got %d %q", rr.Code, rr.Body)
+	}
+}
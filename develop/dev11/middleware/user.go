@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"dev11/logic"
+	"net/http"
+)
+
+// Обворачивает функцию next, добавляя в контекст запроса пользователя
+// из заголовка header. Заголовок должен выставлять прокси после
+// аутентификации, удаляя присланный клиентом, иначе клиент может
+// выдать себя за кого угодно
+func User(header string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if user := req.Header.Get(header); user != "" {
+			req = req.WithContext(logic.WithUser(req.Context(), user))
+		}
+		next.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"dev11/logic"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUserMiddleware(t *testing.T) {
+	var got string
+	var ok bool
+	h := User("X-User", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got, ok = logic.UserFromContext(req.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-User", "alice")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if !ok || got != "alice" {
+		t.Fatalf("got %q %v; want alice", got, ok)
+	}
+
+	// Другие заголовки, например X-Actor, пользователя не задают
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Actor", "mallory")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if ok {
+		t.Fatalf("user should not be set, got %q", got)
+	}
+}
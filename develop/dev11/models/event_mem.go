@@ -3,6 +3,7 @@ package models
 import (
 	"dev11/structs"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -11,12 +12,46 @@ type EventModelMemory struct {
 	lock   sync.RWMutex
 	events []structs.Event
 	freeId structs.EventID
+	quota  int // Сколько событий вне корзины можно хранить, 0 - без ограничений
+	alive  int // Сколько событий вне корзины хранится сейчас
+	// Момент последнего изменения, нулевое значение - изменений не было
+	modifiedAt time.Time
 }
 
 func NewEventModelMemory() *EventModelMemory {
 	return &EventModelMemory{}
 }
 
+// Ограничивает количество событий вне корзины, 0 - без ограничений.
+// Уже сохраненные события не удаляются
+func (m *EventModelMemory) SetQuota(n int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.quota = n
+}
+
+// Проверяет, что можно добавить еще одно событие вне корзины.
+// Вызывающий должен держать lock
+func (m *EventModelMemory) checkQuota() error {
+	if m.quota > 0 && m.alive >= m.quota {
+		return structs.ErrQuotaExceeded
+	}
+	return nil
+}
+
+// Возвращает момент последнего изменения событий
+func (m *EventModelMemory) ModifiedAt() time.Time {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.modifiedAt
+}
+
+// Запоминает момент изменения, вызывающий должен держать lock
+func (m *EventModelMemory) touch() {
+	m.modifiedAt = time.Now().UTC()
+}
+
+// Ищет индекс события, включая находящиеся в корзине
 func (m *EventModelMemory) findidx(id structs.EventID) (int, bool) {
 	for i, v := range m.events {
 		if v.GetId() == id {
@@ -26,13 +61,34 @@ func (m *EventModelMemory) findidx(id structs.EventID) (int, bool) {
 	return 0, false
 }
 
+// Ищет индекс события, не находящегося в корзине
+func (m *EventModelMemory) findAliveIdx(id structs.EventID) (int, bool) {
+	if idx, ok := m.findidx(id); ok && !m.events[idx].IsDeleted() {
+		return idx, true
+	}
+	return 0, false
+}
+
 func (m *EventModelMemory) Create(newe structs.EventNoId) (structs.Event, error) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
+	ev, err := m.create(newe)
+	if err == nil {
+		m.touch()
+	}
+	return ev, err
+}
+
+// create без блокировки, вызывающий должен держать lock
+func (m *EventModelMemory) create(newe structs.EventNoId) (structs.Event, error) {
+	if err := m.checkQuota(); err != nil {
+		return structs.Event{}, err
+	}
 	// В качестве нового id берем просто следующий элемент
 	newEv, _ := newe.MakeEventWithId(m.freeId)
 	m.freeId++
 	m.events = append(m.events, newEv)
+	m.alive++
 	return newEv, nil
 }
 
@@ -40,7 +96,7 @@ func (m *EventModelMemory) SelectById(id structs.EventID) (structs.Event, error)
 	m.lock.RLock()
 	defer m.lock.RUnlock()
 
-	if idx, ok := m.findidx(id); ok {
+	if idx, ok := m.findAliveIdx(id); ok {
 		return m.events[idx], nil
 	}
 	return structs.Event{}, errors.New("no such element id")
@@ -56,6 +112,9 @@ func (m *EventModelMemory) SelectBetweenDates(start, end time.Time) ([]structs.E
 
 	var res []structs.Event
 	for _, el := range m.events {
+		if el.IsDeleted() {
+			continue
+		}
 		edata := el.GetDate()
 		// [start, end] -> !before(start) && !after(end)
 		if !edata.Before(start) && !edata.After(end) {
@@ -68,8 +127,16 @@ func (m *EventModelMemory) SelectBetweenDates(start, end time.Time) ([]structs.E
 func (m *EventModelMemory) Update(e structs.Event) (structs.Event, error) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
+	ev, err := m.update(e)
+	if err == nil {
+		m.touch()
+	}
+	return ev, err
+}
 
-	if idx, ok := m.findidx(e.GetId()); ok {
+// update без блокировки, вызывающий должен держать lock
+func (m *EventModelMemory) update(e structs.Event) (structs.Event, error) {
+	if idx, ok := m.findAliveIdx(e.GetId()); ok {
 		m.events[idx] = e
 		return e, nil
 	}
@@ -79,12 +146,202 @@ func (m *EventModelMemory) Update(e structs.Event) (structs.Event, error) {
 func (m *EventModelMemory) Delete(id structs.EventID) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
+	err := m.delete(id)
+	if err == nil {
+		m.touch()
+	}
+	return err
+}
 
-	if idx, ok := m.findidx(id); ok {
-		back := len(m.events) - 1
-		m.events[idx], m.events[back] = m.events[back], m.events[idx]
-		m.events = m.events[:back]
+// delete без блокировки, вызывающий должен держать lock.
+// Событие не удаляется, а помещается в корзину
+func (m *EventModelMemory) delete(id structs.EventID) error {
+	if idx, ok := m.findAliveIdx(id); ok {
+		m.events[idx].SetDeletedAt(time.Now().UTC())
+		m.alive--
 		return nil
 	}
 	return errors.New("no such element id")
 }
+
+// Возвращает содержимое корзины пользователя
+func (m *EventModelMemory) SelectDeleted(userId structs.UserID) ([]structs.Event, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	var res []structs.Event
+	for _, el := range m.events {
+		if el.IsDeleted() && el.GetUserId() == userId {
+			res = append(res, el)
+		}
+	}
+	return res, nil
+}
+
+// Достает событие из корзины
+func (m *EventModelMemory) Restore(id structs.EventID) (structs.Event, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if idx, ok := m.findidx(id); ok && m.events[idx].IsDeleted() {
+		if err := m.checkQuota(); err != nil {
+			return structs.Event{}, err
+		}
+		m.events[idx].SetDeletedAt(time.Time{})
+		m.alive++
+		m.touch()
+		return m.events[idx], nil
+	}
+	return structs.Event{}, errors.New("no such deleted element id")
+}
+
+// Заменяет событие с id e на e. Если событие лежит в корзине,
+// то оно восстанавливается с учетом квоты
+func (m *EventModelMemory) Revert(e structs.Event) (structs.Event, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	idx, ok := m.findidx(e.GetId())
+	if !ok {
+		return structs.Event{}, errors.New("no such element id")
+	}
+	if m.events[idx].IsDeleted() {
+		if err := m.checkQuota(); err != nil {
+			return structs.Event{}, err
+		}
+		m.alive++
+	}
+	e.SetDeletedAt(time.Time{})
+	m.events[idx] = e
+	m.touch()
+	return e, nil
+}
+
+// Окончательно удаляет события, помещенные в корзину раньше before.
+// Возвращает количество удаленных событий
+func (m *EventModelMemory) Purge(before time.Time) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var n int
+	for i := 0; i < len(m.events); {
+		el := m.events[i]
+		if el.IsDeleted() && el.GetDeletedAt().Before(before) {
+			back := len(m.events) - 1
+			m.events[i], m.events[back] = m.events[back], m.events[i]
+			m.events = m.events[:back]
+			n++
+			continue
+		}
+		i++
+	}
+	if n > 0 {
+		m.touch()
+	}
+	return n, nil
+}
+
+// Выполняет операции пакета под одной блокировкой.
+// Если atomic == true, то при первой ошибке все изменения откатываются
+// и возвращается ошибка, иначе каждая операция применяется независимо.
+func (m *EventModelMemory) Batch(ops []structs.BatchOp, atomic bool) ([]structs.BatchResult, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	// Запоминаем состояние для отката. Копия нужна, так как update и delete
+	// меняют элементы внутри массива. Без atomic откатывать нечего
+	var savedEvents []structs.Event
+	savedFreeId, savedAlive := m.freeId, m.alive
+	if atomic {
+		savedEvents = append([]structs.Event(nil), m.events...)
+	}
+
+	res := make([]structs.BatchResult, len(ops))
+	for i, op := range ops {
+		res[i] = m.batchOp(op)
+		if res[i].Err == nil || !atomic {
+			continue
+		}
+
+		// Откатываемся
+		m.events = savedEvents
+		m.freeId = savedFreeId
+		m.alive = savedAlive
+		for j := range res {
+			switch {
+			case j < i:
+				res[j] = structs.BatchResult{Err: errors.New("rolled back")}
+			case j > i:
+				res[j] = structs.BatchResult{Err: errors.New("not executed")}
+			}
+		}
+		return res, fmt.Errorf("operation %d: %w", i, res[i].Err)
+	}
+	for _, r := range res {
+		if r.Err == nil {
+			m.touch()
+			break
+		}
+	}
+	return res, nil
+}
+
+func (m *EventModelMemory) batchOp(op structs.BatchOp) structs.BatchResult {
+	var r structs.BatchResult
+	switch op.Kind {
+	case structs.BatchCreate:
+		r.Event, r.Err = m.create(op.Event.EventNoId)
+	case structs.BatchUpdate:
+		r.Event, r.Err = m.update(op.Event)
+	case structs.BatchDelete:
+		r.Event = op.Event
+		r.Err = m.delete(op.Event.GetId())
+	default:
+		r.Err = errors.New("unknown operation")
+	}
+	return r
+}
+
+// Возвращает снимок всех событий, включая находящиеся в корзине
+func (m *EventModelMemory) Dump() (structs.Snapshot, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return structs.Snapshot{
+		TakenAt: time.Now().UTC(),
+		Events:  append([]structs.Event(nil), m.events...),
+		FreeId:  m.freeId,
+	}, nil
+}
+
+// Заменяет содержимое хранилища снимком s.
+// Снимок, в котором событий вне корзины больше квоты, не загружается
+func (m *EventModelMemory) Load(s structs.Snapshot) error {
+	// Проверяем снимок до того, как что-то поменять
+	seen := make(map[structs.EventID]bool, len(s.Events))
+	var alive int
+	for _, e := range s.Events {
+		id := e.GetId()
+		if id < 0 || id >= s.FreeId {
+			return fmt.Errorf("event id %d out of range [0, %d)", id, s.FreeId)
+		}
+		if seen[id] {
+			return fmt.Errorf("duplicate event id %d", id)
+		}
+		seen[id] = true
+		if !e.IsDeleted() {
+			alive++
+		}
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.quota > 0 && alive > m.quota {
+		return fmt.Errorf("snapshot has %d events, quota %d: %w", alive, m.quota, structs.ErrQuotaExceeded)
+	}
+	m.events = append([]structs.Event(nil), s.Events...)
+	m.freeId = s.FreeId
+	m.alive = alive
+	m.touch()
+	return nil
+}
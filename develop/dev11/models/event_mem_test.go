@@ -2,6 +2,8 @@ package models
 
 import (
 	"dev11/structs"
+	"errors"
+	"sync"
 	"testing"
 	"time"
 )
@@ -124,3 +126,404 @@ func TestEventModelSelectBetween(t *testing.T) {
 	}
 	checkEventsSlice(t, got, []structs.Event{eA, eB, eC})
 }
+
+func TestEventModelBatchAtomic(t *testing.T) {
+	date := time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)
+	m := NewEventModelMemory()
+	eA := eventModelCreateHelper(t, m, structs.MakeEventNoId(1, date))
+
+	// Вторая операция падает, первая должна откатиться
+	ops := []structs.BatchOp{
+		structs.MakeBatchCreate(structs.MakeEventNoId(2, date)),
+		structs.MakeBatchDelete(100),
+	}
+	res, err := m.Batch(ops, true)
+	if err == nil {
+		t.Fatal("err should be not nil")
+	}
+	if len(res) != 2 || res[1].Err == nil {
+		t.Fatalf("unexpected results: %v", res)
+	}
+	got, _ := m.SelectBetweenDates(date, date)
+	checkEventsSlice(t, got, []structs.Event{eA})
+
+	// id не должен быть израсходован
+	eB := eventModelCreateHelper(t, m, structs.MakeEventNoId(2, date))
+	if eB.GetId() != eA.GetId()+1 {
+		t.Fatalf("got id %v want %v", eB.GetId(), eA.GetId()+1)
+	}
+
+	// Успешный пакет
+	ops = []structs.BatchOp{
+		structs.MakeBatchCreate(structs.MakeEventNoId(3, date)),
+		structs.MakeBatchDelete(eA.GetId()),
+	}
+	res, err = m.Batch(ops, true)
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	got, _ = m.SelectBetweenDates(date, date)
+	checkEventsSlice(t, got, []structs.Event{eB, res[0].Event})
+}
+
+func TestEventModelBatchBestEffort(t *testing.T) {
+	date := time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)
+	m := NewEventModelMemory()
+
+	ops := []structs.BatchOp{
+		structs.MakeBatchCreate(structs.MakeEventNoId(1, date)),
+		structs.MakeBatchDelete(100),
+		structs.MakeBatchCreate(structs.MakeEventNoId(2, date)),
+	}
+	res, err := m.Batch(ops, false)
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if res[0].Err != nil || res[1].Err == nil || res[2].Err != nil {
+		t.Fatalf("unexpected results: %v", res)
+	}
+	got, _ := m.SelectBetweenDates(date, date)
+	checkEventsSlice(t, got, []structs.Event{res[0].Event, res[2].Event})
+}
+
+func TestEventModelTrash(t *testing.T) {
+	date := time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)
+	m := NewEventModelMemory()
+	eA := eventModelCreateHelper(t, m, structs.MakeEventNoId(1, date))
+	eB := eventModelCreateHelper(t, m, structs.MakeEventNoId(2, date))
+
+	if err := m.Delete(eA.GetId()); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+
+	// Удаленное событие не видно при выборках
+	if _, err := m.SelectById(eA.GetId()); err == nil {
+		t.Fatal("err should be not nil")
+	}
+	got, _ := m.SelectBetweenDates(date, date)
+	checkEventsSlice(t, got, []structs.Event{eB})
+	if _, err := m.Update(eA); err == nil {
+		t.Fatal("err should be not nil")
+	}
+
+	// Но лежит в корзине
+	trash, _ := m.SelectDeleted(1)
+	if len(trash) != 1 || trash[0].GetId() != eA.GetId() || !trash[0].IsDeleted() {
+		t.Fatalf("unexpected trash: %v", trash)
+	}
+	if trash, _ := m.SelectDeleted(2); len(trash) != 0 {
+		t.Fatalf("unexpected trash: %v", trash)
+	}
+
+	// Восстановление
+	restored, err := m.Restore(eA.GetId())
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if restored != eA {
+		t.Fatalf("got %v; want %v\n", restored, eA)
+	}
+	if _, err := m.Restore(eA.GetId()); err == nil {
+		t.Fatal("err should be not nil")
+	}
+}
+
+func TestEventModelPurge(t *testing.T) {
+	date := time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)
+	m := NewEventModelMemory()
+	eA := eventModelCreateHelper(t, m, structs.MakeEventNoId(1, date))
+	eB := eventModelCreateHelper(t, m, structs.MakeEventNoId(1, date))
+	_ = m.Delete(eA.GetId())
+
+	// Событие удалено только что, старше часа назад его не удаляем
+	if n, _ := m.Purge(time.Now().Add(-time.Hour)); n != 0 {
+		t.Fatalf("got %d purged; want 0", n)
+	}
+	if n, _ := m.Purge(time.Now().Add(time.Hour)); n != 1 {
+		t.Fatalf("got %d purged; want 1", n)
+	}
+	if _, err := m.Restore(eA.GetId()); err == nil {
+		t.Fatal("err should be not nil")
+	}
+	got, _ := m.SelectBetweenDates(date, date)
+	checkEventsSlice(t, got, []structs.Event{eB})
+}
+
+func TestEventModelQuota(t *testing.T) {
+	date := time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)
+	m := NewEventModelMemory()
+	m.SetQuota(2)
+	eA := eventModelCreateHelper(t, m, structs.MakeEventNoId(1, date))
+	eB := eventModelCreateHelper(t, m, structs.MakeEventNoId(1, date))
+
+	if _, err := m.Create(structs.MakeEventNoId(1, date)); !errors.Is(err, structs.ErrQuotaExceeded) {
+		t.Fatalf("got %v; want ErrQuotaExceeded", err)
+	}
+
+	// Пакет упирается в ограничение и откатывается целиком
+	ops := []structs.BatchOp{
+		structs.MakeBatchDelete(eA.GetId()),
+		structs.MakeBatchCreate(structs.MakeEventNoId(2, date)),
+		structs.MakeBatchCreate(structs.MakeEventNoId(2, date)),
+	}
+	if _, err := m.Batch(ops, true); !errors.Is(err, structs.ErrQuotaExceeded) {
+		t.Fatalf("got %v; want ErrQuotaExceeded", err)
+	}
+
+	// События в корзине не учитываются, но восстановить их нельзя
+	_ = m.Delete(eA.GetId())
+	eventModelCreateHelper(t, m, structs.MakeEventNoId(1, date))
+	if _, err := m.Restore(eA.GetId()); !errors.Is(err, structs.ErrQuotaExceeded) {
+		t.Fatalf("got %v; want ErrQuotaExceeded", err)
+	}
+	if _, err := m.Revert(eA); !errors.Is(err, structs.ErrQuotaExceeded) {
+		t.Fatalf("got %v; want ErrQuotaExceeded", err)
+	}
+	if trash, _ := m.SelectDeleted(1); len(trash) != 1 {
+		t.Fatalf("event should stay in trash, got %v", trash)
+	}
+
+	// Без atomic удаление освобождает место для следующего создания
+	ops = []structs.BatchOp{
+		structs.MakeBatchDelete(eB.GetId()),
+		structs.MakeBatchCreate(structs.MakeEventNoId(2, date)),
+		structs.MakeBatchCreate(structs.MakeEventNoId(2, date)),
+	}
+	res, err := m.Batch(ops, false)
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if res[0].Err != nil || res[1].Err != nil || !errors.Is(res[2].Err, structs.ErrQuotaExceeded) {
+		t.Fatalf("unexpected results %v", res)
+	}
+
+	// После загрузки снимка считаются события из него
+	snap, _ := m.Dump()
+	var kept []structs.Event
+	var alive int
+	for _, e := range snap.Events {
+		if !e.IsDeleted() {
+			if alive == 1 {
+				continue
+			}
+			alive++
+		}
+		kept = append(kept, e)
+	}
+	snap.Events = kept
+	if err := m.Load(snap); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	eventModelCreateHelper(t, m, structs.MakeEventNoId(1, date))
+	if _, err := m.Create(structs.MakeEventNoId(1, date)); !errors.Is(err, structs.ErrQuotaExceeded) {
+		t.Fatalf("got %v; want ErrQuotaExceeded", err)
+	}
+}
+
+func TestEventModelRevert(t *testing.T) {
+	date := time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)
+	m := NewEventModelMemory()
+	eA := eventModelCreateHelper(t, m, structs.MakeEventNoId(1, date))
+	_ = m.Delete(eA.GetId())
+
+	// Событие из корзины восстанавливается и заменяется одной операцией
+	upd := eA
+	upd.SetUserId(2)
+	upd.SetDeletedAt(time.Now())
+	got, err := m.Revert(upd)
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	upd.SetDeletedAt(time.Time{})
+	if got != upd {
+		t.Fatalf("got %v; want %v", got, upd)
+	}
+	if got, _ := m.SelectById(eA.GetId()); got != upd {
+		t.Fatalf("got %v; want %v", got, upd)
+	}
+	missing, _ := structs.MakeEventNoId(1, date).MakeEventWithId(100)
+	if _, err := m.Revert(missing); err == nil {
+		t.Fatal("err should be not nil")
+	}
+}
+
+func TestEventModelDumpLoad(t *testing.T) {
+	date := time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)
+	m := NewEventModelMemory()
+	eA := eventModelCreateHelper(t, m, structs.MakeEventNoId(1, date))
+	eB := eventModelCreateHelper(t, m, structs.MakeEventNoId(2, date))
+	_ = m.Delete(eB.GetId())
+
+	snap, err := m.Dump()
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if snap.FreeId != 2 || len(snap.Events) != 2 {
+		t.Fatalf("unexpected snapshot: %v", snap)
+	}
+
+	restored := NewEventModelMemory()
+	if err := restored.Load(snap); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	got, _ := restored.SelectBetweenDates(date, date)
+	checkEventsSlice(t, got, []structs.Event{eA})
+	if trash, _ := restored.SelectDeleted(2); len(trash) != 1 {
+		t.Fatalf("unexpected trash: %v", trash)
+	}
+	// Новые id продолжают нумерацию
+	eC := eventModelCreateHelper(t, restored, structs.MakeEventNoId(1, date))
+	if eC.GetId() != 2 {
+		t.Fatalf("got id %v; want 2", eC.GetId())
+	}
+
+	// Некорректные снимки не загружаются
+	bad := snap
+	bad.FreeId = 1
+	if err := restored.Load(bad); err == nil {
+		t.Fatal("err should be not nil")
+	}
+	bad = snap
+	bad.Events = []structs.Event{eA, eA}
+	if err := restored.Load(bad); err == nil {
+		t.Fatal("err should be not nil")
+	}
+
+	// Снимок больше квоты не загружается, события в корзине не считаются
+	limited := NewEventModelMemory()
+	limited.SetQuota(1)
+	if err := limited.Load(snap); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	big, _ := restored.Dump()
+	if err := limited.Load(big); !errors.Is(err, structs.ErrQuotaExceeded) {
+		t.Fatalf("got %v; want ErrQuotaExceeded", err)
+	}
+	if got, _ := limited.SelectBetweenDates(date, date); len(got) != 1 {
+		t.Fatalf("failed load should keep events, got %v", got)
+	}
+}
+
+func TestEventModelModifiedAt(t *testing.T) {
+	date := time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)
+	m := NewEventModelMemory()
+	if !m.ModifiedAt().IsZero() {
+		t.Fatal("new model should not be modified")
+	}
+
+	eA := eventModelCreateHelper(t, m, structs.MakeEventNoId(1, date))
+	created := m.ModifiedAt()
+	if created.IsZero() {
+		t.Fatal("create should change ModifiedAt")
+	}
+
+	// Неудачные операции и чтение не меняют момент изменения
+	_, _ = m.SelectBetweenDates(date, date)
+	_ = m.Delete(100)
+	_, _ = m.Batch([]structs.BatchOp{structs.MakeBatchDelete(100)}, true)
+	if m.ModifiedAt() != created {
+		t.Fatal("ModifiedAt changed without modification")
+	}
+
+	// Часы могли не сдвинуться, поэтому проверяем только порядок
+	_ = m.Delete(eA.GetId())
+	if m.ModifiedAt().Before(created) {
+		t.Fatal("delete should not move ModifiedAt back")
+	}
+}
+
+// Тест рассчитан на запуск с -race: каждая горутина работает со своими
+// событиями, а чтения и пакеты идут параллельно с ними
+func TestEventModelConcurrent(t *testing.T) {
+	const workers, rounds = 8, 200
+	m := NewEventModelMemory()
+	day := time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(userId structs.UserID) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				e, err := m.Create(structs.MakeEventNoId(userId, day))
+				if err != nil {
+					errs <- err
+					return
+				}
+				e.SetDate(day.AddDate(0, 0, i%30))
+				if _, err := m.Update(e); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := m.SelectBetweenDates(day, day.AddDate(0, 0, 30)); err != nil {
+					errs <- err
+					return
+				}
+				if i%2 == 0 {
+					if err := m.Delete(e.GetId()); err != nil {
+						errs <- err
+						return
+					}
+				}
+				if i%50 == 0 {
+					ops := []structs.BatchOp{structs.MakeBatchCreate(structs.MakeEventNoId(userId, day))}
+					if _, err := m.Batch(ops, true); err != nil {
+						errs <- err
+						return
+					}
+					_, _ = m.Dump()
+					_ = m.ModifiedAt()
+				}
+			}
+		}(structs.UserID(w))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal("err should be nil", err)
+	}
+
+	// Каждая горутина создала rounds событий и rounds/50 пакетом,
+	// половину первых удалила. Id не должны повторяться
+	s, _ := m.Dump()
+	const perWorker = rounds + rounds/50
+	if len(s.Events) != workers*perWorker || int(s.FreeId) != workers*perWorker {
+		t.Fatalf("got %d events, free id %d; want %d", len(s.Events), s.FreeId, workers*perWorker)
+	}
+	seen := map[structs.EventID]bool{}
+	for _, e := range s.Events {
+		if seen[e.GetId()] {
+			t.Fatalf("duplicate id %d", e.GetId())
+		}
+		seen[e.GetId()] = true
+	}
+	for w := 0; w < workers; w++ {
+		trash, _ := m.SelectDeleted(structs.UserID(w))
+		if len(trash) != rounds/2 {
+			t.Fatalf("user %d: got %d deleted; want %d", w, len(trash), rounds/2)
+		}
+	}
+}
+
+// Показывает, во что обходится общая блокировка при смеси чтений и записей
+func BenchmarkEventModelParallel(b *testing.B) {
+	m := NewEventModelMemory()
+	day := time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 1000; i++ {
+		_, _ = m.Create(structs.MakeEventNoId(structs.UserID(i%10), day.AddDate(0, 0, i%365)))
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			// Одна запись на девять чтений
+			if i%10 == 0 {
+				_, _ = m.Create(structs.MakeEventNoId(1, day))
+			} else {
+				_, _ = m.SelectBetweenDates(day, day.AddDate(0, 0, 30))
+			}
+			i++
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"dev11/structs"
+	"sync"
+)
+
+// Хранит историю изменений событий в памяти.
+// Ревизии только добавляются и никогда не изменяются, удалить
+// можно только всю историю сразу
+type RevisionModelMemory struct {
+	lock      sync.RWMutex
+	revisions map[structs.EventID][]structs.Revision
+	freeId    structs.RevisionID
+}
+
+func NewRevisionModelMemory() *RevisionModelMemory {
+	return &RevisionModelMemory{
+		revisions: make(map[structs.EventID][]structs.Revision),
+	}
+}
+
+func (m *RevisionModelMemory) Append(r structs.Revision) (structs.Revision, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	newRev, _ := r.MakeRevisionWithId(m.freeId)
+	m.freeId++
+	ev := newRev.GetEvent()
+	m.revisions[ev.GetId()] = append(m.revisions[ev.GetId()], newRev)
+	return newRev, nil
+}
+
+// Возвращает историю события от старых ревизий к новым
+func (m *RevisionModelMemory) SelectByEventId(id structs.EventID) ([]structs.Revision, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	// Копируем, чтобы вызывающий не мог поменять историю
+	return append([]structs.Revision(nil), m.revisions[id]...), nil
+}
+
+// Удаляет всю историю. Номера новых ревизий не повторяют удаленные
+func (m *RevisionModelMemory) Clear() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.revisions = make(map[structs.EventID][]structs.Revision)
+	return nil
+}
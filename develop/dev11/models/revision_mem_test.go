@@ -0,0 +1,53 @@
+package models
+
+import (
+	"dev11/structs"
+	"testing"
+	"time"
+)
+
+func TestRevisionModelAppendSelect(t *testing.T) {
+	m := NewRevisionModelMemory()
+	ev, _ := structs.MakeEventNoId(1, time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)).MakeEventWithId(3)
+	other, _ := structs.MakeEventNoId(1, time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)).MakeEventWithId(4)
+
+	r1, err := m.Append(structs.MakeRevision(structs.ActionCreate, "a", time.Now(), nil, ev))
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	_, _ = m.Append(structs.MakeRevision(structs.ActionCreate, "a", time.Now(), nil, other))
+	r3, _ := m.Append(structs.MakeRevision(structs.ActionUpdate, "b", time.Now(), &ev, ev))
+	if r1.GetId() == r3.GetId() {
+		t.Fatal("ids should differ")
+	}
+
+	got, err := m.SelectByEventId(ev.GetId())
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if len(got) != 2 || got[0].GetId() != r1.GetId() || got[1].GetId() != r3.GetId() {
+		t.Fatalf("unexpected history: %v", got)
+	}
+
+	if got, _ := m.SelectByEventId(100); len(got) != 0 {
+		t.Fatalf("unexpected history: %v", got)
+	}
+}
+
+func TestRevisionModelClear(t *testing.T) {
+	m := NewRevisionModelMemory()
+	ev, _ := structs.MakeEventNoId(1, time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)).MakeEventWithId(3)
+	r1, _ := m.Append(structs.MakeRevision(structs.ActionCreate, "a", time.Now(), nil, ev))
+
+	if err := m.Clear(); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if got, _ := m.SelectByEventId(ev.GetId()); len(got) != 0 {
+		t.Fatalf("unexpected history: %v", got)
+	}
+	// Номера ревизий не начинаются заново
+	r2, _ := m.Append(structs.MakeRevision(structs.ActionCreate, "a", time.Now(), nil, ev))
+	if r2.GetId() <= r1.GetId() {
+		t.Fatalf("got id %v after %v", r2.GetId(), r1.GetId())
+	}
+}
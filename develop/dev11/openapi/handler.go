@@ -0,0 +1,158 @@
+package openapi
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+)
+
+// Отдает документ, который возвращает doc, в формате JSON
+func JSONHandler(doc func() Document) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(doc())
+	}
+}
+
+var docsTemplate = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+td, th { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+.method { font-weight: bold; text-transform: uppercase; }
+</style>
+</head>
+<body>
+<h1>{{.Title}} {{.Version}}</h1>
+<p>Машиночитаемое описание: <a href="{{.SpecURL}}">{{.SpecURL}}</a></p>
+{{range .Ops}}
+<h2><span class="method">{{.Method}}</span> {{.Pattern}}</h2>
+<p>{{.Op.Summary}}</p>
+{{with .Params}}
+<table>
+<tr><th>Параметр</th><th>Где</th><th>Тип</th><th>Обязательный</th><th>Описание</th></tr>
+{{range .}}<tr><td>{{.Name}}</td><td>{{.In}}</td><td>{{.Type}}</td><td>{{.Required}}</td><td>{{.Description}}</td></tr>
+{{end}}
+</table>
+{{end}}
+<table>
+<tr><th>Код</th><th>Описание</th></tr>
+{{range $code, $resp := .Op.Responses}}<tr><td>{{$code}}</td><td>{{$resp.Description}}</td></tr>
+{{end}}
+</table>
+{{end}}
+</body>
+</html>
+`))
+
+type docsParam struct {
+	Name        string
+	In          string
+	Type        string
+	Required    bool
+	Description string
+}
+
+type docsOp struct {
+	Pattern string
+	Method  string
+	Op      Operation
+	Params  []docsParam
+}
+
+// Собирает параметры операции в одну таблицу, включая поля тела запроса
+func (d *docsOp) collectParams(doc Document) {
+	for _, p := range d.Op.Parameters {
+		d.Params = append(d.Params, docsParam{p.Name, p.In, p.Schema.Type, p.Required, p.Description})
+	}
+	if d.Op.RequestBody == nil {
+		return
+	}
+	for ctype, mt := range d.Op.RequestBody.Content {
+		s := mt.Schema
+		if name, ok := refName(s.Ref); ok {
+			s = doc.Components.Schemas[name]
+		}
+		if len(s.Properties) == 0 {
+			d.Params = append(d.Params, docsParam{"body", ctype, s.Type, true, s.Description})
+			continue
+		}
+		required := make(map[string]bool)
+		for _, name := range s.Required {
+			required[name] = true
+		}
+		for _, name := range sortedKeys(s.Properties) {
+			p := s.Properties[name]
+			d.Params = append(d.Params, docsParam{name, ctype, p.Type, required[name], p.Description})
+		}
+	}
+}
+
+func refName(ref string) (string, bool) {
+	const prefix = "#/components/schemas/"
+	if len(ref) > len(prefix) && ref[:len(prefix)] == prefix {
+		return ref[len(prefix):], true
+	}
+	return "", false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Отдает минимальную html страницу с описанием документа doc.
+// specURL - адрес, по которому доступен JSON документ
+func DocsHandler(specURL string, doc func() Document) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d := doc()
+		var ops []docsOp
+		for _, pattern := range sortedKeys(d.Paths) {
+			for _, method := range sortedKeys(d.Paths[pattern]) {
+				op := docsOp{Pattern: pattern, Method: method, Op: d.Paths[pattern][method]}
+				op.collectParams(d)
+				ops = append(ops, op)
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = docsTemplate.Execute(w, struct {
+			Title   string
+			Version string
+			SpecURL string
+			Ops     []docsOp
+		}{d.Info.Title, d.Info.Version, specURL, ops})
+	}
+}
+
+// Описание самих JSONHandler и DocsHandler
+func SelfPaths(specURL, docsURL string) Paths {
+	p := Paths{}
+	p.Add(specURL, "GET", Operation{
+		Summary: "Описание API в формате OpenAPI 3",
+		Responses: map[string]Response{
+			"200": JSONResponse("Документ OpenAPI", Schema{Type: "object"}),
+		},
+	})
+	p.Add(docsURL, "GET", Operation{
+		Summary: "Страница с описанием API",
+		Responses: map[string]Response{
+			"200": {
+				Description: "html страница",
+				Content:     map[string]MediaType{"text/html": {Schema: Schema{Type: "string"}}},
+			},
+		},
+	})
+	return p
+}
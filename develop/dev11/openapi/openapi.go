@@ -0,0 +1,122 @@
+// Пакет openapi описывает HTTP API в формате OpenAPI 3
+// и отдает описание клиентам.
+package openapi
+
+import "strings"
+
+// Документ OpenAPI 3. Описаны только используемые в проекте поля
+type Document struct {
+	OpenAPI    string     `json:"openapi"`
+	Info       Info       `json:"info"`
+	Paths      Paths      `json:"paths"`
+	Components Components `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Пути API: pattern -> метод в нижнем регистре -> операция
+type Paths map[string]map[string]Operation
+
+// Добавляет операцию для pattern и method
+func (p Paths) Add(pattern, method string, op Operation) {
+	if p[pattern] == nil {
+		p[pattern] = make(map[string]Operation)
+	}
+	p[pattern][strings.ToLower(method)] = op
+}
+
+// Возвращает операцию для pattern и method
+func (p Paths) Get(pattern, method string) (Operation, bool) {
+	op, ok := p[pattern][strings.ToLower(method)]
+	return op, ok
+}
+
+// Добавляет все операции из other
+func (p Paths) Merge(other Paths) {
+	for pattern, ops := range other {
+		for method, op := range ops {
+			p.Add(pattern, method, op)
+		}
+	}
+}
+
+type Components struct {
+	Schemas map[string]Schema `json:"schemas,omitempty"`
+}
+
+type Operation struct {
+	Summary     string              `json:"summary"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"` // query или path
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+	Schema      Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref         string            `json:"$ref,omitempty"`
+	Type        string            `json:"type,omitempty"`
+	Format      string            `json:"format,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Enum        []string          `json:"enum,omitempty"`
+	Minimum     *int              `json:"minimum,omitempty"`
+	Maximum     *int              `json:"maximum,omitempty"`
+	MaxLength   *int              `json:"maxLength,omitempty"`
+	Pattern     string            `json:"pattern,omitempty"`
+	Items       *Schema           `json:"items,omitempty"`
+	Properties  map[string]Schema `json:"properties,omitempty"`
+	Required    []string          `json:"required,omitempty"`
+	OneOf       []Schema          `json:"oneOf,omitempty"`
+}
+
+// Ссылка на схему из components
+func Ref(name string) Schema {
+	return Schema{Ref: "#/components/schemas/" + name}
+}
+
+// Тело запроса в формате application/x-www-form-urlencoded
+func FormBody(s Schema) *RequestBody {
+	return &RequestBody{
+		Required: true,
+		Content:  map[string]MediaType{"application/x-www-form-urlencoded": {Schema: s}},
+	}
+}
+
+// Тело запроса в формате application/json
+func JSONBody(s Schema) *RequestBody {
+	return &RequestBody{
+		Required: true,
+		Content:  map[string]MediaType{"application/json": {Schema: s}},
+	}
+}
+
+// Ответ в формате application/json
+func JSONResponse(description string, s Schema) Response {
+	return Response{
+		Description: description,
+		Content:     map[string]MediaType{"application/json": {Schema: s}},
+	}
+}
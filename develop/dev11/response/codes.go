@@ -0,0 +1,74 @@
+package response
+
+import (
+	"dev11/structs"
+	"errors"
+	"net/http"
+)
+
+// Машиночитаемые коды ошибок. HTTP+JSON и RPC выбирают код
+// и HTTP статус ошибки по одним таблицам, поэтому отвечают одинаково
+const (
+	CodeInvalidArgument  = "invalid_argument"
+	CodeUnauthenticated  = "unauthenticated"
+	CodeNotFound         = "not_found"
+	CodeUnimplemented    = "unimplemented"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodePermissionDenied = "permission_denied"
+	CodeQuotaExceeded    = "quota_exceeded"
+	CodeTooLarge         = "too_large"
+	CodeRateLimited      = "rate_limited"
+	CodeInternal         = "internal"
+)
+
+// HTTP статус для каждого кода
+var codeStatus = map[string]int{
+	CodeInvalidArgument:  http.StatusBadRequest,
+	CodeUnauthenticated:  http.StatusUnauthorized,
+	CodeNotFound:         http.StatusNotFound,
+	CodeUnimplemented:    http.StatusNotFound,
+	CodeMethodNotAllowed: http.StatusMethodNotAllowed,
+	CodePermissionDenied: http.StatusForbidden,
+	CodeQuotaExceeded:    http.StatusForbidden,
+	CodeTooLarge:         http.StatusRequestEntityTooLarge,
+	CodeRateLimited:      http.StatusTooManyRequests,
+	CodeInternal:         http.StatusInternalServerError,
+}
+
+// Ошибки бизнес-логики и их коды, остальные ошибки - internal
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{structs.ErrQuotaExceeded, CodeQuotaExceeded},
+	{structs.ErrTooManyTenants, CodePermissionDenied},
+	{structs.ErrUnknownRegion, CodeInvalidArgument},
+}
+
+// HTTP статус для кода ошибки, неизвестный код - 500
+func Status(code string) int {
+	if status, ok := codeStatus[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Код ошибки бизнес-логики
+func Code(err error) string {
+	for _, ec := range errorCodes {
+		if errors.Is(err, ec.err) {
+			return ec.code
+		}
+	}
+	return CodeInternal
+}
+
+// Код и текст ошибки чтения тела запроса: слишком большое тело - too_large,
+// остальные ошибки - invalid_argument
+func BodyError(err error) (code, msg string) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return CodeTooLarge, "request body too large"
+	}
+	return CodeInvalidArgument, err.Error()
+}
@@ -0,0 +1,51 @@
+// Пакет response формирует json ответы сервера. Успешный ответ
+// имеет вид {"result": ...}, ошибка - {"error": "...", "code": "..."}.
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Структура для json сообщения об ошибке. Error - текст для человека,
+// Code - машиночитаемый код из codes.go, по нему клиенты различают ошибки
+type JsonError struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// Формирует и отсылает json документ v в w
+func JSON(w http.ResponseWriter, v interface{}, statusCode int) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		Error(w, CodeInternal, "can't encode response")
+		return
+	}
+	Bytes(w, append(b, '\n'), statusCode)
+}
+
+// Отсылает уже закодированный json документ b
+func Bytes(w http.ResponseWriter, b []byte, statusCode int) {
+	// Заголовки нужно задать до WriteHeader, иначе они будут проигнорированы
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(b)
+}
+
+// Отсылает ошибку msg с кодом code, HTTP статус определяется по коду
+func Error(w http.ResponseWriter, code, msg string) {
+	JSON(w, JsonError{Error: msg, Code: code}, Status(code))
+}
+
+// Отвечает 405 с заголовком Allow, перечисляющим допустимые методы
+func MethodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	Error(w, CodeMethodNotAllowed, "method not allowed")
+}
+
+// Отвечает 404 для неизвестного маршрута
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Error(w, CodeNotFound, "not found")
+}
@@ -0,0 +1,67 @@
+package response
+
+import (
+	"dev11/structs"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	cases := []struct {
+		name    string
+		write   func(w http.ResponseWriter)
+		code    int
+		errCode string
+		errMsg  string
+		allow   string
+	}{
+		{"error", func(w http.ResponseWriter) { Error(w, CodeInvalidArgument, `bad "quoted" value`) }, http.StatusBadRequest, CodeInvalidArgument, `bad "quoted" value`, ""},
+		{"method", func(w http.ResponseWriter) { MethodNotAllowed(w, "GET", "HEAD") }, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "method not allowed", "GET, HEAD"},
+		{"not found", func(w http.ResponseWriter) { NotFound(w, httptest.NewRequest("GET", "/", nil)) }, http.StatusNotFound, CodeNotFound, "not found", ""},
+		{"bad value", func(w http.ResponseWriter) { JSON(w, func() {}, http.StatusOK) }, http.StatusInternalServerError, CodeInternal, "can't encode response", ""},
+	}
+	for _, tc := range cases {
+		rr := httptest.NewRecorder()
+		tc.write(rr)
+		var got JsonError
+		if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: invalid json %q: %s", tc.name, rr.Body, err)
+		}
+		if rr.Code != tc.code || got.Error != tc.errMsg || got.Code != tc.errCode ||
+			rr.Header().Get("Content-Type") != "application/json" || rr.Header().Get("Allow") != tc.allow {
+			t.Errorf("%s: got %d %v %q", tc.name, rr.Code, rr.Header(), rr.Body)
+		}
+	}
+}
+
+func TestCode(t *testing.T) {
+	cases := []struct {
+		err    error
+		code   string
+		status int
+	}{
+		{structs.ErrQuotaExceeded, CodeQuotaExceeded, http.StatusForbidden},
+		{fmt.Errorf("operation 1: %w", structs.ErrQuotaExceeded), CodeQuotaExceeded, http.StatusForbidden},
+		{structs.ErrTooManyTenants, CodePermissionDenied, http.StatusForbidden},
+		{structs.ErrUnknownRegion, CodeInvalidArgument, http.StatusBadRequest},
+		{errors.New("no such element id"), CodeInternal, http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		code := Code(tc.err)
+		if code != tc.code || Status(code) != tc.status {
+			t.Errorf("%v: got %s %d; want %s %d", tc.err, code, Status(code), tc.code, tc.status)
+		}
+	}
+
+	code, msg := BodyError(&http.MaxBytesError{Limit: 10})
+	if code != CodeTooLarge || Status(code) != http.StatusRequestEntityTooLarge || msg != "request body too large" {
+		t.Errorf("got %s %q; want too_large", code, msg)
+	}
+	if code, _ := BodyError(errors.New("unexpected EOF")); code != CodeInvalidArgument {
+		t.Errorf("got %s; want invalid_argument", code)
+	}
+}
@@ -0,0 +1,130 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Клиент EventService
+type Client struct {
+	hc      *http.Client
+	baseURL string
+	actor   string
+	tenant  string
+}
+
+// Создает клиента для сервера по адресу baseURL (например, http://127.0.0.1:8081)
+func NewClient(hc *http.Client, baseURL string) *Client {
+	return &Client{
+		hc:      hc,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// Возвращает копию клиента, вызовы которого выполняются от имени actor.
+// Сервер учитывает X-Actor, только если доверяет этому заголовку
+func (c *Client) WithActor(actor string) *Client {
+	res := *c
+	res.actor = actor
+	return &res
+}
+
+// Возвращает копию клиента, вызовы которого выполняются для арендатора tenant
+func (c *Client) WithTenant(tenant string) *Client {
+	res := *c
+	res.tenant = tenant
+	return &res
+}
+
+func (c *Client) call(ctx context.Context, name string, in, out Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+Path(name), bytes.NewReader(in.Marshal()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", protoContentType)
+	if c.actor != "" {
+		req.Header.Set("X-Actor", c.actor)
+	}
+	if c.tenant != "" {
+		req.Header.Set("X-Tenant", c.tenant)
+	}
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e Error
+		if err := json.Unmarshal(b, &e); err != nil || e.Code == "" {
+			return fmt.Errorf("rpc %s: unexpected status %d", name, resp.StatusCode)
+		}
+		return &e
+	}
+	return out.Unmarshal(b)
+}
+
+func (c *Client) CreateEvent(ctx context.Context, req *CreateEventRequest) (*EventResponse, error) {
+	var out EventResponse
+	return &out, c.call(ctx, "CreateEvent", req, &out)
+}
+
+func (c *Client) UpdateEvent(ctx context.Context, req *UpdateEventRequest) (*EventResponse, error) {
+	var out EventResponse
+	return &out, c.call(ctx, "UpdateEvent", req, &out)
+}
+
+func (c *Client) DeleteEvent(ctx context.Context, req *DeleteEventRequest) (*DeleteEventResponse, error) {
+	var out DeleteEventResponse
+	return &out, c.call(ctx, "DeleteEvent", req, &out)
+}
+
+func (c *Client) EventsForDay(ctx context.Context, req *EventsForRequest) (*EventsResponse, error) {
+	var out EventsResponse
+	return &out, c.call(ctx, "EventsForDay", req, &out)
+}
+
+func (c *Client) EventsForWeek(ctx context.Context, req *EventsForRequest) (*EventsResponse, error) {
+	var out EventsResponse
+	return &out, c.call(ctx, "EventsForWeek", req, &out)
+}
+
+func (c *Client) EventsForMonth(ctx context.Context, req *EventsForRequest) (*EventsResponse, error) {
+	var out EventsResponse
+	return &out, c.call(ctx, "EventsForMonth", req, &out)
+}
+
+func (c *Client) Batch(ctx context.Context, req *BatchRequest) (*BatchResponse, error) {
+	var out BatchResponse
+	return &out, c.call(ctx, "Batch", req, &out)
+}
+
+func (c *Client) Trash(ctx context.Context, req *TrashRequest) (*EventsResponse, error) {
+	var out EventsResponse
+	return &out, c.call(ctx, "Trash", req, &out)
+}
+
+func (c *Client) RestoreEvent(ctx context.Context, req *RestoreEventRequest) (*EventResponse, error) {
+	var out EventResponse
+	return &out, c.call(ctx, "RestoreEvent", req, &out)
+}
+
+func (c *Client) History(ctx context.Context, req *HistoryRequest) (*HistoryResponse, error) {
+	var out HistoryResponse
+	return &out, c.call(ctx, "History", req, &out)
+}
+
+func (c *Client) Revert(ctx context.Context, req *RevertRequest) (*EventResponse, error) {
+	var out EventResponse
+	return &out, c.call(ctx, "Revert", req, &out)
+}
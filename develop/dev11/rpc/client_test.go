@@ -0,0 +1,25 @@
+package rpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+// Создает клиента, который вызывает handler напрямую, без сети
+func newInProcessClient(handler http.Handler) *Client {
+	return NewClient(&http.Client{Transport: inProcessTransport{handler}}, "http://in-process")
+}
+
+// Выполняет запросы, вызывая handler в том же процессе
+type inProcessTransport struct {
+	handler http.Handler
+}
+
+func (t inProcessTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	rr := httptest.NewRecorder()
+	t.handler.ServeHTTP(rr, req)
+	return rr.Result(), nil
+}
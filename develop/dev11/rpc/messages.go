@@ -0,0 +1,576 @@
+package rpc
+
+// Сообщения из calendar.proto
+
+// Сообщение, которое умеет сериализоваться в формат protobuf
+type Message interface {
+	Marshal() []byte
+	Unmarshal(b []byte) error
+}
+
+type Event struct {
+	Id          int64
+	UserId      int64
+	Date        string
+	DeletedAt   string
+	Title       string
+	Description string
+	Overlay     *Overlay // Только у наложенных событий
+}
+
+func (m *Event) Marshal() []byte {
+	var b []byte
+	b = appendInt64(b, 1, m.Id)
+	b = appendInt64(b, 2, m.UserId)
+	b = appendString(b, 3, m.Date)
+	b = appendString(b, 4, m.DeletedAt)
+	b = appendString(b, 5, m.Title)
+	b = appendString(b, 6, m.Description)
+	if m.Overlay != nil {
+		b = appendBytes(b, 7, m.Overlay.Marshal())
+	}
+	return b
+}
+
+func (m *Event) Unmarshal(b []byte) error {
+	*m = Event{}
+	return readFields(b, func(f wireField) error {
+		switch f.num {
+		case 1:
+			return f.int64(&m.Id)
+		case 2:
+			return f.int64(&m.UserId)
+		case 3:
+			return f.string(&m.Date)
+		case 4:
+			return f.string(&m.DeletedAt)
+		case 5:
+			return f.string(&m.Title)
+		case 6:
+			return f.string(&m.Description)
+		case 7:
+			m.Overlay = &Overlay{}
+			return f.message(m.Overlay)
+		}
+		return nil
+	})
+}
+
+// Признак события, которое показывается поверх событий календаря
+// и не может быть изменено. У таких событий id и user_id равны -1
+type Overlay struct {
+	Kind    string // Пока только holiday
+	Region  string
+	Working bool // Перенесенный рабочий день
+}
+
+func (m *Overlay) Marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.Kind)
+	b = appendString(b, 2, m.Region)
+	b = appendBool(b, 3, m.Working)
+	return b
+}
+
+func (m *Overlay) Unmarshal(b []byte) error {
+	*m = Overlay{}
+	return readFields(b, func(f wireField) error {
+		switch f.num {
+		case 1:
+			return f.string(&m.Kind)
+		case 2:
+			return f.string(&m.Region)
+		case 3:
+			return f.bool(&m.Working)
+		}
+		return nil
+	})
+}
+
+type CreateEventRequest struct {
+	UserId      int64
+	Date        string
+	Title       string
+	Description string
+}
+
+func (m *CreateEventRequest) Marshal() []byte {
+	var b []byte
+	b = appendInt64(b, 1, m.UserId)
+	b = appendString(b, 2, m.Date)
+	b = appendString(b, 3, m.Title)
+	b = appendString(b, 4, m.Description)
+	return b
+}
+
+func (m *CreateEventRequest) Unmarshal(b []byte) error {
+	*m = CreateEventRequest{}
+	return readFields(b, func(f wireField) error {
+		switch f.num {
+		case 1:
+			return f.int64(&m.UserId)
+		case 2:
+			return f.string(&m.Date)
+		case 3:
+			return f.string(&m.Title)
+		case 4:
+			return f.string(&m.Description)
+		}
+		return nil
+	})
+}
+
+type UpdateEventRequest struct {
+	Id          int64
+	UserId      int64
+	Date        string
+	Title       string
+	Description string
+}
+
+func (m *UpdateEventRequest) Marshal() []byte {
+	var b []byte
+	b = appendInt64(b, 1, m.Id)
+	b = appendInt64(b, 2, m.UserId)
+	b = appendString(b, 3, m.Date)
+	b = appendString(b, 4, m.Title)
+	b = appendString(b, 5, m.Description)
+	return b
+}
+
+func (m *UpdateEventRequest) Unmarshal(b []byte) error {
+	*m = UpdateEventRequest{}
+	return readFields(b, func(f wireField) error {
+		switch f.num {
+		case 1:
+			return f.int64(&m.Id)
+		case 2:
+			return f.int64(&m.UserId)
+		case 3:
+			return f.string(&m.Date)
+		case 4:
+			return f.string(&m.Title)
+		case 5:
+			return f.string(&m.Description)
+		}
+		return nil
+	})
+}
+
+type DeleteEventRequest struct {
+	Id int64
+}
+
+func (m *DeleteEventRequest) Marshal() []byte {
+	return appendInt64(nil, 1, m.Id)
+}
+
+func (m *DeleteEventRequest) Unmarshal(b []byte) error {
+	*m = DeleteEventRequest{}
+	return readFields(b, func(f wireField) error {
+		if f.num == 1 {
+			return f.int64(&m.Id)
+		}
+		return nil
+	})
+}
+
+type DeleteEventResponse struct{}
+
+func (m *DeleteEventResponse) Marshal() []byte {
+	return nil
+}
+
+func (m *DeleteEventResponse) Unmarshal(b []byte) error {
+	return readFields(b, func(f wireField) error { return nil })
+}
+
+type EventResponse struct {
+	Event *Event
+}
+
+func (m *EventResponse) Marshal() []byte {
+	if m.Event == nil {
+		return nil
+	}
+	return appendBytes(nil, 1, m.Event.Marshal())
+}
+
+func (m *EventResponse) Unmarshal(b []byte) error {
+	*m = EventResponse{}
+	return readFields(b, func(f wireField) error {
+		if f.num == 1 {
+			m.Event = &Event{}
+			return f.message(m.Event)
+		}
+		return nil
+	})
+}
+
+type EventsForRequest struct {
+	ToDate string
+	Region string // Пустая строка - регион праздников по умолчанию
+}
+
+func (m *EventsForRequest) Marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.ToDate)
+	b = appendString(b, 2, m.Region)
+	return b
+}
+
+func (m *EventsForRequest) Unmarshal(b []byte) error {
+	*m = EventsForRequest{}
+	return readFields(b, func(f wireField) error {
+		switch f.num {
+		case 1:
+			return f.string(&m.ToDate)
+		case 2:
+			return f.string(&m.Region)
+		}
+		return nil
+	})
+}
+
+type EventsResponse struct {
+	Events []*Event
+}
+
+func (m *EventsResponse) Marshal() []byte {
+	var b []byte
+	for _, e := range m.Events {
+		b = appendBytes(b, 1, e.Marshal())
+	}
+	return b
+}
+
+func (m *EventsResponse) Unmarshal(b []byte) error {
+	*m = EventsResponse{}
+	return readFields(b, func(f wireField) error {
+		if f.num == 1 {
+			e := &Event{}
+			if err := f.message(e); err != nil {
+				return err
+			}
+			m.Events = append(m.Events, e)
+		}
+		return nil
+	})
+}
+
+// Одна операция пакета, поля соответствуют CreateEventRequest,
+// UpdateEventRequest и DeleteEventRequest
+type BatchOp struct {
+	Op          string // create, update или delete
+	Id          int64
+	UserId      int64
+	Date        string
+	Title       string
+	Description string
+}
+
+func (m *BatchOp) Marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.Op)
+	b = appendInt64(b, 2, m.Id)
+	b = appendInt64(b, 3, m.UserId)
+	b = appendString(b, 4, m.Date)
+	b = appendString(b, 5, m.Title)
+	b = appendString(b, 6, m.Description)
+	return b
+}
+
+func (m *BatchOp) Unmarshal(b []byte) error {
+	*m = BatchOp{}
+	return readFields(b, func(f wireField) error {
+		switch f.num {
+		case 1:
+			return f.string(&m.Op)
+		case 2:
+			return f.int64(&m.Id)
+		case 3:
+			return f.int64(&m.UserId)
+		case 4:
+			return f.string(&m.Date)
+		case 5:
+			return f.string(&m.Title)
+		case 6:
+			return f.string(&m.Description)
+		}
+		return nil
+	})
+}
+
+type BatchRequest struct {
+	Ops        []*BatchOp
+	BestEffort bool // По умолчанию пакет выполняется атомарно
+}
+
+func (m *BatchRequest) Marshal() []byte {
+	var b []byte
+	for _, op := range m.Ops {
+		b = appendBytes(b, 1, op.Marshal())
+	}
+	b = appendBool(b, 2, m.BestEffort)
+	return b
+}
+
+func (m *BatchRequest) Unmarshal(b []byte) error {
+	*m = BatchRequest{}
+	return readFields(b, func(f wireField) error {
+		switch f.num {
+		case 1:
+			op := &BatchOp{}
+			if err := f.message(op); err != nil {
+				return err
+			}
+			m.Ops = append(m.Ops, op)
+		case 2:
+			return f.bool(&m.BestEffort)
+		}
+		return nil
+	})
+}
+
+// Результат одной операции пакета: либо Event (или Deleted), либо Error
+type BatchItem struct {
+	Event   *Event
+	Deleted bool
+	Error   string
+}
+
+func (m *BatchItem) Marshal() []byte {
+	var b []byte
+	if m.Event != nil {
+		b = appendBytes(b, 1, m.Event.Marshal())
+	}
+	b = appendBool(b, 2, m.Deleted)
+	b = appendString(b, 3, m.Error)
+	return b
+}
+
+func (m *BatchItem) Unmarshal(b []byte) error {
+	*m = BatchItem{}
+	return readFields(b, func(f wireField) error {
+		switch f.num {
+		case 1:
+			m.Event = &Event{}
+			return f.message(m.Event)
+		case 2:
+			return f.bool(&m.Deleted)
+		case 3:
+			return f.string(&m.Error)
+		}
+		return nil
+	})
+}
+
+type BatchResponse struct {
+	Results []*BatchItem
+}
+
+func (m *BatchResponse) Marshal() []byte {
+	var b []byte
+	for _, r := range m.Results {
+		b = appendBytes(b, 1, r.Marshal())
+	}
+	return b
+}
+
+func (m *BatchResponse) Unmarshal(b []byte) error {
+	*m = BatchResponse{}
+	return readFields(b, func(f wireField) error {
+		if f.num == 1 {
+			r := &BatchItem{}
+			if err := f.message(r); err != nil {
+				return err
+			}
+			m.Results = append(m.Results, r)
+		}
+		return nil
+	})
+}
+
+type TrashRequest struct {
+	UserId int64
+}
+
+func (m *TrashRequest) Marshal() []byte {
+	return appendInt64(nil, 1, m.UserId)
+}
+
+func (m *TrashRequest) Unmarshal(b []byte) error {
+	*m = TrashRequest{}
+	return readFields(b, func(f wireField) error {
+		if f.num == 1 {
+			return f.int64(&m.UserId)
+		}
+		return nil
+	})
+}
+
+type RestoreEventRequest struct {
+	Id int64
+}
+
+func (m *RestoreEventRequest) Marshal() []byte {
+	return appendInt64(nil, 1, m.Id)
+}
+
+func (m *RestoreEventRequest) Unmarshal(b []byte) error {
+	*m = RestoreEventRequest{}
+	return readFields(b, func(f wireField) error {
+		if f.num == 1 {
+			return f.int64(&m.Id)
+		}
+		return nil
+	})
+}
+
+type HistoryRequest struct {
+	Id int64
+}
+
+func (m *HistoryRequest) Marshal() []byte {
+	return appendInt64(nil, 1, m.Id)
+}
+
+func (m *HistoryRequest) Unmarshal(b []byte) error {
+	*m = HistoryRequest{}
+	return readFields(b, func(f wireField) error {
+		if f.num == 1 {
+			return f.int64(&m.Id)
+		}
+		return nil
+	})
+}
+
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+func (m *FieldChange) Marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.Field)
+	b = appendString(b, 2, m.Old)
+	b = appendString(b, 3, m.New)
+	return b
+}
+
+func (m *FieldChange) Unmarshal(b []byte) error {
+	*m = FieldChange{}
+	return readFields(b, func(f wireField) error {
+		switch f.num {
+		case 1:
+			return f.string(&m.Field)
+		case 2:
+			return f.string(&m.Old)
+		case 3:
+			return f.string(&m.New)
+		}
+		return nil
+	})
+}
+
+type Revision struct {
+	Id     int64
+	Actor  string
+	At     string // RFC3339Nano
+	Action string
+	Event  *Event
+	Diff   []*FieldChange
+}
+
+func (m *Revision) Marshal() []byte {
+	var b []byte
+	b = appendInt64(b, 1, m.Id)
+	b = appendString(b, 2, m.Actor)
+	b = appendString(b, 3, m.At)
+	b = appendString(b, 4, m.Action)
+	if m.Event != nil {
+		b = appendBytes(b, 5, m.Event.Marshal())
+	}
+	for _, c := range m.Diff {
+		b = appendBytes(b, 6, c.Marshal())
+	}
+	return b
+}
+
+func (m *Revision) Unmarshal(b []byte) error {
+	*m = Revision{}
+	return readFields(b, func(f wireField) error {
+		switch f.num {
+		case 1:
+			return f.int64(&m.Id)
+		case 2:
+			return f.string(&m.Actor)
+		case 3:
+			return f.string(&m.At)
+		case 4:
+			return f.string(&m.Action)
+		case 5:
+			m.Event = &Event{}
+			return f.message(m.Event)
+		case 6:
+			c := &FieldChange{}
+			if err := f.message(c); err != nil {
+				return err
+			}
+			m.Diff = append(m.Diff, c)
+		}
+		return nil
+	})
+}
+
+type HistoryResponse struct {
+	Revisions []*Revision
+}
+
+func (m *HistoryResponse) Marshal() []byte {
+	var b []byte
+	for _, r := range m.Revisions {
+		b = appendBytes(b, 1, r.Marshal())
+	}
+	return b
+}
+
+func (m *HistoryResponse) Unmarshal(b []byte) error {
+	*m = HistoryResponse{}
+	return readFields(b, func(f wireField) error {
+		if f.num == 1 {
+			r := &Revision{}
+			if err := f.message(r); err != nil {
+				return err
+			}
+			m.Revisions = append(m.Revisions, r)
+		}
+		return nil
+	})
+}
+
+type RevertRequest struct {
+	Id       int64
+	Revision int64
+}
+
+func (m *RevertRequest) Marshal() []byte {
+	var b []byte
+	b = appendInt64(b, 1, m.Id)
+	b = appendInt64(b, 2, m.Revision)
+	return b
+}
+
+func (m *RevertRequest) Unmarshal(b []byte) error {
+	*m = RevertRequest{}
+	return readFields(b, func(f wireField) error {
+		switch f.num {
+		case 1:
+			return f.int64(&m.Id)
+		case 2:
+			return f.int64(&m.Revision)
+		}
+		return nil
+	})
+}
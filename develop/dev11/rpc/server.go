@@ -0,0 +1,410 @@
+package rpc
+
+import (
+	"dev11/endpoints"
+	"dev11/logic"
+	"dev11/response"
+	"dev11/structs"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Имя сервиса из calendar.proto, используется как префикс пути
+const ServiceName = "calendar.v1.EventService"
+
+const protoContentType = "application/proto"
+
+// Коды ошибок, общие с HTTP+JSON API
+const (
+	CodeInvalidArgument  = response.CodeInvalidArgument
+	CodeNotFound         = response.CodeNotFound
+	CodeInternal         = response.CodeInternal
+	CodeUnimplemented    = response.CodeUnimplemented
+	CodePermissionDenied = response.CodePermissionDenied
+	CodeQuotaExceeded    = response.CodeQuotaExceeded
+	CodeTooLarge         = response.CodeTooLarge
+)
+
+// Ошибка RPC вызова
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// Ошибка входных данных, текст совпадает с ответом HTTP+JSON API
+var errCantParse = &Error{Code: CodeInvalidArgument, Message: "can't parse"}
+
+// Ошибка бизнес-логики, код выбирается по той же таблице, что и в HTTP+JSON API
+func businessError(err error) *Error {
+	return &Error{Code: response.Code(err), Message: err.Error()}
+}
+
+// Обработчик одного метода сервиса
+type method func(api *logic.EventAPI, in []byte) (Message, *Error)
+
+// Реализация EventService поверх logic.EventAPI.
+// Параметры валидируются функциями из endpoints, поэтому оба транспорта
+// принимают и отклоняют одни и те же данные
+type Server struct {
+	tenants *logic.Tenants
+	methods map[string]method
+}
+
+func NewServer(api *logic.EventAPI) *Server {
+	return NewTenantServer(logic.SingleTenant(api))
+}
+
+// Сервер, который выбирает api по арендатору из контекста запроса
+func NewTenantServer(tenants *logic.Tenants) *Server {
+	s := &Server{tenants: tenants}
+	s.methods = map[string]method{
+		"CreateEvent":    createEvent,
+		"UpdateEvent":    updateEvent,
+		"DeleteEvent":    deleteEvent,
+		"EventsForDay":   eventsFor((*logic.EventAPI).ForDay, logic.DayPeriod),
+		"EventsForWeek":  eventsFor((*logic.EventAPI).ForWeek, logic.WeekPeriod),
+		"EventsForMonth": eventsFor((*logic.EventAPI).ForMonth, logic.MonthPeriod),
+		"Batch":          batch,
+		"Trash":          trash,
+		"RestoreEvent":   restoreEvent,
+		"History":        history,
+		"Revert":         revert,
+	}
+	return s
+}
+
+// Путь, по которому доступен метод name
+func Path(name string) string {
+	return "/" + ServiceName + "/" + name
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutPrefix(r.URL.Path, "/"+ServiceName+"/")
+	m, found := s.methods[name]
+	if !ok || !found {
+		writeError(w, &Error{Code: CodeUnimplemented, Message: "unknown method " + r.URL.Path})
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, &Error{Code: CodeUnimplemented, Message: "method not allowed"})
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != protoContentType {
+		writeError(w, &Error{Code: CodeInvalidArgument, Message: "unsupported content type " + ct})
+		return
+	}
+
+	in, err := io.ReadAll(r.Body)
+	if err != nil {
+		code, msg := response.BodyError(err)
+		writeError(w, &Error{Code: code, Message: msg})
+		return
+	}
+	// Хранилище нового арендатора создается только для известных методов
+	api, err := s.tenants.ForContext(r.Context())
+	if err != nil {
+		writeError(w, businessError(err))
+		return
+	}
+	out, rpcErr := m(api.WithActor(endpoints.ActorFromRequest(r)), in)
+	if rpcErr != nil {
+		writeError(w, rpcErr)
+		return
+	}
+
+	w.Header().Set("Content-Type", protoContentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(out.Marshal())
+}
+
+func writeError(w http.ResponseWriter, e *Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.Status(e.Code))
+	_ = json.NewEncoder(w).Encode(e)
+}
+
+func makeEvent(e structs.Event) *Event {
+	res := &Event{
+		Id:          int64(e.GetId()),
+		UserId:      int64(e.GetUserId()),
+		Date:        e.GetDate().Format("2006-01-02"),
+		Title:       e.GetTitle(),
+		Description: e.GetDescription(),
+	}
+	if e.IsDeleted() {
+		res.DeletedAt = e.GetDeletedAt().Format(time.RFC3339)
+	}
+	return res
+}
+
+func itoa(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+func createEvent(api *logic.EventAPI, in []byte) (Message, *Error) {
+	var req CreateEventRequest
+	if err := req.Unmarshal(in); err != nil {
+		return nil, errCantParse
+	}
+	newe, ok := endpoints.EventNoIdFromValues(url.Values{
+		"user_id":     {itoa(req.UserId)},
+		"date":        {req.Date},
+		"title":       {req.Title},
+		"description": {req.Description},
+	})
+	if !ok {
+		return nil, errCantParse
+	}
+
+	ev, err := api.Create(newe)
+	if err != nil {
+		return nil, businessError(err)
+	}
+	return &EventResponse{Event: makeEvent(ev)}, nil
+}
+
+func updateEvent(api *logic.EventAPI, in []byte) (Message, *Error) {
+	var req UpdateEventRequest
+	if err := req.Unmarshal(in); err != nil {
+		return nil, errCantParse
+	}
+	e, ok := endpoints.EventFromValues(url.Values{
+		"id":          {itoa(req.Id)},
+		"user_id":     {itoa(req.UserId)},
+		"date":        {req.Date},
+		"title":       {req.Title},
+		"description": {req.Description},
+	})
+	if !ok {
+		return nil, errCantParse
+	}
+
+	ev, err := api.Update(e)
+	if err != nil {
+		return nil, businessError(err)
+	}
+	return &EventResponse{Event: makeEvent(ev)}, nil
+}
+
+func deleteEvent(api *logic.EventAPI, in []byte) (Message, *Error) {
+	var req DeleteEventRequest
+	if err := req.Unmarshal(in); err != nil {
+		return nil, errCantParse
+	}
+	e, ok := endpoints.IdFromUrlValues(structs.EventNoId{}, url.Values{
+		"id": {itoa(req.Id)},
+	})
+	if !ok {
+		return nil, errCantParse
+	}
+
+	if err := api.Delete(e.GetId()); err != nil {
+		return nil, businessError(err)
+	}
+	return &DeleteEventResponse{}, nil
+}
+
+// Праздник в виде события-наложения
+func makeHolidayEvent(h structs.Holiday) *Event {
+	return &Event{
+		Id:      -1,
+		UserId:  -1,
+		Date:    h.Date.Format("2006-01-02"),
+		Title:   h.Name,
+		Overlay: &Overlay{Kind: "holiday", Region: h.Region, Working: h.Working},
+	}
+}
+
+func eventsFor(fn func(*logic.EventAPI, time.Time) ([]structs.Event, error), period func(time.Time) (time.Time, time.Time)) method {
+	return func(api *logic.EventAPI, in []byte) (Message, *Error) {
+		var req EventsForRequest
+		if err := req.Unmarshal(in); err != nil {
+			return nil, errCantParse
+		}
+		v := url.Values{"to_date": {req.ToDate}}
+		if req.Region != "" {
+			v.Set("region", req.Region)
+		}
+		date, ok := endpoints.FreeDateFromUrlValues("to_date", v)
+		if !ok {
+			return nil, errCantParse
+		}
+		region, ok := endpoints.RegionFromValues(v)
+		if !ok {
+			return nil, errCantParse
+		}
+
+		from, to := period(date)
+		holidays, err := api.Holidays(region, from, to)
+		if err != nil {
+			return nil, businessError(err)
+		}
+		list, err := fn(api, date)
+		if err != nil {
+			return nil, businessError(err)
+		}
+		// Праздники идут перед событиями, как и в HTTP+JSON API
+		res := &EventsResponse{}
+		for _, h := range holidays {
+			res.Events = append(res.Events, makeHolidayEvent(h))
+		}
+		for _, e := range list {
+			res.Events = append(res.Events, makeEvent(e))
+		}
+		return res, nil
+	}
+}
+
+func batch(api *logic.EventAPI, in []byte) (Message, *Error) {
+	var req BatchRequest
+	if err := req.Unmarshal(in); err != nil {
+		return nil, errCantParse
+	}
+	ops := make([]structs.BatchOp, len(req.Ops))
+	for i, rop := range req.Ops {
+		id, userId := int(rop.Id), int(rop.UserId)
+		op, ok := endpoints.BatchOpFromJson(endpoints.JsonBatchOp{
+			Op:          rop.Op,
+			Id:          &id,
+			UserId:      &userId,
+			Date:        &rop.Date,
+			Title:       &rop.Title,
+			Description: &rop.Description,
+		})
+		if !ok {
+			return nil, &Error{Code: CodeInvalidArgument, Message: fmt.Sprintf("can't parse operation %d", i)}
+		}
+		ops[i] = op
+	}
+
+	res, err := api.Batch(ops, !req.BestEffort)
+	if err != nil {
+		return nil, businessError(err)
+	}
+	out := &BatchResponse{}
+	for i, r := range res {
+		item := &BatchItem{}
+		switch {
+		case r.Err != nil:
+			item.Error = r.Err.Error()
+		case ops[i].Kind == structs.BatchDelete:
+			item.Deleted = true
+		default:
+			item.Event = makeEvent(r.Event)
+		}
+		out.Results = append(out.Results, item)
+	}
+	return out, nil
+}
+
+func trash(api *logic.EventAPI, in []byte) (Message, *Error) {
+	var req TrashRequest
+	if err := req.Unmarshal(in); err != nil {
+		return nil, errCantParse
+	}
+	userId, ok := endpoints.UserIdFromValues(url.Values{"user_id": {itoa(req.UserId)}})
+	if !ok {
+		return nil, errCantParse
+	}
+
+	list, err := api.Trash(userId)
+	if err != nil {
+		return nil, businessError(err)
+	}
+	res := &EventsResponse{}
+	for _, e := range list {
+		res.Events = append(res.Events, makeEvent(e))
+	}
+	return res, nil
+}
+
+func restoreEvent(api *logic.EventAPI, in []byte) (Message, *Error) {
+	var req RestoreEventRequest
+	if err := req.Unmarshal(in); err != nil {
+		return nil, errCantParse
+	}
+	e, ok := endpoints.IdFromUrlValues(structs.EventNoId{}, url.Values{"id": {itoa(req.Id)}})
+	if !ok {
+		return nil, errCantParse
+	}
+
+	ev, err := api.Restore(e.GetId())
+	if err != nil {
+		return nil, businessError(err)
+	}
+	return &EventResponse{Event: makeEvent(ev)}, nil
+}
+
+func makeRevision(r structs.Revision) *Revision {
+	res := &Revision{
+		Id:     int64(r.GetId()),
+		Actor:  r.GetActor(),
+		At:     r.GetAt().Format(time.RFC3339Nano),
+		Action: string(r.GetAction()),
+		Event:  makeEvent(r.GetEvent()),
+	}
+	for _, c := range r.GetDiff() {
+		res.Diff = append(res.Diff, &FieldChange{Field: c.Field, Old: c.Old, New: c.New})
+	}
+	return res
+}
+
+func history(api *logic.EventAPI, in []byte) (Message, *Error) {
+	var req HistoryRequest
+	if err := req.Unmarshal(in); err != nil {
+		return nil, errCantParse
+	}
+	e, ok := endpoints.IdFromUrlValues(structs.EventNoId{}, url.Values{"id": {itoa(req.Id)}})
+	if !ok {
+		return nil, errCantParse
+	}
+
+	revs, err := api.History(e.GetId())
+	if err != nil {
+		return nil, businessError(err)
+	}
+	res := &HistoryResponse{}
+	for _, r := range revs {
+		res.Revisions = append(res.Revisions, makeRevision(r))
+	}
+	return res, nil
+}
+
+func revert(api *logic.EventAPI, in []byte) (Message, *Error) {
+	var req RevertRequest
+	if err := req.Unmarshal(in); err != nil {
+		return nil, errCantParse
+	}
+	e, ok := endpoints.IdFromUrlValues(structs.EventNoId{}, url.Values{"id": {itoa(req.Id)}})
+	if !ok {
+		return nil, errCantParse
+	}
+	revId, ok := endpoints.RevisionIdFromValues(url.Values{"revision": {itoa(req.Revision)}})
+	if !ok {
+		return nil, errCantParse
+	}
+
+	ev, err := api.Revert(e.GetId(), revId)
+	if err != nil {
+		return nil, businessError(err)
+	}
+	return &EventResponse{Event: makeEvent(ev)}, nil
+}
+
+// Проверяет, что ошибка является ошибкой RPC с кодом code
+func IsCode(err error, code string) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == code
+}
@@ -0,0 +1,289 @@
+package rpc
+
+import (
+	"context"
+	"dev11/endpoints"
+	"dev11/holidays"
+	"dev11/logic"
+	"dev11/middleware"
+	"dev11/models"
+	"dev11/response"
+	"dev11/server"
+	"dev11/structs"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func buildClient() *Client {
+	api := logic.NewEventAPI(models.NewEventModelMemory())
+	return newInProcessClient(NewServer(api))
+}
+
+func TestServerCRUD(t *testing.T) {
+	c := buildClient()
+	ctx := context.Background()
+
+	created, err := c.CreateEvent(ctx, &CreateEventRequest{UserId: 3, Date: "2019-09-09"})
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	want := Event{Id: 0, UserId: 3, Date: "2019-09-09"}
+	if *created.Event != want {
+		t.Fatalf("got: %v want: %v", *created.Event, want)
+	}
+
+	updated, err := c.UpdateEvent(ctx, &UpdateEventRequest{Id: 0, UserId: 4, Date: "2019-09-10"})
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	want = Event{Id: 0, UserId: 4, Date: "2019-09-10"}
+	if *updated.Event != want {
+		t.Fatalf("got: %v want: %v", *updated.Event, want)
+	}
+
+	events, err := c.EventsForWeek(ctx, &EventsForRequest{ToDate: "2019-09-12"})
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if len(events.Events) != 1 || *events.Events[0] != want {
+		t.Fatalf("unexpected events: %v", events.Events)
+	}
+
+	if _, err := c.DeleteEvent(ctx, &DeleteEventRequest{Id: 0}); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	events, _ = c.EventsForDay(ctx, &EventsForRequest{ToDate: "2019-09-10"})
+	if len(events.Events) != 0 {
+		t.Fatalf("unexpected events: %v", events.Events)
+	}
+
+	_, err = c.DeleteEvent(ctx, &DeleteEventRequest{Id: 0})
+	if !IsCode(err, CodeInternal) {
+		t.Fatalf("got %v; want internal error", err)
+	}
+}
+
+func TestServerUnknownMethod(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", Path("Nope"), nil)
+	req.Header.Set("Content-Type", protoContentType)
+	NewServer(logic.NewEventAPI(models.NewEventModelMemory())).ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), `"code":"unimplemented"`) {
+		t.Fatalf("unexpected response: %d %s", rr.Code, rr.Body)
+	}
+}
+
+func TestServerCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := buildClient().CreateEvent(ctx, &CreateEventRequest{UserId: 3, Date: "2019-09-09"}); err == nil {
+		t.Fatal("err should be not nil")
+	}
+}
+
+// Ограничение тела запроса в тестах обоих транспортов
+const parityBodyLimit = 1024
+
+// api с квотой, историей и праздниками, чтобы проверить все ветки
+func parityAPI() *logic.EventAPI {
+	m := models.NewEventModelMemory()
+	m.SetQuota(2)
+	cal := holidays.NewCalendar([]structs.Holiday{
+		{Date: time.Date(2019, 9, 10, 0, 0, 0, 0, time.UTC), Name: "Праздник", Region: "ru"},
+	})
+	return logic.NewEventAPI(m).WithRevisions(models.NewRevisionModelMemory()).WithHolidays(cal, "ru")
+}
+
+// Шаг сценария: один и тот же запрос в обоих транспортах
+type parityStep struct {
+	method, path, body string
+	call               func(c *Client) error
+}
+
+// Оба транспорта должны одинаково принимать и отклонять запросы:
+// одинаковые HTTP статусы, коды и тексты ошибок
+func TestTransportsParity(t *testing.T) {
+	ctx := context.Background()
+	long := strings.Repeat("x", parityBodyLimit)
+	steps := []parityStep{
+		{"POST", "/create_event", "user_id=3&date=2019-09-09", func(c *Client) error {
+			_, err := c.CreateEvent(ctx, &CreateEventRequest{UserId: 3, Date: "2019-09-09"})
+			return err
+		}},
+		{"POST", "/create_event", "user_id=3&date=2019-13-09", func(c *Client) error {
+			_, err := c.CreateEvent(ctx, &CreateEventRequest{UserId: 3, Date: "2019-13-09"})
+			return err
+		}},
+		{"POST", "/create_event", "user_id=-1&date=2019-09-09", func(c *Client) error {
+			_, err := c.CreateEvent(ctx, &CreateEventRequest{UserId: -1, Date: "2019-09-09"})
+			return err
+		}},
+		{"POST", "/create_event", "user_id=3&date=2019-09-09&title=" + strings.Repeat("x", structs.MaxTitleLen+1), func(c *Client) error {
+			_, err := c.CreateEvent(ctx, &CreateEventRequest{UserId: 3, Date: "2019-09-09", Title: strings.Repeat("x", structs.MaxTitleLen+1)})
+			return err
+		}},
+		{"POST", "/create_event", "user_id=3&date=2019-09-11&title=" + url.QueryEscape("Встреча"), func(c *Client) error {
+			_, err := c.CreateEvent(ctx, &CreateEventRequest{UserId: 3, Date: "2019-09-11", Title: "Встреча"})
+			return err
+		}},
+		// Квота 2 события
+		{"POST", "/create_event", "user_id=3&date=2019-09-09", func(c *Client) error {
+			_, err := c.CreateEvent(ctx, &CreateEventRequest{UserId: 3, Date: "2019-09-09"})
+			return err
+		}},
+		{"POST", "/create_event", "user_id=3&date=2019-09-09&description=" + long, func(c *Client) error {
+			_, err := c.CreateEvent(ctx, &CreateEventRequest{UserId: 3, Date: "2019-09-09", Description: long})
+			return err
+		}},
+		{"POST", "/update_event", "id=0&user_id=4&date=2019-09-10", func(c *Client) error {
+			_, err := c.UpdateEvent(ctx, &UpdateEventRequest{Id: 0, UserId: 4, Date: "2019-09-10"})
+			return err
+		}},
+		{"POST", "/update_event", "id=99&user_id=4&date=2019-09-10", func(c *Client) error {
+			_, err := c.UpdateEvent(ctx, &UpdateEventRequest{Id: 99, UserId: 4, Date: "2019-09-10"})
+			return err
+		}},
+		{"POST", "/delete_event", "id=1", func(c *Client) error {
+			_, err := c.DeleteEvent(ctx, &DeleteEventRequest{Id: 1})
+			return err
+		}},
+		{"POST", "/delete_event", "id=1", func(c *Client) error {
+			_, err := c.DeleteEvent(ctx, &DeleteEventRequest{Id: 1})
+			return err
+		}},
+		{"GET", "/trash?user_id=3", "", func(c *Client) error {
+			_, err := c.Trash(ctx, &TrashRequest{UserId: 3})
+			return err
+		}},
+		{"GET", "/trash?user_id=-1", "", func(c *Client) error {
+			_, err := c.Trash(ctx, &TrashRequest{UserId: -1})
+			return err
+		}},
+		{"POST", "/restore_event", "id=1", func(c *Client) error {
+			_, err := c.RestoreEvent(ctx, &RestoreEventRequest{Id: 1})
+			return err
+		}},
+		{"POST", "/restore_event", "id=1", func(c *Client) error {
+			_, err := c.RestoreEvent(ctx, &RestoreEventRequest{Id: 1})
+			return err
+		}},
+		// Атомарный пакет упирается в квоту
+		{"POST", "/batch", `{"ops":[{"op":"delete","id":0},{"op":"create","user_id":5,"date":"2019-09-09"},{"op":"create","user_id":5,"date":"2019-09-09"}]}`, func(c *Client) error {
+			_, err := c.Batch(ctx, &BatchRequest{Ops: []*BatchOp{
+				{Op: "delete", Id: 0},
+				{Op: "create", UserId: 5, Date: "2019-09-09"},
+				{Op: "create", UserId: 5, Date: "2019-09-09"},
+			}})
+			return err
+		}},
+		{"POST", "/batch", `{"best_effort":true,"ops":[{"op":"delete","id":1},{"op":"update","id":7,"user_id":5,"date":"2019-09-09"}]}`, func(c *Client) error {
+			_, err := c.Batch(ctx, &BatchRequest{BestEffort: true, Ops: []*BatchOp{
+				{Op: "delete", Id: 1},
+				{Op: "update", Id: 7, UserId: 5, Date: "2019-09-09"},
+			}})
+			return err
+		}},
+		{"POST", "/batch", `{"ops":[{"op":"drop","id":0}]}`, func(c *Client) error {
+			_, err := c.Batch(ctx, &BatchRequest{Ops: []*BatchOp{{Op: "drop", Id: 0}}})
+			return err
+		}},
+		{"GET", "/events/0/history", "", func(c *Client) error {
+			_, err := c.History(ctx, &HistoryRequest{Id: 0})
+			return err
+		}},
+		{"POST", "/events/0/revert", "revision=0", func(c *Client) error {
+			_, err := c.Revert(ctx, &RevertRequest{Id: 0, Revision: 0})
+			return err
+		}},
+		{"POST", "/events/0/revert", "revision=-1", func(c *Client) error {
+			_, err := c.Revert(ctx, &RevertRequest{Id: 0, Revision: -1})
+			return err
+		}},
+		{"POST", "/events/0/revert", "revision=99", func(c *Client) error {
+			_, err := c.Revert(ctx, &RevertRequest{Id: 0, Revision: 99})
+			return err
+		}},
+		{"GET", "/events_for_week?to_date=2019-09-12", "", func(c *Client) error {
+			_, err := c.EventsForWeek(ctx, &EventsForRequest{ToDate: "2019-09-12"})
+			return err
+		}},
+		{"GET", "/events_for_day?to_date=2019-09-12&region=us", "", func(c *Client) error {
+			_, err := c.EventsForDay(ctx, &EventsForRequest{ToDate: "2019-09-12", Region: "us"})
+			return err
+		}},
+		{"GET", "/events_for_month?to_date=2019-09-12&region=r!", "", func(c *Client) error {
+			_, err := c.EventsForMonth(ctx, &EventsForRequest{ToDate: "2019-09-12", Region: "r!"})
+			return err
+		}},
+	}
+
+	mb := server.NewMuxBuilder()
+	mb.SetBodyLimit(parityBodyLimit)
+	mb.AddEventHTTP(endpoints.NewEventHTTP(parityAPI()))
+	mux := mb.Build()
+	c := newInProcessClient(middleware.MaxBytes(parityBodyLimit, NewServer(parityAPI())))
+
+	for i, step := range steps {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(step.method, step.path, strings.NewReader(step.body))
+		mux.ServeHTTP(rr, req)
+		var httpErr response.JsonError
+		if rr.Code != http.StatusOK {
+			_ = json.Unmarshal(rr.Body.Bytes(), &httpErr)
+		}
+
+		var rpcErr Error
+		if err := step.call(c); err != nil {
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("step %d %s: unexpected error %v", i, step.path, err)
+			}
+			rpcErr = *e
+		}
+		rpcStatus := http.StatusOK
+		if rpcErr.Code != "" {
+			rpcStatus = response.Status(rpcErr.Code)
+		}
+		if rr.Code != rpcStatus || httpErr.Code != rpcErr.Code || httpErr.Error != rpcErr.Message {
+			t.Errorf("step %d %s %s: http %d %s %q, rpc %d %s %q", i, step.path, step.body,
+				rr.Code, httpErr.Code, httpErr.Error, rpcStatus, rpcErr.Code, rpcErr.Message)
+		}
+	}
+}
+
+// Праздники приходят в RPC так же, как в HTTP+JSON API: перед событиями, с признаком наложения
+func TestServerHolidayOverlay(t *testing.T) {
+	ctx := context.Background()
+	c := newInProcessClient(NewServer(parityAPI()))
+	if _, err := c.CreateEvent(ctx, &CreateEventRequest{UserId: 3, Date: "2019-09-10"}); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+
+	res, err := c.EventsForDay(ctx, &EventsForRequest{ToDate: "2019-09-10"})
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	want := []Event{
+		{Id: -1, UserId: -1, Date: "2019-09-10", Title: "Праздник", Overlay: &Overlay{Kind: "holiday", Region: "ru"}},
+		{Id: 0, UserId: 3, Date: "2019-09-10"},
+	}
+	if len(res.Events) != len(want) {
+		t.Fatalf("unexpected events: %v", res.Events)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(*res.Events[i], want[i]) {
+			t.Errorf("got %v; want %v", *res.Events[i], want[i])
+		}
+	}
+
+	if _, err := c.EventsForDay(ctx, &EventsForRequest{ToDate: "2019-09-10", Region: "us"}); !IsCode(err, CodeInvalidArgument) {
+		t.Fatalf("got %v; want invalid_argument", err)
+	}
+}
@@ -0,0 +1,151 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Минимальная реализация формата protobuf.
+// В проекте используется только стандартная библиотека,
+// поэтому сообщения из calendar.proto сериализуются вручную.
+
+// Типы полей в формате protobuf
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireBytes  = 2
+	wireI32    = 5
+)
+
+var errTruncated = errors.New("proto: truncated message")
+
+func appendTag(b []byte, field int, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+// Добавляет int64 поле. Нулевые значения не пишутся, как в proto3
+func appendInt64(b []byte, field int, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendTag(b, field, wireVarint)
+	return binary.AppendUvarint(b, uint64(v))
+}
+
+// Добавляет bool поле. false не пишется, как в proto3
+func appendBool(b []byte, field int, v bool) []byte {
+	if !v {
+		return b
+	}
+	b = appendTag(b, field, wireVarint)
+	return append(b, 1)
+}
+
+func appendString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return appendBytes(b, field, []byte(s))
+}
+
+func appendBytes(b []byte, field int, v []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// Поле сообщения, прочитанное из буфера
+type wireField struct {
+	num      int
+	wireType int
+	varint   uint64
+	bytes    []byte
+}
+
+var errWireType = errors.New("proto: wrong wire type")
+
+// Читает поле типа int64 в v
+func (f wireField) int64(v *int64) error {
+	if f.wireType != wireVarint {
+		return errWireType
+	}
+	*v = int64(f.varint)
+	return nil
+}
+
+// Читает поле типа bool в v
+func (f wireField) bool(v *bool) error {
+	if f.wireType != wireVarint {
+		return errWireType
+	}
+	*v = f.varint != 0
+	return nil
+}
+
+// Читает поле типа string в s
+func (f wireField) string(s *string) error {
+	if f.wireType != wireBytes {
+		return errWireType
+	}
+	*s = string(f.bytes)
+	return nil
+}
+
+// Читает вложенное сообщение в m
+func (f wireField) message(m Message) error {
+	if f.wireType != wireBytes {
+		return errWireType
+	}
+	return m.Unmarshal(f.bytes)
+}
+
+// Перебирает поля сообщения b, вызывая fn для каждого.
+// Поля с неизвестными номерами должен пропускать сам fn
+func readFields(b []byte, fn func(f wireField) error) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errTruncated
+		}
+		b = b[n:]
+		f := wireField{num: int(tag >> 3), wireType: int(tag & 7)}
+		if f.num == 0 {
+			return errors.New("proto: invalid field number")
+		}
+
+		switch f.wireType {
+		case wireVarint:
+			f.varint, n = binary.Uvarint(b)
+			if n <= 0 {
+				return errTruncated
+			}
+			b = b[n:]
+		case wireI64:
+			if len(b) < 8 {
+				return errTruncated
+			}
+			f.varint = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case wireI32:
+			if len(b) < 4 {
+				return errTruncated
+			}
+			f.varint = uint64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return errTruncated
+			}
+			f.bytes = b[n : n+int(l)]
+			b = b[n+int(l):]
+		default:
+			return errors.New("proto: unsupported wire type")
+		}
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
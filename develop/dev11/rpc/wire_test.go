@@ -0,0 +1,63 @@
+package rpc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMessagesRoundTrip(t *testing.T) {
+	in := &EventsResponse{
+		Events: []*Event{
+			{Id: 1, UserId: 3, Date: "2019-09-09"},
+			{Id: 0, UserId: 0, Date: "2019-09-10", DeletedAt: "2020-01-01T00:00:00Z"},
+			{},
+		},
+	}
+	var out EventsResponse
+	if err := out.Unmarshal(in.Marshal()); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if !reflect.DeepEqual(in, &out) {
+		t.Fatalf("got: %v want: %v", out, in)
+	}
+
+	req := &UpdateEventRequest{Id: 300, UserId: 1 << 40, Date: "2019-09-09"}
+	var reqOut UpdateEventRequest
+	if err := reqOut.Unmarshal(req.Marshal()); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if *req != reqOut {
+		t.Fatalf("got: %v want: %v", reqOut, *req)
+	}
+}
+
+func TestUnmarshalSkipsUnknownFields(t *testing.T) {
+	var b []byte
+	b = appendInt64(b, 1, 7)
+	b = appendString(b, 15, "unknown")
+	b = appendInt64(b, 16, 100)
+	b = appendString(b, 2, "2019-09-09")
+
+	var req CreateEventRequest
+	if err := req.Unmarshal(b); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if req.UserId != 7 || req.Date != "2019-09-09" {
+		t.Fatalf("unexpected message: %v", req)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var req CreateEventRequest
+
+	// Длина строки больше, чем данных
+	b := appendString(nil, 2, "2019-09-09")
+	if err := req.Unmarshal(b[:len(b)-1]); err == nil {
+		t.Fatal("err should be not nil")
+	}
+
+	// Строка вместо числа
+	if err := req.Unmarshal(appendString(nil, 1, "3")); err == nil {
+		t.Fatal("err should be not nil")
+	}
+}
@@ -0,0 +1,227 @@
+package search
+
+import (
+	"dev11/structs"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Вес слова из заголовка относительно слова из описания
+const titleBoost = 2
+
+// Вес совпадения по префиксу относительно точного совпадения
+const prefixWeight = 0.5
+
+// Сколько раз слово встречается в событии
+type posting struct {
+	title int
+	body  int
+}
+
+// Инвертированный индекс событий: слово -> события, в которых оно встречается.
+// Для поиска по префиксу слова хранятся также в отсортированном виде
+type Index struct {
+	lock   sync.RWMutex
+	events map[structs.EventID]structs.Event
+	terms  map[string]map[structs.EventID]posting
+	sorted []string
+}
+
+func NewIndex() *Index {
+	return &Index{
+		events: make(map[structs.EventID]structs.Event),
+		terms:  make(map[string]map[structs.EventID]posting),
+	}
+}
+
+// Добавляет событие в индекс или обновляет его
+func (idx *Index) Put(e structs.Event) {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	idx.remove(e.GetId())
+	idx.put(e)
+}
+
+// Удаляет событие из индекса
+func (idx *Index) Remove(id structs.EventID) {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	idx.remove(id)
+}
+
+// Заменяет содержимое индекса событиями events
+func (idx *Index) Reset(events []structs.Event) {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	idx.events = make(map[structs.EventID]structs.Event, len(events))
+	idx.terms = make(map[string]map[structs.EventID]posting)
+	idx.sorted = nil
+	for _, e := range events {
+		idx.put(e)
+	}
+}
+
+// put без блокировки, вызывающий должен держать lock
+func (idx *Index) put(e structs.Event) {
+	id := e.GetId()
+	idx.events[id] = e
+	for _, w := range Tokenize(e.GetTitle()) {
+		p := idx.posting(w, id)
+		p.title++
+		idx.terms[w][id] = p
+	}
+	for _, w := range Tokenize(e.GetDescription()) {
+		p := idx.posting(w, id)
+		p.body++
+		idx.terms[w][id] = p
+	}
+}
+
+// Возвращает вхождения слова w в событие id, добавляя слово в словарь
+func (idx *Index) posting(w string, id structs.EventID) posting {
+	docs, ok := idx.terms[w]
+	if !ok {
+		docs = make(map[structs.EventID]posting)
+		idx.terms[w] = docs
+		i := sort.SearchStrings(idx.sorted, w)
+		idx.sorted = append(idx.sorted, "")
+		copy(idx.sorted[i+1:], idx.sorted[i:])
+		idx.sorted[i] = w
+	}
+	return docs[id]
+}
+
+// remove без блокировки, вызывающий должен держать lock
+func (idx *Index) remove(id structs.EventID) {
+	e, ok := idx.events[id]
+	if !ok {
+		return
+	}
+	delete(idx.events, id)
+	words := append(Tokenize(e.GetTitle()), Tokenize(e.GetDescription())...)
+	for _, w := range words {
+		docs, ok := idx.terms[w]
+		if !ok {
+			continue
+		}
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(idx.terms, w)
+			i := sort.SearchStrings(idx.sorted, w)
+			idx.sorted = append(idx.sorted[:i], idx.sorted[i+1:]...)
+		}
+	}
+}
+
+// Ищет события, в которых каждое слово запроса встречается целиком
+// или как начало слова. Результат упорядочен по убыванию релевантности,
+// при равной релевантности - по дате и id
+func (idx *Index) Search(q structs.SearchQuery) []structs.SearchHit {
+	words := Tokenize(q.Text)
+	if len(words) == 0 {
+		return nil
+	}
+
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+
+	var scores map[structs.EventID]float64
+	for _, w := range words {
+		// Лучшее совпадение слова запроса в каждом событии
+		terms := idx.withPrefix(w)
+		idf := idx.idf(terms)
+		best := make(map[structs.EventID]float64)
+		for _, term := range terms {
+			weight := idf
+			if term != w {
+				weight *= prefixWeight
+			}
+			for id, p := range idx.terms[term] {
+				if !idx.matches(id, q) {
+					continue
+				}
+				if s := weight * saturate(titleBoost*p.title+p.body); s > best[id] {
+					best[id] = s
+				}
+			}
+		}
+
+		// Событие должно содержать все слова запроса
+		if scores == nil {
+			scores = best
+			continue
+		}
+		for id := range scores {
+			if s, ok := best[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	res := make([]structs.SearchHit, 0, len(scores))
+	for id, s := range scores {
+		res = append(res, structs.SearchHit{Event: idx.events[id], Score: s})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if !a.Event.GetDate().Equal(b.Event.GetDate()) {
+			return a.Event.GetDate().Before(b.Event.GetDate())
+		}
+		return a.Event.GetId() < b.Event.GetId()
+	})
+	if q.Limit > 0 && len(res) > q.Limit {
+		res = res[:q.Limit]
+	}
+	return res
+}
+
+// Слова словаря, начинающиеся с prefix
+func (idx *Index) withPrefix(prefix string) []string {
+	i := sort.SearchStrings(idx.sorted, prefix)
+	j := i
+	for j < len(idx.sorted) && strings.HasPrefix(idx.sorted[j], prefix) {
+		j++
+	}
+	return idx.sorted[i:j]
+}
+
+// Чем в меньшем числе событий встречаются слова terms, тем больше их вес
+func (idx *Index) idf(terms []string) float64 {
+	docs := make(map[structs.EventID]bool)
+	for _, term := range terms {
+		for id := range idx.terms[term] {
+			docs[id] = true
+		}
+	}
+	if len(docs) == 0 {
+		return 0
+	}
+	return math.Log(1 + float64(len(idx.events))/float64(len(docs)))
+}
+
+// Подходит ли событие под фильтры запроса
+func (idx *Index) matches(id structs.EventID, q structs.SearchQuery) bool {
+	e := idx.events[id]
+	if q.UserId >= 0 && e.GetUserId() != q.UserId {
+		return false
+	}
+	if !q.From.IsZero() && e.GetDate().Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && e.GetDate().After(q.To) {
+		return false
+	}
+	return true
+}
+
+// Повторы слова увеличивают релевантность все меньше
+func saturate(tf int) float64 {
+	return float64(tf) / (float64(tf) + 1.2)
+}
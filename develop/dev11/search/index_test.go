@@ -0,0 +1,105 @@
+package search
+
+import (
+	"dev11/structs"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTokenize(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []string
+	}{
+		{"Hello, World!", []string{"hello", "world"}},
+		{"Ёлка в 18:00 — Большой зал", []string{"елка", "в", "18", "00", "большой", "зал"}},
+		{"  ", []string{}},
+	}
+	for _, c := range cases {
+		if got := Tokenize(c.in); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Tokenize(%q) = %q; want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func makeEvent(t *testing.T, id structs.EventID, userId structs.UserID, day int, title, description string) structs.Event {
+	t.Helper()
+	eni := structs.MakeEventNoId(userId, time.Date(2019, 9, day, 0, 0, 0, 0, time.UTC))
+	if !eni.SetTitle(title) || !eni.SetDescription(description) {
+		t.Fatal("can't set text")
+	}
+	e, _ := eni.MakeEventWithId(id)
+	return e
+}
+
+func hitIds(hits []structs.SearchHit) []structs.EventID {
+	res := []structs.EventID{}
+	for _, h := range hits {
+		res = append(res, h.Event.GetId())
+	}
+	return res
+}
+
+func TestIndexSearch(t *testing.T) {
+	idx := NewIndex()
+	idx.Put(makeEvent(t, 0, 1, 1, "Планерка", "Обсуждаем встречу с заказчиком"))
+	idx.Put(makeEvent(t, 1, 1, 2, "Встреча с заказчиком", ""))
+	idx.Put(makeEvent(t, 2, 2, 3, "Встреча выпускников", "Ёлка во дворе"))
+	idx.Put(makeEvent(t, 3, 1, 4, "Обед", ""))
+	idx.Put(makeEvent(t, 4, 1, 3, "Обеденный перерыв", ""))
+
+	cases := []struct {
+		name string
+		q    structs.SearchQuery
+		want []structs.EventID
+	}{
+		// Совпадение в заголовке важнее, чем в описании
+		{"title first", structs.SearchQuery{Text: "заказчиком", UserId: -1}, []structs.EventID{1, 0}},
+		// По префиксу находятся все формы слова
+		{"prefix", structs.SearchQuery{Text: "встреч", UserId: -1}, []structs.EventID{1, 2, 0}},
+		// Точное совпадение важнее совпадения по префиксу
+		{"exact first", structs.SearchQuery{Text: "обед", UserId: -1}, []structs.EventID{3, 4}},
+		{"all words", structs.SearchQuery{Text: "встреча выпуск", UserId: -1}, []structs.EventID{2}},
+		{"case and yo", structs.SearchQuery{Text: "ЕЛКА", UserId: -1}, []structs.EventID{2}},
+		{"user", structs.SearchQuery{Text: "встреча", UserId: 2}, []structs.EventID{2}},
+		{"dates", structs.SearchQuery{Text: "встреча", UserId: -1,
+			From: time.Date(2019, 9, 2, 0, 0, 0, 0, time.UTC),
+			To:   time.Date(2019, 9, 2, 0, 0, 0, 0, time.UTC)}, []structs.EventID{1}},
+		{"limit", structs.SearchQuery{Text: "встреч", UserId: -1, Limit: 1}, []structs.EventID{1}},
+		{"no match", structs.SearchQuery{Text: "ужин", UserId: -1}, []structs.EventID{}},
+	}
+	for _, c := range cases {
+		if got := hitIds(idx.Search(c.q)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v; want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestIndexUpdateRemove(t *testing.T) {
+	idx := NewIndex()
+	idx.Put(makeEvent(t, 0, 1, 1, "Встреча", ""))
+	idx.Put(makeEvent(t, 0, 1, 1, "Обед", ""))
+
+	q := structs.SearchQuery{Text: "встреча", UserId: -1}
+	if got := hitIds(idx.Search(q)); len(got) != 0 {
+		t.Fatalf("old title still found: %v", got)
+	}
+	q.Text = "обед"
+	if got := hitIds(idx.Search(q)); !reflect.DeepEqual(got, []structs.EventID{0}) {
+		t.Fatalf("got %v; want [0]", got)
+	}
+
+	idx.Remove(0)
+	if got := hitIds(idx.Search(q)); len(got) != 0 {
+		t.Fatalf("removed event found: %v", got)
+	}
+	if len(idx.sorted) != 0 || len(idx.terms) != 0 {
+		t.Fatalf("dictionary is not empty: %v", idx.sorted)
+	}
+
+	idx.Reset([]structs.Event{makeEvent(t, 5, 1, 1, "Обед", "")})
+	if got := hitIds(idx.Search(q)); !reflect.DeepEqual(got, []structs.EventID{5}) {
+		t.Fatalf("got %v; want [5]", got)
+	}
+}
@@ -0,0 +1,25 @@
+// Пакет search - полнотекстовый поиск по событиям в памяти.
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Разбивает текст на слова в нижнем регистре. Словом считается
+// последовательность букв и цифр, ё приводится к е
+func Tokenize(s string) []string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	res := words[:0]
+	for _, w := range words {
+		res = append(res, normalize(w))
+	}
+	return res
+}
+
+func normalize(w string) string {
+	w = strings.ToLower(w)
+	return strings.ReplaceAll(w, "ё", "е")
+}
@@ -0,0 +1,180 @@
+// Пакет server собирает http обработчики в один мультиплексер.
+package server
+
+import (
+	"dev11/endpoints"
+	"dev11/middleware"
+	"dev11/openapi"
+	"dev11/response"
+	"net/http"
+)
+
+// Ограничение размера тела запроса по умолчанию
+const DefaultBodyLimit = 1 << 20
+
+// Зарегистрированный маршрут
+type route struct {
+	pattern string
+	method  string
+}
+
+type muxBuilder struct {
+	mux        *http.ServeMux
+	routes     []route
+	docs       openapi.Paths    // Описание маршрутов
+	bodyLimit  int64            // Ограничение размера тела запроса
+	bodyLimits map[string]int64 // Ограничения для отдельных маршрутов
+	limiter    *middleware.RateLimiter
+	limitKey   func(*http.Request) string // Как определяется клиент для limiter
+	tenants    *middleware.TenantResolver // nil - арендатор в контекст не добавляется
+	userHeader string                     // Пустая строка - пользователь в контекст не добавляется
+	cors       *middleware.CORSConfig     // nil - заголовки CORS не добавляются
+	compress   int                        // Минимальный размер сжимаемого ответа, <0 - не сжимать
+}
+
+func NewMuxBuilder() *muxBuilder {
+	mux := http.NewServeMux()
+	// Неизвестные маршруты получают json ответ вместо текстового
+	mux.HandleFunc("/", response.NotFound)
+	return &muxBuilder{
+		mux:        mux,
+		docs:       openapi.Paths{},
+		bodyLimit:  DefaultBodyLimit,
+		bodyLimits: make(map[string]int64),
+		compress:   -1,
+	}
+}
+
+// Задает ограничение размера тела запроса для всех маршрутов.
+// Должна вызываться до добавления маршрутов
+func (m *muxBuilder) SetBodyLimit(n int64) {
+	m.bodyLimit = n
+}
+
+// Задает ограничение размера тела запроса для маршрута pattern.
+// Должна вызываться до добавления маршрутов
+func (m *muxBuilder) SetRouteBodyLimit(pattern string, n int64) {
+	m.bodyLimits[pattern] = n
+}
+
+// Включает ограничение частоты запросов от одного клиента.
+// Клиент определяется функцией key, nil - по IP
+func (m *muxBuilder) SetRateLimiter(l *middleware.RateLimiter, key func(*http.Request) string) {
+	if key == nil {
+		key = middleware.ClientIP
+	}
+	m.limiter = l
+	m.limitKey = key
+}
+
+// Включает определение арендатора запроса
+func (m *muxBuilder) SetTenantResolver(tr middleware.TenantResolver) {
+	m.tenants = &tr
+}
+
+// Включает определение аутентифицированного пользователя по заголовку header,
+// который выставляет доверенный прокси
+func (m *muxBuilder) SetUserHeader(header string) {
+	m.userHeader = header
+}
+
+// Включает заголовки CORS для браузерных клиентов
+func (m *muxBuilder) SetCORS(c middleware.CORSConfig) {
+	m.cors = &c
+}
+
+// Включает сжатие ответов не короче minSize байт, отрицательное значение выключает сжатие
+func (m *muxBuilder) SetCompression(minSize int) {
+	m.compress = minSize
+}
+
+// Регистрирует обработчик h для pattern, который принимает только метод method
+func (m *muxBuilder) handle(pattern, method string, h http.HandlerFunc) {
+	limit, ok := m.bodyLimits[pattern]
+	if !ok {
+		limit = m.bodyLimit
+	}
+	m.mux.Handle(pattern, middleware.WithMethod(method, middleware.MaxBytes(limit, h)))
+	m.routes = append(m.routes, route{pattern: pattern, method: method})
+}
+
+func (m *muxBuilder) AddEventHTTP(e *endpoints.EventHTTP) {
+	m.handle("/create_event", "POST", e.CreateHandle)
+	m.handle("/update_event", "POST", e.UpdateHandle)
+	m.handle("/delete_event", "POST", e.DeleteHandle)
+	m.handle("/batch", "POST", e.BatchHandle)
+	m.handle("/restore_event", "POST", e.RestoreHandle)
+	m.handle("/trash", "GET", e.TrashHandle)
+	m.handle("/search", "GET", e.SearchHandle)
+	m.handle("/events/{id}/history", "GET", e.HistoryHandle)
+	m.handle("/events/{id}/revert", "POST", e.RevertHandle)
+	m.handle("/working_days", "GET", e.WorkingDaysHandle)
+
+	m.handle("/events_for_day", "GET", e.ForDayHandle)
+	m.handle("/events_for_week", "GET", e.ForWeekHandle)
+	m.handle("/events_for_month", "GET", e.ForMonthHandle)
+
+	m.docs.Merge(endpoints.Docs())
+}
+
+// Добавляет административные маршруты, доступные только с токеном token
+func (m *muxBuilder) AddAdmin(e *endpoints.EventHTTP, token string) {
+	m.handle("/admin/snapshot", "GET", middleware.WithToken(token, http.HandlerFunc(e.SnapshotHandle)).ServeHTTP)
+	m.handle("/admin/restore", "POST", middleware.WithToken(token, http.HandlerFunc(e.RestoreSnapshotHandle)).ServeHTTP)
+
+	m.docs.Merge(endpoints.AdminDocs())
+}
+
+// Описание API для всех маршрутов, у которых оно есть
+func (m *muxBuilder) Document() openapi.Document {
+	paths := openapi.Paths{}
+	for _, r := range m.routes {
+		if op, ok := m.docs.Get(r.pattern, r.method); ok {
+			paths.Add(r.pattern, r.method, op)
+		}
+	}
+	return openapi.Document{
+		OpenAPI:    "3.0.3",
+		Info:       openapi.Info{Title: "dev11 calendar", Version: "1.0.0"},
+		Paths:      paths,
+		Components: endpoints.Components(),
+	}
+}
+
+// Добавляет /openapi.json с описанием API и страницу /docs.
+// Описание строится по маршрутам, зарегистрированным к моменту запроса
+func (m *muxBuilder) AddOpenAPI() {
+	const specURL, docsURL = "/openapi.json", "/docs"
+	m.handle(specURL, "GET", openapi.JSONHandler(m.Document))
+	m.handle(docsURL, "GET", openapi.DocsHandler(specURL, m.Document))
+	m.docs.Merge(openapi.SelfPaths(specURL, docsURL))
+}
+
+func (m *muxBuilder) Build() http.Handler {
+	var h http.Handler = m.mux
+	// Определяем арендатора
+	if m.tenants != nil {
+		h = middleware.Tenant(*m.tenants, h)
+	}
+	// Определяем пользователя
+	if m.userHeader != "" {
+		h = middleware.User(m.userHeader, h)
+	}
+	// Ограничиваем частоту запросов
+	if m.limiter != nil {
+		h = middleware.RateLimit(m.limiter, m.limitKey, h)
+	}
+	// Сжимаем ответы
+	if m.compress >= 0 {
+		h = middleware.Compress(m.compress, h)
+	}
+	// Отвечаем на preflight запросы до ограничения частоты
+	if m.cors != nil {
+		h = middleware.CORS(*m.cors, h)
+	}
+	// Паника в обработчике превращается в ответ 500
+	h = middleware.Recover(h)
+	// Добавляем логирование запросов
+	withLogger := middleware.Logging(h)
+	return withLogger
+}
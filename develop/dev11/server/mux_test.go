@@ -0,0 +1,317 @@
+package server
+
+import (
+	"dev11/endpoints"
+	"dev11/logic"
+	"dev11/middleware"
+	"dev11/models"
+	"dev11/openapi"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func buildTestMux(setup func(mb *muxBuilder)) http.Handler {
+	mb := NewMuxBuilder()
+	if setup != nil {
+		setup(mb)
+	}
+	mb.AddEventHTTP(endpoints.NewEventHTTP(logic.NewEventAPI(models.NewEventModelMemory())))
+	return mb.Build()
+}
+
+func TestMuxRouteBodyLimit(t *testing.T) {
+	mux := buildTestMux(func(mb *muxBuilder) {
+		mb.SetBodyLimit(10)
+		mb.SetRouteBodyLimit("/batch", 1000)
+	})
+
+	req := httptest.NewRequest("POST", "/create_event", strings.NewReader("user_id=3&date=2019-09-09"))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("got %d; want %d", rr.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	body := `{"ops":[{"op":"create","user_id":3,"date":"2019-09-09"}]}`
+	req = httptest.NewRequest("POST", "/batch", strings.NewReader(body))
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got %d; want %d: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+}
+
+func TestMuxRateLimit(t *testing.T) {
+	mux := buildTestMux(func(mb *muxBuilder) {
+		mb.SetRateLimiter(middleware.NewRateLimiter(1, 1), nil)
+	})
+
+	req := httptest.NewRequest("GET", "/events_for_day?to_date=2019-09-09", nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got %d; want %d", rr.Code, http.StatusOK)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("got %d; want %d with Retry-After", rr.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestMuxTenants(t *testing.T) {
+	mb := NewMuxBuilder()
+	mb.SetTenantResolver(middleware.TenantResolver{Header: "X-Tenant"})
+	mb.AddEventHTTP(endpoints.NewTenantEventHTTP(logic.NewTenants(func(string) *logic.EventAPI {
+		return logic.NewEventAPI(models.NewEventModelMemory())
+	})))
+	mux := mb.Build()
+
+	req := httptest.NewRequest("POST", "/create_event", strings.NewReader("user_id=3&date=2019-09-09"))
+	req.Header.Set("X-Tenant", "a")
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Арендатор b не видит события арендатора a
+	for tenant, want := range map[string]string{"a": `"id":0`, "b": `{"result":[]}`} {
+		req = httptest.NewRequest("GET", "/events_for_day?to_date=2019-09-09", nil)
+		req.Header.Set("X-Tenant", tenant)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), want) {
+			t.Fatalf("tenant %s: got %d %s", tenant, rr.Code, rr.Body)
+		}
+	}
+
+	// Без арендатора и без арендатора по умолчанию
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/events_for_day?to_date=2019-09-09", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("got %d; want %d", rr.Code, http.StatusBadRequest)
+	}
+}
+
+// Запросы, которые не доходят до событий, не создают хранилищ арендаторов
+func TestMuxTenantsLimit(t *testing.T) {
+	tenants := logic.NewTenants(func(string) *logic.EventAPI {
+		return logic.NewEventAPI(models.NewEventModelMemory())
+	})
+	tenants.SetLimit(1)
+	mb := NewMuxBuilder()
+	mb.SetTenantResolver(middleware.TenantResolver{Header: "X-Tenant"})
+	mb.AddEventHTTP(endpoints.NewTenantEventHTTP(tenants))
+	mb.AddOpenAPI()
+	mux := mb.Build()
+
+	steps := []struct {
+		tenant, method, path, body string
+		want                       int
+	}{
+		{"a", "GET", "/no_such_route", "", http.StatusNotFound},
+		{"b", "GET", "/openapi.json", "", http.StatusOK},
+		{"c", "GET", "/docs", "", http.StatusOK},
+		{"d", "POST", "/create_event", "user_id=3&date=2019-13-09", http.StatusBadRequest},
+		{"e", "POST", "/create_event", "user_id=3&date=2019-09-09", http.StatusOK},
+		{"f", "GET", "/events_for_day?to_date=2019-09-09", "", http.StatusForbidden},
+		{"e", "GET", "/events_for_day?to_date=2019-09-09", "", http.StatusOK},
+	}
+	for _, step := range steps {
+		req := httptest.NewRequest(step.method, step.path, strings.NewReader(step.body))
+		req.Header.Set("X-Tenant", step.tenant)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != step.want {
+			t.Fatalf("%s %s: got %d; want %d", step.tenant, step.path, rr.Code, step.want)
+		}
+	}
+	if got := tenants.List(); len(got) != 1 || got[0] != "e" {
+		t.Fatalf("got tenants %v; want [e]", got)
+	}
+}
+
+func TestMuxAdminSnapshot(t *testing.T) {
+	mb := NewMuxBuilder()
+	e := endpoints.NewEventHTTP(logic.NewEventAPI(models.NewEventModelMemory()))
+	mb.AddEventHTTP(e)
+	mb.AddAdmin(e, "secret")
+	mux := mb.Build()
+
+	req := httptest.NewRequest("POST", "/create_event", strings.NewReader("user_id=3&date=2019-09-09"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Без токена
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/snapshot", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("got %d; want %d", rr.Code, http.StatusUnauthorized)
+	}
+
+	// Снимок
+	req = httptest.NewRequest("GET", "/admin/snapshot", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/gzip" {
+		t.Fatalf("got %d %q; want %d gzip", rr.Code, rr.Header().Get("Content-Type"), http.StatusOK)
+	}
+	snap := rr.Body.Bytes()
+
+	// Восстановление
+	req = httptest.NewRequest("POST", "/admin/restore", strings.NewReader(string(snap)))
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "restored 1 events") {
+		t.Fatalf("got %d %s", rr.Code, rr.Body)
+	}
+
+	// Поврежденный снимок
+	snap[len(snap)/2] ^= 0xff
+	req = httptest.NewRequest("POST", "/admin/restore", strings.NewReader(string(snap)))
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("got %d; want %d", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	mb := NewMuxBuilder()
+	e := endpoints.NewEventHTTP(logic.NewEventAPI(models.NewEventModelMemory()))
+	mb.AddEventHTTP(e)
+	mb.AddAdmin(e, "secret")
+	mb.AddOpenAPI()
+
+	// Получаем документ так же, как клиент
+	rr := httptest.NewRecorder()
+	mb.Build().ServeHTTP(rr, httptest.NewRequest("GET", "/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got %d; want %d", rr.Code, http.StatusOK)
+	}
+	var doc openapi.Document
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+
+	for _, r := range mb.routes {
+		op, ok := doc.Paths.Get(r.pattern, r.method)
+		if !ok {
+			t.Errorf("route %s %s is not documented", r.method, r.pattern)
+			continue
+		}
+		if _, ok := op.Responses["200"]; !ok {
+			t.Errorf("route %s %s: no 200 response", r.method, r.pattern)
+		}
+		// Параметры пути должны быть описаны
+		for _, name := range pathParams(r.pattern) {
+			var found bool
+			for _, p := range op.Parameters {
+				found = found || (p.In == "path" && p.Name == name)
+			}
+			if !found {
+				t.Errorf("route %s %s: path parameter %s is not documented", r.method, r.pattern, name)
+			}
+		}
+	}
+
+	// Ссылки на схемы должны вести на существующие схемы
+	for _, ref := range findRefs(rr.Body.String()) {
+		if _, ok := doc.Components.Schemas[ref]; !ok {
+			t.Errorf("unknown schema %s", ref)
+		}
+	}
+}
+
+func TestDocsPage(t *testing.T) {
+	mb := NewMuxBuilder()
+	mb.AddEventHTTP(endpoints.NewEventHTTP(logic.NewEventAPI(models.NewEventModelMemory())))
+	mb.AddOpenAPI()
+
+	rr := httptest.NewRecorder()
+	mb.Build().ServeHTTP(rr, httptest.NewRequest("GET", "/docs", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "/create_event") {
+		t.Fatalf("unexpected docs page: %d %s", rr.Code, rr.Body)
+	}
+}
+
+func pathParams(pattern string) []string {
+	var res []string
+	for _, part := range strings.Split(pattern, "/") {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			res = append(res, strings.Trim(part, "{}"))
+		}
+	}
+	return res
+}
+
+func findRefs(doc string) []string {
+	const prefix = `"$ref":"#/components/schemas/`
+	var res []string
+	for _, part := range strings.Split(doc, prefix)[1:] {
+		res = append(res, part[:strings.IndexByte(part, '"')])
+	}
+	return res
+}
+
+func TestMuxCORSCompression(t *testing.T) {
+	mux := buildTestMux(func(mb *muxBuilder) {
+		mb.SetRateLimiter(middleware.NewRateLimiter(1, 1), nil)
+		mb.SetCompression(0)
+		mb.SetCORS(middleware.CORSConfig{
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: []string{"GET", "POST", "OPTIONS"},
+		})
+	})
+
+	// Preflight не проходит дальше CORS и не тратит лимит запросов
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("OPTIONS", "/create_event", nil)
+		req.Header.Set("Origin", "https://app.example")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusNoContent {
+			t.Fatalf("got %d; want %d", rr.Code, http.StatusNoContent)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/events_for_day?to_date=2019-09-09", nil)
+	req.Header.Set("Origin", "https://app.example")
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Encoding") != "gzip" ||
+		rr.Header().Get("Access-Control-Allow-Origin") != "https://app.example" {
+		t.Fatalf("got %d %v", rr.Code, rr.Header())
+	}
+}
+
+func TestMuxErrorEnvelopes(t *testing.T) {
+	mux := buildTestMux(nil)
+	cases := []struct {
+		method, target string
+		code           int
+		allow          string
+	}{
+		{"GET", "/no_such_route", http.StatusNotFound, ""},
+		{"GET", "/create_event", http.StatusMethodNotAllowed, "POST"},
+		{"POST", "/events_for_day", http.StatusMethodNotAllowed, "GET"},
+	}
+	for _, tc := range cases {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.target, nil))
+		var body endpoints.JsonError
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Error == "" {
+			t.Errorf("%s %s: invalid envelope %q", tc.method, tc.target, rr.Body)
+		}
+		if rr.Code != tc.code || rr.Header().Get("Allow") != tc.allow ||
+			rr.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s %s: got %d %v", tc.method, tc.target, rr.Code, rr.Header())
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Пакет snapshot сохраняет снимок хранилища событий в файл и читает его.
+//
+// Файл - это gzip с JSON документом:
+//
+//	{"version":1,"created_at":"...","checksum":"sha256:...","data":{"free_id":2,"events":[...]}}
+//
+// checksum считается по байтам поля data, поэтому поврежденный
+// или измененный вручную файл не будет загружен.
+package snapshot
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"dev11/structs"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Версия формата. Увеличивается при несовместимых изменениях
+const Version = 1
+
+var ErrChecksum = errors.New("snapshot checksum mismatch")
+
+type fileEnvelope struct {
+	Version   int             `json:"version"`
+	CreatedAt string          `json:"created_at"`
+	Checksum  string          `json:"checksum"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type fileData struct {
+	FreeId structs.EventID `json:"free_id"`
+	Events []fileEvent     `json:"events"`
+}
+
+type fileEvent struct {
+	Id          structs.EventID `json:"id"`
+	UserId      structs.UserID  `json:"user_id"`
+	Date        string          `json:"date"`
+	Title       string          `json:"title,omitempty"`
+	Description string          `json:"description,omitempty"`
+	DeletedAt   string          `json:"deleted_at,omitempty"`
+}
+
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Записывает снимок s в w
+func Write(w io.Writer, s structs.Snapshot) error {
+	data := fileData{
+		FreeId: s.FreeId,
+		Events: make([]fileEvent, 0, len(s.Events)),
+	}
+	for _, e := range s.Events {
+		fe := fileEvent{
+			Id:          e.GetId(),
+			UserId:      e.GetUserId(),
+			Date:        e.GetDate().Format("2006-01-02"),
+			Title:       e.GetTitle(),
+			Description: e.GetDescription(),
+		}
+		if e.IsDeleted() {
+			fe.DeletedAt = e.GetDeletedAt().Format(time.RFC3339Nano)
+		}
+		data.Events = append(data.Events, fe)
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(w)
+	err = json.NewEncoder(zw).Encode(fileEnvelope{
+		Version:   Version,
+		CreatedAt: s.TakenAt.Format(time.RFC3339Nano),
+		Checksum:  checksum(raw),
+		Data:      raw,
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Читает снимок из r, проверяя версию и контрольную сумму
+func Read(r io.Reader) (structs.Snapshot, error) {
+	var res structs.Snapshot
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return res, fmt.Errorf("snapshot: %w", err)
+	}
+	defer zr.Close()
+
+	// Читаем поток целиком, чтобы gzip проверил свою контрольную сумму
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return res, fmt.Errorf("snapshot: %w", err)
+	}
+	var env fileEnvelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return res, fmt.Errorf("snapshot: %w", err)
+	}
+	if env.Version != Version {
+		return res, fmt.Errorf("snapshot: incompatible version %d, want %d", env.Version, Version)
+	}
+	if checksum(env.Data) != env.Checksum {
+		return res, ErrChecksum
+	}
+	if res.TakenAt, err = time.Parse(time.RFC3339Nano, env.CreatedAt); err != nil {
+		return res, fmt.Errorf("snapshot: bad created_at: %w", err)
+	}
+
+	var data fileData
+	if err := json.Unmarshal(env.Data, &data); err != nil {
+		return res, fmt.Errorf("snapshot: %w", err)
+	}
+	res.FreeId = data.FreeId
+	for _, fe := range data.Events {
+		e, err := fe.event()
+		if err != nil {
+			return structs.Snapshot{}, fmt.Errorf("snapshot: event %d: %w", fe.Id, err)
+		}
+		res.Events = append(res.Events, e)
+	}
+	return res, nil
+}
+
+func (fe fileEvent) event() (structs.Event, error) {
+	var eni structs.EventNoId
+	if !eni.SetUserId(fe.UserId) {
+		return structs.Event{}, errors.New("bad user_id")
+	}
+	date, err := time.Parse("2006-01-02", fe.Date)
+	if err != nil {
+		return structs.Event{}, err
+	}
+	eni.SetDate(date)
+	if !eni.SetTitle(fe.Title) || !eni.SetDescription(fe.Description) {
+		return structs.Event{}, errors.New("bad title or description")
+	}
+
+	var deletedAt time.Time
+	if fe.DeletedAt != "" {
+		if deletedAt, err = time.Parse(time.RFC3339Nano, fe.DeletedAt); err != nil {
+			return structs.Event{}, err
+		}
+	}
+	e, ok := structs.RestoreEvent(fe.Id, eni, deletedAt)
+	if !ok {
+		return e, errors.New("bad id")
+	}
+	return e, nil
+}
+
+// Записывает снимок в файл name. Сначала пишется временный файл,
+// который затем переименовывается, чтобы не оставить файл наполовину записанным
+func WriteFile(name string, s structs.Snapshot) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := Write(tmp, s); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// Читает снимок из файла name
+func ReadFile(name string) (structs.Snapshot, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return structs.Snapshot{}, err
+	}
+	defer f.Close()
+	return Read(f)
+}
@@ -0,0 +1,88 @@
+package snapshot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"dev11/structs"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSnapshot(t *testing.T) structs.Snapshot {
+	t.Helper()
+	date := time.Date(2019, 9, 9, 0, 0, 0, 0, time.UTC)
+	eni := structs.MakeEventNoId(1, date)
+	eni.SetTitle("Встреча")
+	eni.SetDescription("С заказчиком")
+	a, _ := eni.MakeEventWithId(0)
+	b, _ := structs.RestoreEvent(3, structs.MakeEventNoId(2, date), time.Date(2020, 1, 1, 10, 0, 0, 5, time.UTC))
+	return structs.Snapshot{
+		TakenAt: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+		Events:  []structs.Event{a, b},
+		FreeId:  4,
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	want := testSnapshot(t)
+	file := filepath.Join(t.TempDir(), "events.snap.gz")
+	if err := WriteFile(file, want); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	got, err := ReadFile(file)
+	if err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+// Пересобирает файл, заменяя old на new в распакованном содержимом
+func tamper(t *testing.T, file []byte, old, new string) []byte {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plain bytes.Buffer
+	_, _ = plain.ReadFrom(zr)
+	if !strings.Contains(plain.String(), old) {
+		t.Fatalf("%q not found in %s", old, plain.String())
+	}
+
+	var res bytes.Buffer
+	zw := gzip.NewWriter(&res)
+	_, _ = zw.Write([]byte(strings.Replace(plain.String(), old, new, 1)))
+	_ = zw.Close()
+	return res.Bytes()
+}
+
+func TestReadRejects(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testSnapshot(t)); err != nil {
+		t.Fatal("err should be nil", err)
+	}
+	file := buf.Bytes()
+
+	// Данные изменены
+	_, err := Read(bytes.NewReader(tamper(t, file, `"user_id":2`, `"user_id":5`)))
+	if !errors.Is(err, ErrChecksum) {
+		t.Fatalf("got %v; want ErrChecksum", err)
+	}
+
+	// Другая версия
+	_, err = Read(bytes.NewReader(tamper(t, file, `"version":1`, `"version":2`)))
+	if err == nil || !strings.Contains(err.Error(), "incompatible version") {
+		t.Fatalf("got %v; want incompatible version", err)
+	}
+
+	// Не gzip
+	if _, err := Read(strings.NewReader("{}")); err == nil {
+		t.Fatal("err should be not nil")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"dev11/logic"
+	"dev11/snapshot"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// Подстановка имени арендатора в путь к файлу снимка
+const tenantPlaceholder = "{tenant}"
+
+// Загружает снимки арендаторов. Если в pattern нет {tenant},
+// то файл загружается в арендатора по умолчанию
+func loadSnapshots(tenants *logic.Tenants, pattern string) error {
+	if !strings.Contains(pattern, tenantPlaceholder) {
+		return loadSnapshot(tenants.Get(logic.DefaultTenant), pattern)
+	}
+
+	// Имя арендатора - часть имени файла на месте {tenant}.
+	// Glob возвращает очищенные пути, поэтому очищаем и шаблон
+	pattern = filepath.Clean(pattern)
+	prefix, suffix, _ := strings.Cut(pattern, tenantPlaceholder)
+	files, err := filepath.Glob(strings.ReplaceAll(pattern, tenantPlaceholder, "*"))
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		tenant := strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix)
+		if err := loadSnapshot(tenants.Get(tenant), name); err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant, err)
+		}
+	}
+	return nil
+}
+
+func loadSnapshot(api *logic.EventAPI, name string) error {
+	s, err := snapshot.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	if err := api.LoadSnapshot(s); err != nil {
+		return err
+	}
+	log.Printf("restored %d events from %s\n", len(s.Events), name)
+	return nil
+}
+
+// Сохраняет снимки арендаторов. Если в pattern нет {tenant},
+// то сохраняется только арендатор по умолчанию
+func saveSnapshots(tenants *logic.Tenants, pattern string) error {
+	if !strings.Contains(pattern, tenantPlaceholder) {
+		return saveSnapshot(tenants.Get(logic.DefaultTenant), pattern)
+	}
+	for _, tenant := range tenants.List() {
+		name := strings.ReplaceAll(pattern, tenantPlaceholder, tenant)
+		if err := saveSnapshot(tenants.Get(tenant), name); err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant, err)
+		}
+	}
+	return nil
+}
+
+func saveSnapshot(api *logic.EventAPI, name string) error {
+	s, err := api.Snapshot()
+	if err != nil {
+		return err
+	}
+	if err := snapshot.WriteFile(name, s); err != nil {
+		return err
+	}
+	log.Printf("saved %d events to %s\n", len(s.Events), name)
+	return nil
+}
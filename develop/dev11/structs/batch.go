@@ -0,0 +1,48 @@
+package structs
+
+// Тип операции в пакетном запросе
+type BatchOpKind int
+
+const (
+	BatchCreate BatchOpKind = iota
+	BatchUpdate
+	BatchDelete
+)
+
+// Одна операция пакетного запроса.
+// Для BatchCreate используется только EventNoId, для BatchDelete только id
+type BatchOp struct {
+	Kind  BatchOpKind
+	Event Event
+}
+
+// Конструктор операции создания события
+func MakeBatchCreate(e EventNoId) BatchOp {
+	return BatchOp{
+		Kind:  BatchCreate,
+		Event: Event{EventNoId: e},
+	}
+}
+
+// Конструктор операции изменения события
+func MakeBatchUpdate(e Event) BatchOp {
+	return BatchOp{
+		Kind:  BatchUpdate,
+		Event: e,
+	}
+}
+
+// Конструктор операции удаления события
+func MakeBatchDelete(id EventID) BatchOp {
+	return BatchOp{
+		Kind:  BatchDelete,
+		Event: Event{id: id},
+	}
+}
+
+// Результат выполнения одной операции пакета.
+// Если Err != nil, то операция не была применена
+type BatchResult struct {
+	Event Event
+	Err   error
+}
@@ -0,0 +1,12 @@
+package structs
+
+import "errors"
+
+// Превышено ограничение на количество событий
+var ErrQuotaExceeded = errors.New("event quota exceeded")
+
+// Превышено ограничение на количество арендаторов
+var ErrTooManyTenants = errors.New("too many tenants")
+
+// Для региона нет производственного календаря
+var ErrUnknownRegion = errors.New("unknown holiday region")
@@ -1,12 +1,22 @@
 package structs
 
-import "time"
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// Ограничения на длину текстовых полей события в символах
+const (
+	MaxTitleLen       = 200
+	MaxDescriptionLen = 4000
+)
 
 type UserID int // TODO убрать в user.go
 
 type EventID int
 type Event struct {
-	id EventID
+	id        EventID
+	deletedAt time.Time // Момент мягкого удаления, нулевое значение - событие не удалено
 	EventNoId
 }
 
@@ -14,10 +24,27 @@ func (e *Event) GetId() EventID {
 	return e.id
 }
 
+func (e *Event) GetDeletedAt() time.Time {
+	return e.deletedAt
+}
+
+// Находится ли событие в корзине
+func (e *Event) IsDeleted() bool {
+	return !e.deletedAt.IsZero()
+}
+
+// Помещает событие в корзину с моментом удаления at.
+// Нулевое at достает событие из корзины
+func (e *Event) SetDeletedAt(at time.Time) {
+	e.deletedAt = at
+}
+
 // Event без поля ID, используется для создания записей
 type EventNoId struct {
-	userId UserID
-	date   time.Time
+	userId      UserID
+	date        time.Time
+	title       string
+	description string
 }
 
 // Конструктор для EventNoId
@@ -36,6 +63,33 @@ func (e *EventNoId) GetDate() time.Time {
 	return e.date
 }
 
+func (e *EventNoId) GetTitle() string {
+	return e.title
+}
+
+func (e *EventNoId) GetDescription() string {
+	return e.description
+}
+
+// Пытаемся установить заголовок, если он длиннее MaxTitleLen
+// или не является корректным utf-8, то возвращаем false
+func (e *EventNoId) SetTitle(title string) bool {
+	if !utf8.ValidString(title) || utf8.RuneCountInString(title) > MaxTitleLen {
+		return false
+	}
+	e.title = title
+	return true
+}
+
+// Пытаемся установить описание, аналогично SetTitle
+func (e *EventNoId) SetDescription(description string) bool {
+	if !utf8.ValidString(description) || utf8.RuneCountInString(description) > MaxDescriptionLen {
+		return false
+	}
+	e.description = description
+	return true
+}
+
 // Пытаемся установить новый userid, если он не корректный
 // то возвращаем false
 func (e *EventNoId) SetUserId(id UserID) bool {
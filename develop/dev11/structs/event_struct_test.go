@@ -1,6 +1,7 @@
 package structs
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -57,4 +58,36 @@ func TestEventSetters(t *testing.T) {
 	if !e.SetUserId(5) {
 		t.Fatal("should be ok")
 	}
+
+	// Текстовые поля
+	if !e.SetTitle("Встреча") || e.GetTitle() != "Встреча" {
+		t.Fatal("should be ok")
+	}
+	if e.SetTitle(strings.Repeat("я", MaxTitleLen+1)) {
+		t.Fatal("title too long (not ok)")
+	}
+	if e.SetDescription("\xff") {
+		t.Fatal("invalid utf-8 (not ok)")
+	}
+	if !e.SetDescription(strings.Repeat("я", MaxDescriptionLen)) {
+		t.Fatal("should be ok")
+	}
+}
+
+func TestEventDeletedAt(t *testing.T) {
+	var e Event
+	if e.IsDeleted() {
+		t.Fatal("new event should not be deleted")
+	}
+
+	at := time.Date(2020, 05, 13, 23, 56, 23, 0, time.UTC)
+	e.SetDeletedAt(at)
+	if !e.IsDeleted() || e.GetDeletedAt() != at {
+		t.Fatalf("got: %v want: %v", e.GetDeletedAt(), at)
+	}
+
+	e.SetDeletedAt(time.Time{})
+	if e.IsDeleted() {
+		t.Fatal("event should be restored")
+	}
 }
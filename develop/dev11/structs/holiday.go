@@ -0,0 +1,18 @@
+package structs
+
+import "time"
+
+// День производственного календаря региона, отличающийся от обычного:
+// праздник или рабочий день, перенесенный на выходной
+type Holiday struct {
+	Date    time.Time
+	Name    string
+	Region  string
+	Working bool // Рабочий день, например, рабочая суббота
+}
+
+// Рабочие дни периода
+type WorkingDays struct {
+	Days     []time.Time // Рабочие дни по возрастанию
+	Holidays []Holiday   // Особые дни периода по возрастанию
+}
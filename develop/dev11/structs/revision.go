@@ -0,0 +1,118 @@
+package structs
+
+import (
+	"strconv"
+	"time"
+)
+
+type RevisionID int
+
+// Действие, которое привело к появлению ревизии
+type RevisionAction string
+
+const (
+	ActionCreate  RevisionAction = "create"
+	ActionUpdate  RevisionAction = "update"
+	ActionDelete  RevisionAction = "delete"
+	ActionRestore RevisionAction = "restore"
+	ActionRevert  RevisionAction = "revert"
+)
+
+// Изменение одного поля события
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// Ревизия события - неизменяемая запись о том, кто, когда и как его изменил
+type Revision struct {
+	id     RevisionID
+	actor  string
+	at     time.Time
+	action RevisionAction
+	event  Event // Состояние события после изменения
+	diff   []FieldChange
+}
+
+// Конструктор ревизии. before - состояние события до изменения,
+// nil если события не было
+func MakeRevision(action RevisionAction, actor string, at time.Time, before *Event, after Event) Revision {
+	return Revision{
+		actor:  actor,
+		at:     at,
+		action: action,
+		event:  after,
+		diff:   DiffEvents(before, after),
+	}
+}
+
+// Задаем id ревизии, возвращаем ревизию с id
+func (r Revision) MakeRevisionWithId(id RevisionID) (Revision, bool) {
+	if id < 0 {
+		return Revision{}, false
+	}
+	r.id = id
+	return r, true
+}
+
+func (r *Revision) GetId() RevisionID {
+	return r.id
+}
+
+func (r *Revision) GetActor() string {
+	return r.actor
+}
+
+func (r *Revision) GetAt() time.Time {
+	return r.at
+}
+
+func (r *Revision) GetAction() RevisionAction {
+	return r.action
+}
+
+func (r *Revision) GetEvent() Event {
+	return r.event
+}
+
+// Возвращает копию списка изменений, чтобы ревизию нельзя было поменять
+func (r *Revision) GetDiff() []FieldChange {
+	return append([]FieldChange(nil), r.diff...)
+}
+
+// Строковое представление полей события для истории изменений
+func (e *Event) fields() []FieldChange {
+	var deletedAt string
+	if e.IsDeleted() {
+		deletedAt = e.deletedAt.Format(time.RFC3339)
+	}
+	return []FieldChange{
+		{Field: "user_id", New: strconv.Itoa(int(e.userId))},
+		{Field: "date", New: e.date.Format("2006-01-02")},
+		{Field: "title", New: e.title},
+		{Field: "description", New: e.description},
+		{Field: "deleted_at", New: deletedAt},
+	}
+}
+
+// Возвращает список полей, которые отличаются у before и after.
+// Если before == nil, то все непустые поля after считаются новыми
+func DiffEvents(before *Event, after Event) []FieldChange {
+	var res []FieldChange
+	newFields := after.fields()
+	var oldFields []FieldChange
+	if before != nil {
+		oldFields = before.fields()
+	}
+
+	for i, f := range newFields {
+		if oldFields != nil {
+			f.Old = oldFields[i].New
+		}
+		if f.Old != f.New {
+			res = append(res, f)
+		}
+	}
+	return res
+}
@@ -0,0 +1,66 @@
+package structs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffEvents(t *testing.T) {
+	before := Event{
+		id: 1,
+		EventNoId: EventNoId{
+			userId: 2,
+			date:   time.Date(2001, 05, 13, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	after := before
+	after.userId = 3
+	after.title = "Встреча"
+
+	// Создание
+	got := DiffEvents(nil, before)
+	want := []FieldChange{
+		{Field: "user_id", New: "2"},
+		{Field: "date", New: "2001-05-13"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got: %v want: %v", got, want)
+	}
+
+	// Изменение
+	got = DiffEvents(&before, after)
+	want = []FieldChange{
+		{Field: "user_id", Old: "2", New: "3"},
+		{Field: "title", New: "Встреча"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got: %v want: %v", got, want)
+	}
+
+	// Удаление
+	at := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	after.SetDeletedAt(at)
+	got = DiffEvents(&before, after)
+	want = []FieldChange{
+		{Field: "user_id", Old: "2", New: "3"},
+		{Field: "title", New: "Встреча"},
+		{Field: "deleted_at", New: "2020-01-01T10:00:00Z"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got: %v want: %v", got, want)
+	}
+}
+
+func TestRevisionImmutableDiff(t *testing.T) {
+	r := MakeRevision(ActionCreate, "alice", time.Now(), nil, Event{})
+	r, ok := r.MakeRevisionWithId(5)
+	if !ok || r.GetId() != 5 {
+		t.Fatal("should be ok")
+	}
+	diff := r.GetDiff()
+	diff[0].New = "changed"
+	if r.GetDiff()[0].New == "changed" {
+		t.Fatal("diff should not be changed through getter")
+	}
+}
@@ -0,0 +1,18 @@
+package structs
+
+import "time"
+
+// Параметры полнотекстового поиска
+type SearchQuery struct {
+	Text   string
+	UserId UserID    // Меньше нуля - события всех пользователей
+	From   time.Time // Нулевое значение - без ограничения снизу
+	To     time.Time // Нулевое значение - без ограничения сверху
+	Limit  int       // Сколько событий вернуть, 0 - все найденные
+}
+
+// Найденное событие и его релевантность
+type SearchHit struct {
+	Event Event
+	Score float64
+}
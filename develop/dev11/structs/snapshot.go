@@ -0,0 +1,20 @@
+package structs
+
+import "time"
+
+// Согласованный на момент TakenAt срез всех событий хранилища
+type Snapshot struct {
+	TakenAt time.Time
+	Events  []Event
+	FreeId  EventID // Следующий свободный id
+}
+
+// Собирает событие со всеми полями, используется при восстановлении из снимка
+func RestoreEvent(id EventID, e EventNoId, deletedAt time.Time) (Event, bool) {
+	ev, ok := e.MakeEventWithId(id)
+	if !ok {
+		return ev, false
+	}
+	ev.deletedAt = deletedAt
+	return ev, true
+}
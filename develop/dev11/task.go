@@ -1,14 +1,25 @@
 package main
 
 import (
+	"context"
 	"dev11/endpoints"
+	"dev11/holidays"
 	"dev11/logic"
 	"dev11/middleware"
 	"dev11/models"
+	"dev11/rpc"
+	"dev11/search"
+	"dev11/server"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
 /*
@@ -42,68 +53,226 @@ import (
 	4. Код должен проходить проверки go vet и golint.
 */
 
-type muxBuilder struct {
-	mux *http.ServeMux
-}
-
-func NewMuxBuilder() *muxBuilder {
-	return &muxBuilder{
-		mux: http.NewServeMux(),
-	}
-}
-
-func (m *muxBuilder) AddEventHTTP(e *endpoints.EventHTTP) {
-	m.mux.Handle("/create_event", middleware.WithMethod("POST", http.HandlerFunc(e.CreateHandle)))
-	m.mux.Handle("/update_event", middleware.WithMethod("POST", http.HandlerFunc(e.UpdateHandle)))
-	m.mux.Handle("/delete_event", middleware.WithMethod("POST", http.HandlerFunc(e.DeleteHandle)))
-
-	m.mux.Handle("/events_for_day", middleware.WithMethod("GET", http.HandlerFunc(e.ForDayHandle)))
-	m.mux.Handle("/events_for_week", middleware.WithMethod("GET", http.HandlerFunc(e.ForWeekHandle)))
-	m.mux.Handle("/events_for_month", middleware.WithMethod("GET", http.HandlerFunc(e.ForMonthHandle)))
-}
-
-func (m *muxBuilder) Build() http.Handler {
-	// Добавляем логирование запросов
-	withLogger := middleware.Logging(m.mux)
-	return withLogger
-}
-
 type config struct {
-	addr string
+	addr           string
+	rpcAddr        string // Пустая строка - RPC сервер не запускается
+	retention      time.Duration
+	purgeInterval  time.Duration
+	bodyLimit      int64
+	batchBodyLimit int64
+	rate           float64
+	burst          int
+	userHeader     string // Пустая строка - частота ограничивается по IP, автор изменений - IP
+	adminToken     string // Пустая строка - административные ручки не регистрируются
+	restoreFile    string // Снимок, загружаемый при старте
+	snapshotFile   string // Снимок, сохраняемый при остановке
+	restoreLimit   int64
+	tenants        middleware.TenantResolver
+	quota          int      // Сколько событий может хранить один арендатор, 0 - без ограничений
+	maxTenants     int      // Сколько арендаторов может быть создано запросами, 0 - без ограничений
+	corsOrigins    []string // Пустой список - CORS выключен
+	compressMin    int      // Минимальный размер сжимаемого ответа, <0 - не сжимать
+	holidayFiles   []string // Файлы производственных календарей .ics и .json
+	holidayRegion  string   // Регион праздников для запросов без region
 }
 
 func parseConfig() *config {
 	host := flag.String("h", "127.0.0.1", "адрес, который будет прослушиваться")
 	port := flag.Int("p", 8080, "порт, на котором будет запущен http сервер")
+	rpcPort := flag.Int("rpc-port", 0, "порт, на котором будет запущен RPC сервер, 0 - не запускать")
+	retention := flag.Duration("retention", 30*24*time.Hour, "сколько хранить удаленные события в корзине")
+	purgeInterval := flag.Duration("purge-interval", time.Hour, "как часто очищать корзину")
+	bodyLimit := flag.Int64("max-body", server.DefaultBodyLimit, "максимальный размер тела запроса в байтах")
+	batchBodyLimit := flag.Int64("max-batch-body", 32<<20, "максимальный размер тела запроса /batch в байтах")
+	rate := flag.Float64("rate", 0, "сколько запросов в секунду разрешено одному клиенту, 0 - без ограничений")
+	burst := flag.Int("burst", 20, "сколько запросов клиент может сделать подряд")
+	userHeader := flag.String("user-header", "", "заголовок с аутентифицированным пользователем, который выставляет доверенный прокси. По нему ограничивается частота и записывается автор изменений, пустой - по IP")
+	adminToken := flag.String("admin-token", "", "токен для /admin/* ручек, пустой - ручки отключены")
+	restoreFile := flag.String("restore", "", "файл снимка, из которого загружаются события при старте, {tenant} заменяется на имя арендатора")
+	snapshotFile := flag.String("snapshot", "", "файл, в который сохраняется снимок событий при остановке, {tenant} заменяется на имя арендатора")
+	restoreLimit := flag.Int64("max-restore-body", 1<<30, "максимальный размер тела запроса /admin/restore в байтах")
+	tenantHeader := flag.String("tenant-header", "X-Tenant", "заголовок с именем арендатора")
+	tenantDomain := flag.String("tenant-domain", "", "домен, поддомены которого задают арендатора (team.<domain>)")
+	defaultTenant := flag.String("default-tenant", logic.DefaultTenant, "арендатор для запросов без него, пустой - такие запросы отклоняются")
+	quota := flag.Int("quota", 0, "сколько событий может хранить один арендатор, 0 - без ограничений")
+	maxTenants := flag.Int("max-tenants", 1000, "сколько арендаторов может быть создано запросами, 0 - без ограничений")
+	corsOrigins := flag.String("cors-origins", "", "источники через запятую, которым разрешены запросы из браузера, * - любые, пустой - CORS выключен")
+	compressMin := flag.Int("compress-min-size", 1024, "минимальный размер ответа в байтах, который сжимается, отрицательный - не сжимать")
+	holidayFiles := flag.String("holidays", "", "файлы производственных календарей .ics или .json через запятую, регион - имя файла (ru.ics)")
+	holidayRegion := flag.String("holiday-region", "", "регион, праздники которого показываются в events_for_* без region, пустой - только по запросу")
 	flag.Parse()
+	if *purgeInterval <= 0 {
+		usageError("-purge-interval should be positive")
+	}
+	if *retention < 0 {
+		usageError("-retention should not be negative")
+	}
+	if *rate > 0 && *burst < 1 {
+		usageError("-burst should be at least 1 when -rate is set")
+	}
+	var rpcAddr string
+	if *rpcPort != 0 {
+		rpcAddr = fmt.Sprintf("%s:%d", *host, *rpcPort)
+	}
 	return &config{
-		addr: fmt.Sprintf("%s:%d", *host, *port),
+		addr:           fmt.Sprintf("%s:%d", *host, *port),
+		rpcAddr:        rpcAddr,
+		retention:      *retention,
+		purgeInterval:  *purgeInterval,
+		bodyLimit:      *bodyLimit,
+		batchBodyLimit: *batchBodyLimit,
+		rate:           *rate,
+		burst:          *burst,
+		userHeader:     *userHeader,
+		adminToken:     *adminToken,
+		restoreFile:    *restoreFile,
+		snapshotFile:   *snapshotFile,
+		restoreLimit:   *restoreLimit,
+		tenants: middleware.TenantResolver{
+			Header:  *tenantHeader,
+			Domain:  *tenantDomain,
+			Default: *defaultTenant,
+		},
+		quota:         *quota,
+		maxTenants:    *maxTenants,
+		corsOrigins:   splitList(*corsOrigins),
+		compressMin:   *compressMin,
+		holidayFiles:  splitList(*holidayFiles),
+		holidayRegion: *holidayRegion,
+	}
+}
+
+// Сообщает о неверном флаге и завершает программу
+func usageError(msg string) {
+	fmt.Fprintln(os.Stderr, msg)
+	flag.Usage()
+	os.Exit(2)
+}
+
+// Разбирает список через запятую, пустые элементы отбрасываются
+func splitList(s string) []string {
+	var res []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
 	}
+	return res
 }
 
 func main() {
 	// Получаем конфиги
 	cfg := parseConfig()
 
-	// Модель, позволяющая взаимодействовать с БД событий
-	eventModel := models.NewEventModelMemory()
-	// Слой бизнес-логики
-	eventApi := logic.NewEventAPI(eventModel)
+	// Производственные календари общие для всех арендаторов
+	cal, err := holidays.LoadFiles(cfg.holidayFiles...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.holidayRegion != "" && !cal.HasRegion(cfg.holidayRegion) {
+		log.Fatalf("no holiday calendar for region %q", cfg.holidayRegion)
+	}
+
+	// Слой бизнес-логики, у каждого арендатора свои события и история
+	tenants := logic.NewTenants(func(tenant string) *logic.EventAPI {
+		// Модель, позволяющая взаимодействовать с БД событий
+		eventModel := models.NewEventModelMemory()
+		eventModel.SetQuota(cfg.quota)
+		// История изменений событий
+		revisionModel := models.NewRevisionModelMemory()
+		return logic.NewEventAPI(eventModel).WithRevisions(revisionModel).WithSearch(search.NewIndex()).
+			WithHolidays(cal, cfg.holidayRegion)
+	})
+	// Новые имена арендаторов из запросов не должны бесконечно создавать хранилища
+	tenants.SetLimit(cfg.maxTenants)
+	// Загружаем события из снимков
+	if cfg.restoreFile != "" {
+		if err := loadSnapshots(tenants, cfg.restoreFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// Контекст, отменяемый при получении сигнала остановки
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// Фоновая очистка корзины
+	go tenants.PurgeLoop(ctx, cfg.retention, cfg.purgeInterval)
 	// http ручки
-	eventHTTP := endpoints.NewEventHTTP(eventApi)
+	eventHTTP := endpoints.NewTenantEventHTTP(tenants)
 	log.Println("eventHTTP ready")
 
 	// Строим мультиплексер
-	mb := NewMuxBuilder()
+	mb := server.NewMuxBuilder()
+	mb.SetBodyLimit(cfg.bodyLimit)
+	mb.SetRouteBodyLimit("/batch", cfg.batchBodyLimit)
+	mb.SetTenantResolver(cfg.tenants)
+	mb.SetCompression(cfg.compressMin)
+	if len(cfg.corsOrigins) > 0 {
+		mb.SetCORS(middleware.CORSConfig{
+			AllowedOrigins: cfg.corsOrigins,
+			AllowedMethods: []string{"GET", "POST", "OPTIONS"},
+			AllowedHeaders: []string{"Content-Type", "Authorization", cfg.tenants.Header},
+			ExposedHeaders: []string{"ETag", "Last-Modified"},
+			MaxAge:         10 * time.Minute,
+		})
+	}
+	// Один ограничитель на HTTP и RPC, чтобы клиент не мог обойти его через RPC
+	var limiter *middleware.RateLimiter
+	limitKey := middleware.ClientIP
+	if cfg.userHeader != "" {
+		limitKey = middleware.UserKey(cfg.userHeader)
+		mb.SetUserHeader(cfg.userHeader)
+	}
+	if cfg.rate > 0 {
+		limiter = middleware.NewRateLimiter(cfg.rate, cfg.burst)
+		mb.SetRateLimiter(limiter, limitKey)
+	}
 	mb.AddEventHTTP(eventHTTP)
+	if cfg.adminToken != "" {
+		mb.SetRouteBodyLimit("/admin/restore", cfg.restoreLimit)
+		mb.AddAdmin(eventHTTP, cfg.adminToken)
+	}
+	mb.AddOpenAPI()
 	// Получаем его
 	mux := mb.Build()
 	log.Println("mux build")
 
+	// RPC сервер на отдельном порту
+	if cfg.rpcAddr != "" {
+		var rpcHandler http.Handler = middleware.Tenant(cfg.tenants,
+			middleware.MaxBytes(cfg.bodyLimit, rpc.NewTenantServer(tenants)))
+		if cfg.userHeader != "" {
+			rpcHandler = middleware.User(cfg.userHeader, rpcHandler)
+		}
+		if limiter != nil {
+			rpcHandler = middleware.RateLimit(limiter, limitKey, rpcHandler)
+		}
+		rpcHandler = middleware.Logging(middleware.Recover(rpcHandler))
+		go func() {
+			log.Printf("RPC ListenAndServe: %s\n", cfg.rpcAddr)
+			log.Fatal(http.ListenAndServe(cfg.rpcAddr, rpcHandler))
+		}()
+	}
+
 	// Настраиваем сервер
+	srv := &http.Server{Addr: cfg.addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Println(err)
+		}
+	}()
 	log.Printf("HTTP ListenAndServe: %s\n", cfg.addr)
-	err := http.ListenAndServe(cfg.addr, mux)
-	if err != nil {
+	err = srv.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal(err)
 	}
+
+	// Сохраняем снимки после остановки, когда запросов уже нет
+	if cfg.snapshotFile != "" {
+		if err := saveSnapshots(tenants, cfg.snapshotFile); err != nil {
+			log.Fatal(err)
+		}
+	}
 }